@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var domicileRegionsFlag []string
+
+var analyzeDomicileCmd = &cobra.Command{
+	Use:   "domicile",
+	Short: "Check data domiciling against super regions",
+	Long: "Verifies that no replicas of regional by table tables or regional by row partitions can be placed" +
+		" outside their allowed regions, given the constraints, voter constraints and number of replicas of their" +
+		" zone configurations. The allowed regions are those of the super region containing the home region, or" +
+		" only the home region when it is not in a super region. Exits with an error if any violations are found.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		regions, err := analyzer.DatabaseRegions()
+		if err != nil {
+			return err
+		}
+		logrus.Infoln(regions)
+		for _, superRegion := range regions.SuperRegions {
+			logrus.Infoln(superRegion)
+		}
+
+		findings, err := analyzer.CheckDataDomiciling(analyze.DomicileConfig{Regions: domicileRegionsFlag})
+		if err != nil {
+			return err
+		}
+
+		errorCount := 0
+		for _, finding := range findings {
+			if finding.Severity == analyze.ZoneLintSeverityError {
+				errorCount++
+				logrus.Errorln(finding)
+			} else {
+				logrus.Warnln(finding)
+			}
+		}
+		if len(findings) == 0 {
+			logrus.Infoln(" -- NONE --")
+		}
+		if errorCount > 0 {
+			return fmt.Errorf("found %d data domiciling violations", errorCount)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeDomicileCmd)
+	analyzeDomicileCmd.Flags().StringSliceVar(&domicileRegionsFlag, "regions", []string{},
+		"Only check tables and partitions homed in these regions (comma-separated)")
+}
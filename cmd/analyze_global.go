@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var globalMaxSizeFlag uint64
+var globalMaxRowsFlag int
+var globalMinReadRatioFlag float64
+var globalShowAllFlag bool
+
+var analyzeGlobalCmd = &cobra.Command{
+	Use:   "global",
+	Short: "Recommend tables for LOCALITY GLOBAL",
+	Long: "Recommends small, read-mostly tables for LOCALITY GLOBAL using their logical size, row count and the" +
+		" read/write ratio from statement statistics. Also shows the regional by row tables whose FK checks" +
+		" would read locally if the table were GLOBAL. Use convert to-global to generate the changes.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		recommendations, err := analyzer.GlobalRecommendations(analyze.GlobalRecommendationConfig{
+			MaxSizeBytes: globalMaxSizeFlag,
+			MaxRows:      globalMaxRowsFlag,
+			MinReadRatio: globalMinReadRatioFlag,
+		})
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, recommendation := range recommendations {
+			if recommendation.Recommended || globalShowAllFlag {
+				count++
+				logrus.Infoln(recommendation)
+			}
+		}
+		if count == 0 {
+			logrus.Infoln(" -- NONE --")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeGlobalCmd)
+	analyzeGlobalCmd.Flags().Uint64Var(&globalMaxSizeFlag, "max-size", analyze.DefaultGlobalMaxSizeBytes,
+		"Maximum logical size in bytes")
+	analyzeGlobalCmd.Flags().IntVar(&globalMaxRowsFlag, "max-rows", analyze.DefaultGlobalMaxRows,
+		"Maximum estimated row count")
+	analyzeGlobalCmd.Flags().Float64Var(&globalMinReadRatioFlag, "min-read-ratio", analyze.DefaultGlobalMinReadRatio,
+		"Minimum fraction of statement executions that are reads")
+	analyzeGlobalCmd.Flags().BoolVarP(&globalShowAllFlag, "all", "a", false, "Show tables that are not recommended")
+}
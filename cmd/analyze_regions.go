@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var showRegionTablesFlag bool
+
+var analyzeRegionsCmd = &cobra.Command{
+	Use:   "regions",
+	Short: "Analyze multi-region configuration",
+	Long: "Shows the database regions, primary region, survival goal, placement policy and super regions," +
+		" along with a breakdown of tables by locality and their logical sizes.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		regions, err := analyzer.DatabaseRegions()
+		if err != nil {
+			return err
+		}
+
+		logrus.Infoln(regions)
+		if !regions.IsMultiRegion() {
+			logrus.Infof("Database %s is not a multi-region database\n", regions.Database)
+		}
+		for _, region := range regions.Regions {
+			logrus.Infof("Region: %s, Primary: %t, Zones: %v\n", region.Name, region.Primary, region.Zones)
+		}
+		for _, superRegion := range regions.SuperRegions {
+			logrus.Infoln(superRegion)
+		}
+
+		groups, err := analyzer.LocalityGroups(regions, true)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			logrus.Infoln(group)
+			if showRegionTablesFlag {
+				for _, table := range group.Tables {
+					logrus.Infof("  %s (%s)\n", table.Name, table.Locality)
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeRegionsCmd)
+	analyzeRegionsCmd.Flags().BoolVarP(&showRegionTablesFlag, "show-tables", "t", false, "List the tables in each locality")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var rowsAsOfFlag string
+var rowsSampleRateFlag float64
+var rowsDominantShareFlag float64
+var rowsCandidatesOnlyFlag bool
+
+var analyzeRowsCmd = &cobra.Command{
+	Use:   "rows",
+	Short: "Analyze the distribution of regional by row rows per region",
+	Long: "Counts the rows and estimates the logical bytes per region for each regional by row table. All tables" +
+		" are read at the same AS OF SYSTEM TIME timestamp. Tables with nearly all rows in one region are flagged" +
+		" as candidates for regional by table, e.g. with rbr2rbt.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if rowsSampleRateFlag <= 0 || rowsSampleRateFlag > 1 {
+			return fmt.Errorf("sample rate %g must be greater than 0 and at most 1", rowsSampleRateFlag)
+		}
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		filter, err := analyze.NewTableFilter(tableIncludeFlag, tableExcludeFlag, nil, 0, 0, "", 0)
+		if err != nil {
+			return err
+		}
+
+		distributions, err := analyzer.RowDistributions(analyze.RowDistributionConfig{
+			AsOf:          rowsAsOfFlag,
+			SampleRate:    rowsSampleRateFlag,
+			DominantShare: rowsDominantShareFlag,
+			Filter:        filter,
+		})
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, distribution := range distributions {
+			if rowsCandidatesOnlyFlag && distribution.DominantRegion == "" {
+				continue
+			}
+			count++
+			logrus.Infoln(distribution)
+		}
+		if count == 0 {
+			logrus.Infoln(" -- NONE --")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeRowsCmd)
+	analyzeRowsCmd.Flags().StringVar(&rowsAsOfFlag, "as-of", "",
+		"AS OF SYSTEM TIME interval, timestamp or expression, e.g. -1m (default is the follower read timestamp)")
+	analyzeRowsCmd.Flags().Float64Var(&rowsSampleRateFlag, "sample-rate", 1,
+		"Fraction of rows to count, greater than 0 and at most 1. Every row is still read, sampling only "+
+			"reduces the rows grouped and sent back")
+	analyzeRowsCmd.Flags().Float64Var(&rowsDominantShareFlag, "dominant-share", analyze.DefaultDominantShare,
+		"Share of rows in one region that flags a table as a regional by table candidate")
+	analyzeRowsCmd.Flags().BoolVar(&rowsCandidatesOnlyFlag, "candidates-only", false, "Only show regional by table candidates")
+	analyzeRowsCmd.Flags().StringSliceVar(&tableIncludeFlag, "include", []string{}, "Only include tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	analyzeRowsCmd.Flags().StringSliceVar(&tableExcludeFlag, "exclude", []string{}, "Exclude tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+}
@@ -8,6 +8,15 @@ import (
 
 var includeSizeFlag bool
 var includeFKsFlag bool
+var tableIncludeFlag []string
+var tableExcludeFlag []string
+var tableLocalityFlag []string
+var minSizeFlag uint64
+var minRowsFlag int
+var tableSortFlag string
+var topFlag int
+var includeViewsFlag bool
+var includeSequencesFlag bool
 
 var analyzeTablesCmd = &cobra.Command{
 	Use:   "tables",
@@ -23,7 +32,13 @@ var analyzeTablesCmd = &cobra.Command{
 			return err
 		}
 
-		tables, err := analyzer.Tables(includeSizeFlag, includeFKsFlag)
+		filter, err := analyze.NewTableFilter(tableIncludeFlag, tableExcludeFlag, tableLocalityFlag,
+			minSizeFlag, minRowsFlag, tableSortFlag, topFlag)
+		if err != nil {
+			return err
+		}
+
+		tables, err := analyzer.Tables(includeSizeFlag, includeFKsFlag, filter)
 		for _, table := range tables {
 			logrus.Infoln(table)
 		}
@@ -31,6 +46,26 @@ var analyzeTablesCmd = &cobra.Command{
 			return err
 		}
 
+		if includeViewsFlag {
+			views, err := analyzer.Views(filter)
+			if err != nil {
+				return err
+			}
+			for _, view := range views {
+				logrus.Infoln(view)
+			}
+		}
+
+		if includeSequencesFlag {
+			sequences, err := analyzer.Sequences(filter)
+			if err != nil {
+				return err
+			}
+			for _, sequence := range sequences {
+				logrus.Infoln(sequence)
+			}
+		}
+
 		return nil
 	},
 }
@@ -39,4 +74,13 @@ func init() {
 	analyzeCmd.AddCommand(analyzeTablesCmd)
 	analyzeTablesCmd.Flags().BoolVarP(&includeSizeFlag, "include-size", "s", false, "Include table sizes (slower)")
 	analyzeTablesCmd.Flags().BoolVarP(&includeFKsFlag, "include-foreign-keys", "f", false, "Include foreign keys (slower)")
+	analyzeTablesCmd.Flags().StringSliceVar(&tableIncludeFlag, "include", []string{}, "Only include tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	analyzeTablesCmd.Flags().StringSliceVar(&tableExcludeFlag, "exclude", []string{}, "Exclude tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	analyzeTablesCmd.Flags().StringSliceVar(&tableLocalityFlag, "locality", []string{}, "Limit to localities: RBR, RBT, GLOBAL (comma-separated)")
+	analyzeTablesCmd.Flags().Uint64Var(&minSizeFlag, "min-size", 0, "Minimum logical size in bytes (implies --include-size)")
+	analyzeTablesCmd.Flags().IntVar(&minRowsFlag, "min-rows", 0, "Minimum estimated row count")
+	analyzeTablesCmd.Flags().StringVar(&tableSortFlag, "sort", "", "Sort by: size, rows, bytes-per-row, fks, referenced, name")
+	analyzeTablesCmd.Flags().BoolVar(&includeViewsFlag, "include-views", false, "Include views and materialized views")
+	analyzeTablesCmd.Flags().BoolVar(&includeSequencesFlag, "include-sequences", false, "Include sequences")
+	analyzeTablesCmd.Flags().IntVar(&topFlag, "top", 0, "Only show the top N tables")
 }
@@ -0,0 +1,15 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var analyzeZonesCmd = &cobra.Command{
+	Use:   "zones",
+	Short: "Analyze zone configurations",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	analyzeCmd.AddCommand(analyzeZonesCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var zonesExportOutFlag string
+var zonesExportFormatFlag string
+
+var analyzeZonesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export zone configurations to a file",
+	Long: "Writes every zone configuration for the database to a versioned YAML or JSON file, including the" +
+		" target, the parsed configuration and the raw SQL. Take an export before running rbr2rbt, which" +
+		" replaces and discards zone overrides, so they can be brought back with convert zones restore.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		export, err := analyzer.ExportZoneConfigs()
+		if err != nil {
+			return err
+		}
+
+		err = analyze.WriteZoneConfigExport(export, zonesExportOutFlag, analyze.ZoneConfigExportFormat(zonesExportFormatFlag))
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Exported %d zone configurations to %s", len(export.Zones), zonesExportOutFlag)
+		return nil
+	},
+}
+
+func init() {
+	analyzeZonesCmd.AddCommand(analyzeZonesExportCmd)
+	analyzeZonesExportCmd.Flags().StringVarP(&zonesExportOutFlag, "out", "o", "", "File to write the export to")
+	analyzeZonesExportCmd.Flags().StringVar(&zonesExportFormatFlag, "format", "",
+		"Export format, json or yaml (default determined from the file extension)")
+	err := analyzeZonesExportCmd.MarkFlagRequired("out")
+	if err != nil {
+		panic(err)
+	}
+}
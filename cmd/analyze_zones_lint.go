@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var analyzeZonesLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Lint zone configurations",
+	Long: "Checks each zone configuration against the database survival goal, regions and the locality of" +
+		" the table it applies to. Reports too few voters for the survival goal, lease preferences outside the" +
+		" home region, constraints naming regions that are not in the database and overrides that diverge from" +
+		" what the multi-region abstractions would set. Exits with an error if any errors are found.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		findings, err := analyzer.LintZoneConfigs()
+		if err != nil {
+			return err
+		}
+
+		errorCount := 0
+		for _, finding := range findings {
+			if finding.Severity == analyze.ZoneLintSeverityError {
+				errorCount++
+				logrus.Errorln(finding)
+			} else {
+				logrus.Warnln(finding)
+			}
+		}
+		if len(findings) == 0 {
+			logrus.Infoln(" -- NONE --")
+		}
+		if errorCount > 0 {
+			return fmt.Errorf("found %d zone configuration errors", errorCount)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	analyzeZonesCmd.AddCommand(analyzeZonesLintCmd)
+}
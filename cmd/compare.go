@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var rightDatabaseFlag string
+var compareJsonFlag bool
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <left-url> <right-url>",
+	Short: "Compare the schema of two clusters",
+	Long: "Compares tables, columns, indexes, FKs with their rules, localities and zone configurations between" +
+		" two clusters, such as staging and production. Objects only in the right cluster are shown with +," +
+		" objects only in the left cluster with - and objects that differ with ~. Exits with an error if any" +
+		" differences are found.",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		rightDatabase := rightDatabaseFlag
+		if rightDatabase == "" {
+			rightDatabase = databaseFlag
+		}
+
+		var schemas []analyze.Schema
+		for i, database := range []string{databaseFlag, rightDatabase} {
+			analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{
+				DbUrl:    args[i],
+				Database: database,
+			})
+			if err != nil {
+				return err
+			}
+			schema, err := analyzer.Schema()
+			if err != nil {
+				return err
+			}
+			schemas = append(schemas, schema)
+		}
+
+		comparison := analyze.CompareSchemas(schemas[0], schemas[1])
+
+		if compareJsonFlag {
+			out, err := json.MarshalIndent(comparison, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			logrus.Infof("Comparing %s (left) to %s (right)\n", comparison.LeftDatabase, comparison.RightDatabase)
+			for _, difference := range comparison.Differences {
+				fmt.Println(difference)
+			}
+			if len(comparison.Differences) == 0 {
+				logrus.Infoln(" -- NONE --")
+			}
+		}
+
+		if len(comparison.Differences) > 0 {
+			return fmt.Errorf("found %d differences", len(comparison.Differences))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().StringVar(&rightDatabaseFlag, "right-database", "",
+		"Database in the right cluster (default is the same as --database)")
+	compareCmd.Flags().BoolVar(&compareJsonFlag, "json", false, "Output the differences as JSON")
+}
@@ -1,6 +1,21 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var writeToFileFlag bool
+var outFileFlag string
+var planJsonFlag bool
+var outDirFlag string
+
+// phaseFilesDir is the directory --write-to-file writes phase files to
+const phaseFilesDir = "tmp"
+
+// rollbackDir is the subdirectory a rollback plan is written to, next to the plan it rolls back
+const rollbackDir = "rollback"
 
 var convertCmd = &cobra.Command{
 	Use:   "convert",
@@ -10,6 +25,52 @@ var convertCmd = &cobra.Command{
 	},
 }
 
+// planWriter returns the writer selected by the convert flags. Phase files are written to tmp.
+func planWriter() (analyze.PlanWriter, error) {
+	switch {
+	case writeToFileFlag:
+		return analyze.PhaseFilesPlanWriter{Dir: phaseFilesDir}, nil
+	case outFileFlag != "":
+		return analyze.SingleFilePlanWriter{Path: outFileFlag}, nil
+	case outDirFlag != "":
+		return outDirPlanWriter(outDirFlag)
+	case planJsonFlag:
+		return analyze.JsonPlanWriter{Out: os.Stdout}, nil
+	default:
+		return analyze.SqlPlanWriter{Out: os.Stdout}, nil
+	}
+}
+
+// outDirPlanWriter returns the writer for numbered phase files and a manifest in the directory, with the
+// schema the plan is generated from, to detect drift before the plan is executed
+func outDirPlanWriter(dir string) (analyze.OutDirPlanWriter, error) {
+	analyzer, err := analyze.NewAnalyzer(analyze.AnalyzerConfig{DbUrl: urlFlag, Database: databaseFlag})
+	if err != nil {
+		return analyze.OutDirPlanWriter{}, err
+	}
+	schema, err := analyzer.Schema()
+	if err != nil {
+		return analyze.OutDirPlanWriter{}, err
+	}
+	return analyze.OutDirPlanWriter{Dir: dir, Schema: &schema}, nil
+}
+
+// writePlan writes the plan with the writer selected by the convert flags
+func writePlan(plan *analyze.Plan) error {
+	w, err := planWriter()
+	if err != nil {
+		return err
+	}
+	return w.Write(plan)
+}
+
 func init() {
 	rootCmd.AddCommand(convertCmd)
+	convertCmd.PersistentFlags().BoolVarP(&writeToFileFlag, "write-to-file", "f", false,
+		"Write each phase to its own file in tmp instead of stdout, refusing files that already exist")
+	convertCmd.PersistentFlags().StringVar(&outFileFlag, "out-file", "", "Write the plan to a single file")
+	convertCmd.PersistentFlags().StringVar(&outDirFlag, "out-dir", "",
+		"Write numbered phase files and a manifest to a new or empty directory, to run with execute plan")
+	convertCmd.PersistentFlags().BoolVar(&planJsonFlag, "json", false, "Print the plan with statement metadata as JSON")
+	convertCmd.MarkFlagsMutuallyExclusive("write-to-file", "out-file", "out-dir", "json")
 }
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var addRegionFlag string
+
+var convertAddRegionCmd = &cobra.Command{
+	Use:   "add-region",
+	Short: "Plan the addition of a database region",
+	Long: "Generates ALTER DATABASE ... ADD REGION along with updates to zone overrides that pin num_replicas to" +
+		" the multi-region default. The plan starts with an estimate of the replica count and extra logical bytes" +
+		" per table and lists the zone overrides that would keep the new region from getting replicas.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		plan, err := converter.AddRegionPlan(addRegionFlag)
+		if err != nil {
+			return err
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertAddRegionCmd)
+	convertAddRegionCmd.Flags().StringVarP(&addRegionFlag, "region", "r", "", "Region to add")
+	err := convertAddRegionCmd.MarkFlagRequired("region")
+	if err != nil {
+		panic(err)
+	}
+}
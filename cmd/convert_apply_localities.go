@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var localitySpecFlag string
+
+var convertApplyLocalitiesCmd = &cobra.Command{
+	Use:   "apply-localities",
+	Short: "Convert tables to the localities of a spec file",
+	Long: "Generates the plan that brings tables to the localities in a spec file. The spec maps table names or" +
+		" globs, or regular expressions prefixed with 're:', to RBR, RBT in a region or GLOBAL, with an optional" +
+		" table zone configuration. The first matching entry applies and unmatched tables are left as they are." +
+		" The plan replaces FKs that include the region, changes localities and region column types like rbr2rbt" +
+		" and rbt2rbr do, discards zone configurations of tables changing locality and applies the zone" +
+		" configurations of the spec.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		spec, err := analyze.ReadLocalitySpec(localitySpecFlag)
+		if err != nil {
+			return err
+		}
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		plan, err := converter.ApplyLocalitiesPlan(spec)
+		if err != nil {
+			return err
+		}
+
+		for _, comment := range plan.Comments {
+			if strings.HasPrefix(comment, "WARNING:") {
+				logrus.Warnln(comment)
+			}
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertApplyLocalitiesCmd)
+	convertApplyLocalitiesCmd.Flags().StringVar(&localitySpecFlag, "spec", "", "Locality spec file, YAML or JSON")
+	err := convertApplyLocalitiesCmd.MarkFlagRequired("spec")
+	if err != nil {
+		panic(err)
+	}
+}
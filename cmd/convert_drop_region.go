@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var dropRegionFlag string
+var dropRegionTargetFlag string
+var dropRegionNewPrimaryFlag string
+var dropRegionBatchSizeFlag int
+
+var convertDropRegionCmd = &cobra.Command{
+	Use:   "drop-region",
+	Short: "Plan the removal of a database region",
+	Long: "Finds everything blocking ALTER DATABASE ... DROP REGION - regional by table tables homed in the region," +
+		" regional by row rows homed in the region, zone configurations naming the region and super regions" +
+		" containing it - and generates an ordered plan that re-homes the rows, moves the tables, fixes the zone" +
+		" configurations and super regions and finally drops the region. Rows are re-homed walking the primary" +
+		" key in keyset batches that execute resumes from its state file, and tables with a computed region" +
+		" column are left with a warning.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		plan, err := converter.DropRegionPlan(dropRegionFlag, dropRegionTargetFlag, dropRegionNewPrimaryFlag,
+			dropRegionBatchSizeFlag)
+		if err != nil {
+			return err
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertDropRegionCmd)
+	convertDropRegionCmd.Flags().StringVarP(&dropRegionFlag, "region", "r", "", "Region to drop")
+	convertDropRegionCmd.Flags().StringVar(&dropRegionTargetFlag, "target-region", "",
+		"Region to move rows and tables to (default is the primary region)")
+	convertDropRegionCmd.Flags().StringVar(&dropRegionNewPrimaryFlag, "new-primary-region", "",
+		"New primary region, required when dropping the primary region")
+	convertDropRegionCmd.Flags().IntVar(&dropRegionBatchSizeFlag, "rehome-batch-size", analyze.DefaultRehomeBatchSize,
+		"Number of rows each rehome batch walks")
+	err := convertDropRegionCmd.MarkFlagRequired("region")
+	if err != nil {
+		panic(err)
+	}
+}
@@ -1,22 +1,35 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
 var primaryRegionFlag string
-var writeToFileFlag bool
+var rollbackFlag bool
+var skipPreflightFlag bool
+var rbr2rbtTablesFlag []string
+var rbr2rbtExcludeTablesFlag []string
+var includeDependentsFlag bool
+var estimateFlag bool
+var estimateConcurrencyFlag int
+var rehomeRowsFlag bool
+var rehomeBatchSizeFlag int
 
 var convertRbr2rbtCmd = &cobra.Command{
 	Use:   "rbr2rbt",
 	Short: "Convert RBR to RBT tables",
-	Long:  "Convert regional by row to regional by table in the primary region",
+	Long: "Convert regional by row to regional by table in the primary region. Preflight checks run first and" +
+		" block the conversion on problems it cannot handle. Tables using REGIONAL BY ROW AS keep their own region" +
+		" column, and indexes on the region column are recreated around its type change. Steps done by a previous" +
+		" run are left out, so the conversion can be regenerated after partial progress. A rollback plan is built" +
+		" from the state before the conversion.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		converter, err := analyze.NewConverter(analyze.ConverterConfig{
@@ -28,66 +41,131 @@ var convertRbr2rbtCmd = &cobra.Command{
 			return err
 		}
 
-		statements, err := converter.Rbr2rbtSqlStatements(primaryRegionFlag)
+		config := analyze.Rbr2rbtConfig{PrimaryRegion: primaryRegionFlag, IncludeDependents: includeDependentsFlag,
+			RehomeRows: rehomeRowsFlag, RehomeBatchSize: rehomeBatchSizeFlag}
+		if len(rbr2rbtTablesFlag) > 0 || len(rbr2rbtExcludeTablesFlag) > 0 {
+			config.Filter, err = analyze.NewTableFilter(rbr2rbtTablesFlag, rbr2rbtExcludeTablesFlag, nil, 0, 0, "", 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !skipPreflightFlag {
+			findings, err := converter.Analyzer.Rbr2rbtPreflight(config)
+			if err != nil {
+				return err
+			}
+			for _, finding := range findings {
+				logrus.Errorln(finding)
+			}
+			if len(findings) > 0 {
+				return fmt.Errorf("preflight found %d problems, use --skip-preflight to generate the conversion anyway",
+					len(findings))
+			}
+		}
+
+		plan, err := converter.Rbr2rbtPlan(config)
 		if err != nil {
 			return err
 		}
 
-		if writeToFileFlag {
+		for _, line := range plan.Progress() {
+			logrus.Infoln(line)
+		}
+		for _, comment := range plan.Comments {
+			if strings.HasPrefix(comment, "WARNING:") {
+				logrus.Warnln(comment)
+			}
+		}
 
-			// Create directory
-			dir := "tmp"
-			err := os.MkdirAll(dir, 0755)
+		if estimateFlag {
+			estimate, err := converter.Rbr2rbtEstimate(plan, analyze.DefaultEstimateRates, estimateConcurrencyFlag)
 			if err != nil {
-				fmt.Println("Error creating directory:", err)
 				return err
 			}
-
-			defaultFilePath := filepath.Join(dir, "default.sql")
-			defaultFile, err := os.Create(defaultFilePath)
-			defer defaultFile.Close()
-			fileStartRe := regexp.MustCompile(`^-- FILE START (.*)$`)
-
-			activeFile := defaultFile
-			for _, statement := range statements {
-				// If starting a file, create it and mark it as the active file
-				if match := fileStartRe.FindStringSubmatch(statement); len(match) > 1 {
-					fileName := match[1]
-					filePath := filepath.Join(dir, fileName)
-					file, err := os.Create(filePath)
-					if err != nil {
-						return err
-					}
-					activeFile = file
-				} else if statement == "-- FILE END" {
-					activeFile.Close()
-					activeFile = defaultFile
-				} else if len(statement) == 0 || strings.HasPrefix(statement, "--") { // comment
-					_, err2 := activeFile.WriteString(fmt.Sprintf("%s\n", statement))
-					if err2 != nil {
-						return err2
-					}
-				} else {
-					_, err2 := activeFile.WriteString(fmt.Sprintf("%s;\n", statement))
-					if err2 != nil {
-						return err2
-					}
-				}
+			for _, comment := range estimate.Comments() {
+				logrus.Infoln(comment)
 			}
+			plan.Comment(estimate.Comments()...)
+		}
 
-		} else {
-			for _, statement := range statements {
-				fmt.Printf("%s;\n", statement)
+		rollback, err := converter.Rbr2rbtRollbackPlan(config)
+		if err != nil {
+			return err
+		}
+		for _, comment := range rollback.Comments {
+			if strings.HasPrefix(comment, "WARNING:") {
+				logrus.Warnln(comment)
 			}
 		}
 
-		return nil
+		// The rollback phase files are written to a subdirectory, so its default.sql does not replace the
+		// one of the conversion. A rollback written by a previous run is kept, since tables that run converted
+		// can no longer be rolled back from the current state.
+		if writeToFileFlag && !rollbackFlag {
+			dir := filepath.Join(phaseFilesDir, rollbackDir)
+			entries, err := os.ReadDir(dir)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if len(entries) > 0 {
+				return fmt.Errorf("%s holds the rollback of a previous run, move it away before writing the "+
+					"conversion again", dir)
+			}
+			if err := writePlan(plan); err != nil {
+				return err
+			}
+			return analyze.PhaseFilesPlanWriter{Dir: filepath.Join(phaseFilesDir, rollbackDir)}.Write(rollback)
+		}
+		// The rollback gets its own manifest in a subdirectory of the conversion. It runs after the conversion,
+		// whose execution records the state the rollback expects.
+		if outDirFlag != "" {
+			if rollbackFlag {
+				return fmt.Errorf("--rollback cannot be used with --out-dir, the rollback is written to %s",
+					filepath.Join(outDirFlag, rollbackDir))
+			}
+			w, err := outDirPlanWriter(outDirFlag)
+			if err != nil {
+				return err
+			}
+			w.Rollback = rollbackDir
+			if err := w.Write(plan); err != nil {
+				return err
+			}
+			w.Dir, w.Rollback, w.RunsAfter = filepath.Join(outDirFlag, rollbackDir), "", ".."
+			return w.Write(rollback)
+		}
+		if rollbackFlag {
+			return writePlan(rollback)
+		}
+		return writePlan(plan)
 	},
 }
 
 func init() {
 	convertCmd.AddCommand(convertRbr2rbtCmd)
-	convertRbr2rbtCmd.Flags().BoolVarP(&writeToFileFlag, "write-to-file", "f", false, "Write to file instead of stdout")
+	convertRbr2rbtCmd.Flags().BoolVar(&rollbackFlag, "rollback", false,
+		"Print the rollback plan instead of the conversion, which --out-dir and --write-to-file otherwise write to"+
+			" the rollback subdirectory")
+	convertRbr2rbtCmd.Flags().BoolVar(&skipPreflightFlag, "skip-preflight", false,
+		"Generate the conversion without preflight checks for the primary region, replacement FK names, the region"+
+			" column in primary keys, unique indexes, views and functions, and schema changes in flight")
+	convertRbr2rbtCmd.Flags().StringSliceVar(&rbr2rbtTablesFlag, "tables", nil,
+		"Only convert tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	convertRbr2rbtCmd.Flags().StringSliceVar(&rbr2rbtExcludeTablesFlag, "exclude-tables", nil,
+		"Do not convert tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	convertRbr2rbtCmd.Flags().BoolVar(&includeDependentsFlag, "include-dependents", false,
+		"Also convert tables linked to the selected tables by FKs that include the region, which are otherwise reported")
+	convertRbr2rbtCmd.Flags().BoolVar(&estimateFlag, "estimate", false,
+		"Estimate the duration and data movement of each phase and table from table sizes and range counts, at the"+
+			" top of the plan")
+	convertRbr2rbtCmd.Flags().IntVar(&estimateConcurrencyFlag, "concurrency", 5,
+		"Concurrency of execute parallel used for the estimate")
+	convertRbr2rbtCmd.Flags().BoolVar(&rehomeRowsFlag, "rehome-rows", false,
+		"Update the region column of every row to the primary region after the locality change, in keyset batches"+
+			" that execute resumes")
+	convertRbr2rbtCmd.Flags().IntVar(&rehomeBatchSizeFlag, "rehome-batch-size", analyze.DefaultRehomeBatchSize,
+		"Number of rows each rehome batch walks")
 	convertRbr2rbtCmd.Flags().StringVarP(&primaryRegionFlag, "primary-region", "p", "", "primary region")
 	err := convertRbr2rbtCmd.MarkFlagRequired("primary-region")
 	if err != nil {
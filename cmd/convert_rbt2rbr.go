@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var rbt2rbrTablesFlag []string
+var rbt2rbrExcludeTablesFlag []string
+
+var convertRbt2rbrCmd = &cobra.Command{
+	Use:   "rbt2rbr",
+	Short: "Convert RBT to RBR tables",
+	Long: "Convert regional by table to regional by row, the reverse of rbr2rbt. Region columns kept as strings" +
+		" are changed back to the region type, FKs between regional by row tables are rewritten to include" +
+		" the region column and multi-region zone configurations are reset. Tables converted by rbr2rbt from" +
+		" REGIONAL BY ROW AS go back to their own region column, other tables use crdb_region. Use --tables and" +
+		" --exclude-tables to convert a few tables at a time.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		config := analyze.Rbt2rbrConfig{}
+		if len(rbt2rbrTablesFlag) > 0 || len(rbt2rbrExcludeTablesFlag) > 0 {
+			config.Filter, err = analyze.NewTableFilter(rbt2rbrTablesFlag, rbt2rbrExcludeTablesFlag, nil, 0, 0, "", 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		plan, err := converter.Rbt2rbrPlan(config)
+		if err != nil {
+			return err
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertRbt2rbrCmd)
+	convertRbt2rbrCmd.Flags().StringSliceVar(&rbt2rbrTablesFlag, "tables", nil,
+		"Only convert tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+	convertRbt2rbrCmd.Flags().StringSliceVar(&rbt2rbrExcludeTablesFlag, "exclude-tables", nil,
+		"Do not convert tables matching glob patterns, or regular expressions prefixed with 're:' (comma-separated)")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var toGlobalTablesFlag []string
+
+var convertToGlobalCmd = &cobra.Command{
+	Use:   "to-global",
+	Short: "Convert tables to GLOBAL",
+	Long: "Generates the statements that change the tables to LOCALITY GLOBAL, discarding zone configuration" +
+		" overrides on the tables, their indexes and partitions that conflict with GLOBAL placement.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		plan, err := converter.ToGlobalPlan(toGlobalTablesFlag)
+		if err != nil {
+			return err
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertToGlobalCmd)
+	convertToGlobalCmd.Flags().StringSliceVarP(&toGlobalTablesFlag, "tables", "t", nil, "Tables to convert")
+	err := convertToGlobalCmd.MarkFlagRequired("tables")
+	if err != nil {
+		panic(err)
+	}
+}
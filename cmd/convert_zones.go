@@ -0,0 +1,15 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var convertZonesCmd = &cobra.Command{
+	Use:   "zones",
+	Short: "Convert zone configurations",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	convertCmd.AddCommand(convertZonesCmd)
+}
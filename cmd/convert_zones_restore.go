@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var zonesRestoreFromFlag string
+
+var convertZonesRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore zone configurations from an export",
+	Long: "Generates the statements that bring the zone configurations of the database back to the state in a" +
+		" file written by analyze zones export. Changed targets are altered with only the fields that differ," +
+		" missing targets are recreated and overrides that were not in the export are discarded.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		export, err := analyze.ReadZoneConfigExport(zonesRestoreFromFlag)
+		if err != nil {
+			return err
+		}
+
+		converter, err := analyze.NewConverter(analyze.ConverterConfig{
+			DbUrl:    urlFlag,
+			Database: databaseFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		plan, err := converter.ZoneRestorePlan(export)
+		if err != nil {
+			return err
+		}
+
+		return writePlan(plan)
+	},
+}
+
+func init() {
+	convertZonesCmd.AddCommand(convertZonesRestoreCmd)
+	convertZonesRestoreCmd.Flags().StringVar(&zonesRestoreFromFlag, "from", "", "Export file to restore from")
+	err := convertZonesRestoreCmd.MarkFlagRequired("from")
+	if err != nil {
+		panic(err)
+	}
+}
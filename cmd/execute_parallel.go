@@ -9,6 +9,7 @@ var concurrencyFlag int
 var fileFlag string
 var preSqlFlag string
 var untilZeroRowsFlag bool
+var stateFileFlag string
 
 var executeParallelCmd = &cobra.Command{
 	Use:   "parallel",
@@ -22,6 +23,7 @@ var executeParallelCmd = &cobra.Command{
 			Concurrency:   concurrencyFlag,
 			PreSql:        preSqlFlag,
 			UntilZeroRows: untilZeroRowsFlag,
+			StateFile:     stateFileFlag,
 		})
 
 		if err != nil {
@@ -44,6 +46,8 @@ func init() {
 	executeParallelCmd.Flags().StringVarP(&fileFlag, "file", "f", "", "File containing SQL statements")
 	executeParallelCmd.Flags().StringVarP(&preSqlFlag, "pre-sql", "p", "", "Pre-SQL statement - runs before each statement")
 	executeParallelCmd.Flags().BoolVarP(&untilZeroRowsFlag, "until-zero-rows", "u", false, "Run SQL statements until zero rows are returned")
+	executeParallelCmd.Flags().StringVar(&stateFileFlag, "state-file", "",
+		"File recording completed blocks and the progress of keyset batch statements, to resume an interrupted execution")
 	err := executeParallelCmd.MarkFlagRequired("file")
 	if err != nil {
 		panic(err)
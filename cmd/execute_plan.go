@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/analyze"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var planConcurrencyFlag int
+var approveHashFlag string
+var allowDriftFlag bool
+
+var executePlanCmd = &cobra.Command{
+	Use:   "plan <dir>",
+	Short: "Execute a plan written with --out-dir",
+	Long: "Executes the phases of a plan written by convert --out-dir in manifest order. Phase files are checked" +
+		" against the manifest checksums first, and execution is refused when the schema changed since the plan" +
+		" was generated unless --allow-drift is set. The plan hash must be confirmed, either with --approve-hash" +
+		" or at the prompt. Blocks of a phase run in parallel, and execution stops after a phase with failed blocks." +
+		" Executed phases, completed blocks and the progress of keyset batch statements are saved to state.json in the plan" +
+		" directory, and an interrupted execution resumes from it, checking drift only for the remaining phases." +
+		" A rollback written with a conversion expects the state the conversion left.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		executor, err := analyze.NewExecutor(analyze.ExecutorConfig{
+			DbUrl:       urlFlag,
+			Database:    databaseFlag,
+			Concurrency: planConcurrencyFlag,
+			ApproveHash: approveHashFlag,
+			AllowDrift:  allowDriftFlag,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if approveHashFlag == "" {
+			hash, err := promptPlanHash(args[0])
+			if err != nil {
+				return err
+			}
+			executor.Config.ApproveHash = hash
+		}
+
+		return executor.ExecutePlan(args[0])
+	},
+}
+
+// promptPlanHash prints a summary of the plan in the directory and reads the plan hash confirmed by the operator
+func promptPlanHash(dir string) (string, error) {
+	manifest, err := analyze.ReadPlanManifest(dir)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "Plan %s for database %s, created %s\n", manifest.Name, manifest.Database,
+		manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	for _, phase := range manifest.Phases {
+		fmt.Fprintf(os.Stderr, "  %s: %d statements in %d steps\n", phase.Name, phase.Statements, phase.Steps)
+	}
+	fmt.Fprintf(os.Stderr, "Plan hash %s\n", manifest.PlanHash)
+	fmt.Fprintf(os.Stderr, "Enter the plan hash, or at least its first %d characters, to execute: ",
+		analyze.MinApproveHashLength)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("plan %s is not approved: %w", dir, err)
+	}
+	return line, nil
+}
+
+func init() {
+	executeCmd.AddCommand(executePlanCmd)
+	executePlanCmd.Flags().IntVarP(&planConcurrencyFlag, "concurrency", "c", 5, "Number of concurrent queries")
+	executePlanCmd.Flags().StringVar(&approveHashFlag, "approve-hash", "",
+		"Plan hash, or a prefix of it, to execute without prompting")
+	executePlanCmd.Flags().BoolVar(&allowDriftFlag, "allow-drift", false,
+		"Execute even when the schema changed since the plan was generated")
+}
@@ -0,0 +1,188 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AddRegionEstimate is the placement impact of adding a region on a table
+type AddRegionEstimate struct {
+	Table           Table
+	CurrentReplicas int
+	NewReplicas     int
+	// ExtraBytes is the additional logical bytes stored across all replicas
+	ExtraBytes uint64
+	// Blocking lists the zone overrides that keep the new region from getting replicas of the table
+	Blocking []string
+}
+
+func (e AddRegionEstimate) String() string {
+	s := fmt.Sprintf("Table: %s, Locality: %s, Logical Size: %s, Replicas: %d -> %d, Extra: %s",
+		e.Table.Name, e.Table.Locality, formatBytes(e.Table.LogicalSizeBytes), e.CurrentReplicas, e.NewReplicas,
+		formatBytes(e.ExtraBytes))
+	if len(e.Blocking) > 0 {
+		s += fmt.Sprintf(", Blocked: %s", strings.Join(e.Blocking, "; "))
+	}
+	return s
+}
+
+// AddRegionPlan generates the plan that adds the region to the database, with comments estimating the
+// replica count and extra bytes per table. Zone overrides that pin num_replicas to the multi-region default
+// are updated to the new default, other overrides that block the region are listed.
+func (c *Converter) AddRegionPlan(region string) (*Plan, error) {
+	regions, err := c.Analyzer.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	if !regions.IsMultiRegion() {
+		return nil, fmt.Errorf("database %s is not a multi-region database", c.Config.Database)
+	}
+	if regions.HasRegion(region) {
+		return nil, fmt.Errorf("region %s is already a region of database %s", region, c.Config.Database)
+	}
+
+	tables, err := c.Analyzer.Tables(true, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := c.Analyzer.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+
+	return addRegionPlan(regions, region, tables, zones), nil
+}
+
+func addRegionPlan(regions DatabaseRegions, region string, tables []Table, zones []ZoneConfig) *Plan {
+	plan := NewPlan("add-region", regions.Database)
+
+	added := regions
+	added.Regions = append(slices.Clone(regions.Regions), Region{Name: region})
+
+	localities := make(map[string]Locality)
+	for _, t := range tables {
+		localities[t.Name] = t.Locality
+	}
+
+	var totalExtra uint64
+	estimates := estimateAddRegion(regions, added, tables, zones)
+	for _, estimate := range estimates {
+		totalExtra += estimate.ExtraBytes
+		plan.Comment(estimate.String())
+	}
+	plan.Comment(fmt.Sprintf("Total extra logical bytes across replicas: %s", formatBytes(totalExtra)))
+
+	plan.AddPhase("add_region").AddBlock(nil, Statement{
+		Sql:    fmt.Sprintf("ALTER DATABASE %s ADD REGION %s", quoteIdentifier(regions.Database), quoteIdentifier(region)),
+		Target: regions.Database, Reason: fmt.Sprintf("add region %s", region), Risk: PlanRiskMedium,
+	})
+
+	// Overrides that match the current default would otherwise keep the old replica count
+	phase := plan.AddPhase("zoneconfig")
+	for _, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil || !zc.Has(ZoneFieldNumReplicas) {
+			continue
+		}
+		current := expectedZoneConfig(regions, target, localities)
+		if zc.NumReplicas != current.NumReplicas {
+			continue
+		}
+		updated := zc.Clone()
+		updated.NumReplicas = expectedZoneConfig(added, target, localities).NumReplicas
+		if sql, changed := ZoneConfigDiffSql(zc, updated); changed {
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "follow the new default num_replicas",
+				Risk: PlanRiskLow, Idempotent: true})
+		}
+	}
+
+	return plan
+}
+
+// estimateAddRegion compares the replica count of each table before and after the region is added. The
+// table's own zone configuration overrides the database zone configuration, which overrides the
+// multi-region default.
+func estimateAddRegion(regions DatabaseRegions, added DatabaseRegions, tables []Table, zones []ZoneConfig) []AddRegionEstimate {
+	var estimates []AddRegionEstimate
+
+	localities := make(map[string]Locality)
+	for _, t := range tables {
+		localities[t.Name] = t.Locality
+	}
+	var databaseZone *ZoneConfig
+	tableZones := make(map[string]ZoneConfig)
+	for i, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil {
+			continue
+		}
+		switch target.Type {
+		case ZoneTargetTypeDatabase:
+			databaseZone = &zones[i]
+		case ZoneTargetTypeTable:
+			tableZones[target.Table] = zc
+		}
+	}
+
+	for _, t := range tables {
+		target := ZoneTarget{Type: ZoneTargetTypeTable, Database: t.Database, Table: t.Name}
+		estimate := AddRegionEstimate{
+			Table:           t,
+			CurrentReplicas: expectedZoneConfig(regions, target, localities).NumReplicas,
+			NewReplicas:     expectedZoneConfig(added, target, localities).NumReplicas,
+		}
+
+		// Find the closest override of the number of replicas and the constraints
+		var overrides []ZoneConfig
+		if zc, ok := tableZones[t.Name]; ok {
+			overrides = append(overrides, zc)
+		}
+		if databaseZone != nil {
+			overrides = append(overrides, *databaseZone)
+		}
+		for _, zc := range overrides {
+			if zc.Has(ZoneFieldNumReplicas) {
+				if zc.NumReplicas != estimate.CurrentReplicas {
+					estimate.Blocking = append(estimate.Blocking,
+						fmt.Sprintf("%s sets num_replicas = %d", zc.Target, zc.NumReplicas))
+					estimate.NewReplicas = zc.NumReplicas
+				}
+				estimate.CurrentReplicas = zc.NumReplicas
+				break
+			}
+		}
+		for _, zc := range overrides {
+			if !zc.Has(ZoneFieldConstraints) {
+				continue
+			}
+			if constraintsPinReplicas(zc.Constraints, estimate.NewReplicas) {
+				estimate.Blocking = append(estimate.Blocking,
+					fmt.Sprintf("%s constrains every replica with %s", zc.Target, renderConstraints(zc.Constraints)))
+				estimate.NewReplicas = estimate.CurrentReplicas
+			}
+			break
+		}
+
+		if estimate.NewReplicas > estimate.CurrentReplicas {
+			estimate.ExtraBytes = t.LogicalSizeBytes * uint64(estimate.NewReplicas-estimate.CurrentReplicas)
+		}
+		estimates = append(estimates, estimate)
+	}
+	return estimates
+}
+
+// constraintsPinReplicas determines whether the constraints place every replica in existing regions,
+// either with a list of required regions or with per-replica constraints covering all replicas
+func constraintsPinReplicas(conjunctions []ZoneConfigConjunction, numReplicas int) bool {
+	if len(conjunctions) == 1 && conjunctions[0].NumReplicas == 0 {
+		return len(conjunctions[0].Regions()) > 0
+	}
+	pinned := 0
+	for _, conjunction := range conjunctions {
+		if len(conjunction.Regions()) > 0 {
+			pinned += conjunction.NumReplicas
+		}
+	}
+	return pinned > 0 && pinned >= numReplicas
+}
@@ -0,0 +1,47 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAddRegionPlan(t *testing.T) {
+	regions := DatabaseRegions{
+		Database:      "d",
+		PrimaryRegion: "us-east1",
+		Regions:       []Region{{Name: "us-east1", Primary: true}, {Name: "us-west1"}},
+		SurvivalGoal:  SurvivalGoalZone,
+	}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn},
+			LogicalSizeBytes: 1 << 30},
+		{Database: "d", Name: "pinned", Locality: Locality{Type: LocalityTypeRegionalByTable}, LogicalSizeBytes: 1 << 20},
+		{Database: "d", Name: "listed", Locality: Locality{Type: LocalityTypeRegionalByTable}, LogicalSizeBytes: 1 << 20},
+		{Database: "d", Name: "defaulted", Locality: Locality{Type: LocalityTypeRegionalByTable}, LogicalSizeBytes: 1 << 20},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "TABLE d.public.pinned", `num_replicas = 3`),
+		testZoneConfig(t, "TABLE d.public.listed", `constraints = '[+region=us-east1]'`),
+		testZoneConfig(t, "TABLE d.public.defaulted", `num_replicas = 4, gc.ttlseconds = 600`),
+	}
+
+	assert.Equal(t, []string{
+		"-- Table: users, Locality: REGIONAL BY ROW, Logical Size: 1.0 GB, Replicas: 4 -> 5, Extra: 1.0 GB",
+		"-- Table: pinned, Locality: REGIONAL BY TABLE IN PRIMARY REGION, Logical Size: 1.0 MB, Replicas: 3 -> 3, Extra: 0 B, " +
+			"Blocked: TABLE d.public.pinned sets num_replicas = 3",
+		"-- Table: listed, Locality: REGIONAL BY TABLE IN PRIMARY REGION, Logical Size: 1.0 MB, Replicas: 4 -> 4, Extra: 0 B, " +
+			"Blocked: TABLE d.public.listed constrains every replica with [+region=us-east1]",
+		"-- Table: defaulted, Locality: REGIONAL BY TABLE IN PRIMARY REGION, Logical Size: 1.0 MB, Replicas: 4 -> 5, Extra: 1.0 MB",
+		"-- Total extra logical bytes across replicas: 1.0 GB",
+		"-- FILE START add_region.sql",
+		ParallelSqlBlockBegin,
+		`ALTER DATABASE "d" ADD REGION "eu-west1";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zoneconfig.sql",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.defaulted CONFIGURE ZONE USING num_replicas = 5;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, addRegionPlan(regions, "eu-west1", tables, zones).Lines())
+}
@@ -2,8 +2,6 @@ package analyze
 
 import (
 	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
-	"slices"
-	"sort"
 )
 
 type Analyzer struct {
@@ -38,17 +36,14 @@ func (a *Analyzer) Fks(filter *FKFilter) ([]FKConstraint, error) {
 
 	for _, fk := range fks {
 		constraint := FKConstraint{
-			Name:                      fk.ConstraintName,
-			Table:                     fk.TableName,
-			Columns:                   fk.Columns,
-			ReferencedTable:           fk.ReferencedTable,
-			ReferencedColumns:         fk.ReferencedColumns,
-			UpdateRule:                Rule(fk.UpdateRule),
-			DeleteRule:                Rule(fk.DeleteRule),
-			RegionRestricted:          slices.Contains(fk.Columns, "crdb_region"),
-			ColumnsNoRegion:           removeString(fk.Columns, "crdb_region"),
-			ReferencedColumnsNoRegion: removeString(fk.ReferencedColumns, "crdb_region"),
-		}
+			Name:              fk.ConstraintName,
+			Table:             fk.TableName,
+			Columns:           fk.Columns,
+			ReferencedTable:   fk.ReferencedTable,
+			ReferencedColumns: fk.ReferencedColumns,
+			UpdateRule:        Rule(fk.UpdateRule),
+			DeleteRule:        Rule(fk.DeleteRule),
+		}.withRegionColumns(DefaultRegionColumn, DefaultRegionColumn)
 		if filter == nil || filter.Matches(constraint) {
 			constraints = append(constraints, constraint)
 		}
@@ -139,8 +134,14 @@ func (a *Analyzer) FKRedundants() ([]FKConstraint, error) {
 	return redundants, nil
 }
 
-// Tables returns tables for all databases
-func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
+// Tables returns tables for the database. If a filter is provided, only matching tables are returned,
+// ordered by the filter's sort key and limited to its top N. Sizes and FKs are loaded if the filter needs them.
+func (a *Analyzer) Tables(includeSize bool, includeFKs bool, filter *TableFilter) ([]Table, error) {
+
+	if filter != nil {
+		includeSize = includeSize || filter.needsSize()
+		includeFKs = includeFKs || filter.needsFKs()
+	}
 
 	var tables []Table
 	tmap := make(map[string]Table)
@@ -151,6 +152,9 @@ func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
 		return tables, err
 	}
 	for _, srow := range srows {
+		if ObjectKind(srow.Type) != ObjectKindTable {
+			continue
+		}
 		t := Table{}
 		if _, ok := tmap[srow.Name]; ok {
 			t = tmap[srow.Name]
@@ -159,7 +163,10 @@ func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
 		t.Database = a.Config.Database
 		t.Owner = srow.Owner
 		t.EstimatedRowCount = srow.EstimatedRowCount
-		t.Locality = srow.Locality
+		t.Locality, err = ParseLocality(srow.Locality)
+		if err != nil {
+			return tables, err
+		}
 		tmap[srow.Name] = t
 	}
 
@@ -170,13 +177,15 @@ func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
 			return tables, err
 		}
 		for _, row := range rows {
-			t := Table{}
-			if _, ok := tmap[row.Name]; ok {
-				t = tmap[row.Name]
+			// Sizes include materialized views, which are not tables
+			t, ok := tmap[row.Name]
+			if !ok {
+				continue
 			}
 			t.Database = row.Database
 			t.Name = row.Name
 			t.LogicalSizeBytes = row.LogicalBytes
+			t.RangeCount = row.RangeCount
 			tmap[row.Name] = t
 		}
 	}
@@ -188,6 +197,10 @@ func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
 			return tables, err
 		}
 		for _, fk := range fks {
+			// Tables using REGIONAL BY ROW AS include their own region column in region FKs
+			fk = fk.withRegionColumns(tmap[fk.Table].Locality.RegionColumnName(),
+				tmap[fk.ReferencedTable].Locality.RegionColumnName())
+
 			// FK defined on this table
 			if _, ok := tmap[fk.Table]; ok {
 				t := tmap[fk.Table]
@@ -209,19 +222,11 @@ func (a *Analyzer) Tables(includeSize bool, includeFKs bool) ([]Table, error) {
 		tables = append(tables, t)
 	}
 
-	// Sort tables
-	sort.Slice(tables, func(i, j int) bool {
-		// sort by logical size bytes desc, if present
-		if tables[i].LogicalSizeBytes > 0 || tables[j].LogicalSizeBytes > 0 {
-			return tables[i].LogicalSizeBytes > tables[j].LogicalSizeBytes
-		}
-		// sort by row count desc, if present
-		if tables[i].EstimatedRowCount > 0 || tables[j].EstimatedRowCount > 0 {
-			return tables[i].EstimatedRowCount > tables[j].EstimatedRowCount
-		}
-		// otherwise, sort by name asc
-		return tables[i].Name < tables[j].Name
-	})
+	// Filter and sort tables
+	if filter != nil {
+		return filter.Apply(tables), nil
+	}
+	sortTables(tables, TableSortDefault)
 
 	return tables, nil
 }
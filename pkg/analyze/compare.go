@@ -0,0 +1,262 @@
+package analyze
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SchemaDiffKind string
+
+const (
+	SchemaDiffKindTable    SchemaDiffKind = "table"
+	SchemaDiffKindLocality SchemaDiffKind = "locality"
+	SchemaDiffKindColumn   SchemaDiffKind = "column"
+	SchemaDiffKindIndex    SchemaDiffKind = "index"
+	SchemaDiffKindFK       SchemaDiffKind = "fk"
+	SchemaDiffKindZone     SchemaDiffKind = "zone"
+)
+
+// SchemaDiffChange describes how the right schema differs from the left
+type SchemaDiffChange string
+
+const (
+	SchemaDiffChangeAdded   SchemaDiffChange = "added"
+	SchemaDiffChangeRemoved SchemaDiffChange = "removed"
+	SchemaDiffChangeChanged SchemaDiffChange = "changed"
+)
+
+// SchemaDifference is a single object that differs between two schemas. Left and Right are the object's
+// definition on each side, empty when it does not exist there.
+type SchemaDifference struct {
+	Kind   SchemaDiffKind   `json:"kind"`
+	Object string           `json:"object"`
+	Change SchemaDiffChange `json:"change"`
+	Left   string           `json:"left,omitempty"`
+	Right  string           `json:"right,omitempty"`
+}
+
+// SchemaComparison is the result of comparing the schema of a left and a right database
+type SchemaComparison struct {
+	LeftDatabase  string             `json:"left_database"`
+	RightDatabase string             `json:"right_database"`
+	Differences   []SchemaDifference `json:"differences"`
+}
+
+func (d SchemaDifference) String() string {
+	switch d.Change {
+	case SchemaDiffChangeAdded:
+		return fmt.Sprintf("+ %s %s: %s", d.Kind, d.Object, d.Right)
+	case SchemaDiffChangeRemoved:
+		return fmt.Sprintf("- %s %s: %s", d.Kind, d.Object, d.Left)
+	default:
+		return fmt.Sprintf("~ %s %s: %s => %s", d.Kind, d.Object, d.Left, d.Right)
+	}
+}
+
+// CompareSchemas diffs the tables, localities, columns, indexes, FKs and zone configurations of two schemas.
+// Objects only in the right schema are added, objects only in the left schema are removed. Database names
+// are ignored so databases with different names can be compared.
+func CompareSchemas(left Schema, right Schema) SchemaComparison {
+	comparison := SchemaComparison{
+		LeftDatabase:  left.Database,
+		RightDatabase: right.Database,
+		Differences:   []SchemaDifference{},
+	}
+	add := func(differences []SchemaDifference) {
+		comparison.Differences = append(comparison.Differences, differences...)
+	}
+
+	leftTables := make(map[string]SchemaTable)
+	for _, t := range left.Tables {
+		leftTables[t.Name] = t
+	}
+	rightTables := make(map[string]SchemaTable)
+	for _, t := range right.Tables {
+		rightTables[t.Name] = t
+	}
+
+	// Tables that only exist on one side are reported as a whole, without their columns and indexes.
+	// Tables on both sides are compared object by object below.
+	for _, difference := range compareDefinitions(SchemaDiffKindTable, tableDefinitions(left.Tables), tableDefinitions(right.Tables)) {
+		if difference.Change != SchemaDiffChangeChanged {
+			add([]SchemaDifference{difference})
+		}
+	}
+
+	for _, name := range sortedKeys(leftTables) {
+		l := leftTables[name]
+		r, ok := rightTables[name]
+		if !ok {
+			continue
+		}
+		add(compareDefinitions(SchemaDiffKindLocality,
+			map[string]string{name: l.Locality.String()}, map[string]string{name: r.Locality.String()}))
+		add(compareDefinitions(SchemaDiffKindColumn, columnDefinitions(l), columnDefinitions(r)))
+		add(compareDefinitions(SchemaDiffKindIndex, indexDefinitions(l), indexDefinitions(r)))
+		add(compareDefinitions(SchemaDiffKindFK, fkDefinitions(l), fkDefinitions(r)))
+	}
+
+	add(compareDefinitions(SchemaDiffKindZone, zoneDefinitions(left.Zones), zoneDefinitions(right.Zones)))
+
+	return comparison
+}
+
+// Fingerprint is a SHA-256 of the definitions CompareSchemas compares, in object order. Two snapshots of a
+// catalog have the same fingerprint unless a table, locality, column, index, FK or zone configuration changed.
+func (s Schema) Fingerprint() string {
+	h := sha256.New()
+	write := func(kind SchemaDiffKind, definitions map[string]string) {
+		for _, name := range sortedKeys(definitions) {
+			fmt.Fprintf(h, "%s %s %s\n", kind, name, definitions[name])
+		}
+	}
+
+	tables := make(map[string]SchemaTable)
+	for _, t := range s.Tables {
+		tables[t.Name] = t
+	}
+	write(SchemaDiffKindTable, tableDefinitions(s.Tables))
+	for _, name := range sortedKeys(tables) {
+		write(SchemaDiffKindColumn, columnDefinitions(tables[name]))
+		write(SchemaDiffKindIndex, indexDefinitions(tables[name]))
+		write(SchemaDiffKindFK, fkDefinitions(tables[name]))
+	}
+	write(SchemaDiffKindZone, zoneDefinitions(s.Zones))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Relevant keeps the part of the schema that statements with the targets change: the localities, FKs and zone
+// configurations of the targeted tables and the targeted zone configurations. Targets are table names, zone
+// configuration targets or the database name, which stands for the database zone configuration.
+func (s Schema) Relevant(targets []string) Schema {
+	tables := make(map[string]bool)
+	zones := make(map[string]bool)
+	for _, target := range targets {
+		if _, err := ParseZoneTarget(target); err == nil {
+			key, _ := zoneKey(target)
+			zones[key] = true
+		} else if target == s.Database {
+			zones[string(ZoneTargetTypeDatabase)] = true
+		} else {
+			tables[target] = true
+		}
+	}
+
+	relevant := Schema{Database: s.Database}
+	for _, t := range s.Tables {
+		if tables[t.Name] {
+			relevant.Tables = append(relevant.Tables, SchemaTable{Name: t.Name, Locality: t.Locality, FKs: t.FKs})
+		}
+	}
+	for _, zc := range s.Zones {
+		if key, table := zoneKey(zc.Target); zones[key] || tables[table] {
+			relevant.Zones = append(relevant.Zones, zc)
+		}
+	}
+	return relevant
+}
+
+// compareDefinitions diffs two maps of object name to definition, in object name order
+func compareDefinitions(kind SchemaDiffKind, left map[string]string, right map[string]string) []SchemaDifference {
+	var differences []SchemaDifference
+
+	names := sortedKeys(left)
+	for name := range right {
+		if _, ok := left[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		l, inLeft := left[name]
+		r, inRight := right[name]
+		difference := SchemaDifference{Kind: kind, Object: name, Left: l, Right: r}
+		switch {
+		case !inLeft:
+			difference.Change = SchemaDiffChangeAdded
+		case !inRight:
+			difference.Change = SchemaDiffChangeRemoved
+		case l != r:
+			difference.Change = SchemaDiffChangeChanged
+		default:
+			continue
+		}
+		differences = append(differences, difference)
+	}
+	return differences
+}
+
+func tableDefinitions(tables []SchemaTable) map[string]string {
+	definitions := make(map[string]string)
+	for _, t := range tables {
+		definitions[t.Name] = t.Locality.String()
+	}
+	return definitions
+}
+
+func columnDefinitions(t SchemaTable) map[string]string {
+	definitions := make(map[string]string)
+	for _, c := range t.Columns {
+		definitions[fmt.Sprintf("%s.%s", t.Name, c.Name)] = c.String()
+	}
+	return definitions
+}
+
+func indexDefinitions(t SchemaTable) map[string]string {
+	definitions := make(map[string]string)
+	for _, index := range t.Indexes {
+		definitions[fmt.Sprintf("%s@%s", t.Name, index.Name)] = index.Definition
+	}
+	return definitions
+}
+
+func fkDefinitions(t SchemaTable) map[string]string {
+	definitions := make(map[string]string)
+	for _, fk := range t.FKs {
+		definitions[fmt.Sprintf("%s.%s", t.Name, fk.Name)] = fmt.Sprintf(
+			"FOREIGN KEY (%s) REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
+			strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "),
+			fk.UpdateRule, fk.DeleteRule)
+	}
+	return definitions
+}
+
+// zoneDefinitions keys zone configurations by their target without the database
+func zoneDefinitions(zones []ZoneConfig) map[string]string {
+	definitions := make(map[string]string)
+	for _, zc := range zones {
+		key, _ := zoneKey(zc.Target)
+		definitions[key] = strings.Join(zc.assignments(), ", ")
+	}
+	return definitions
+}
+
+// zoneKey is the zone configuration target without the database, along with the table of table, index and
+// partition targets
+func zoneKey(s string) (key string, table string) {
+	target, err := ParseZoneTarget(s)
+	if err != nil {
+		return s, ""
+	}
+	switch target.Type {
+	case ZoneTargetTypeDatabase:
+		return string(ZoneTargetTypeDatabase), ""
+	case ZoneTargetTypeRange:
+		return target.String(), ""
+	}
+	target.Database = ""
+	return target.String(), target.Table
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
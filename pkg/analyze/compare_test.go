@@ -0,0 +1,77 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	left := Schema{
+		Database: "staging",
+		Tables: []SchemaTable{
+			{
+				Name:     "orders",
+				Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn},
+				Columns: []Column{
+					{Name: "id", Type: "UUID"},
+					{Name: "note", Type: "STRING", Nullable: true},
+				},
+				Indexes: []Index{{Name: "orders_pkey", Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id ASC)"}},
+				FKs: []FKConstraint{{Name: "orders_user_fkey", Table: "orders", Columns: []string{"user_id"},
+					ReferencedTable: "users", ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction, DeleteRule: RuleCascade}},
+			},
+			{Name: "old", Locality: Locality{Type: LocalityTypeGlobal}},
+		},
+		Zones: []ZoneConfig{
+			{Target: "DATABASE staging", NumReplicas: 5, Fields: []string{ZoneFieldNumReplicas}},
+			{Target: "TABLE staging.public.orders", GcTtlSeconds: 600, Fields: []string{ZoneFieldGcTtlSeconds}},
+		},
+	}
+	right := Schema{
+		Database: "prod",
+		Tables: []SchemaTable{
+			{
+				Name:     "orders",
+				Locality: Locality{Type: LocalityTypeRegionalByTable},
+				Columns: []Column{
+					{Name: "id", Type: "UUID"},
+					{Name: "note", Type: "STRING"},
+					{Name: "total", Type: "DECIMAL", Nullable: true, Default: "0"},
+				},
+				Indexes: []Index{{Name: "orders_pkey", Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id ASC)"}},
+				FKs: []FKConstraint{{Name: "orders_user_fkey", Table: "orders", Columns: []string{"user_id"},
+					ReferencedTable: "users", ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction, DeleteRule: RuleNoAction}},
+			},
+		},
+		Zones: []ZoneConfig{
+			{Target: "DATABASE prod", NumReplicas: 5, Fields: []string{ZoneFieldNumReplicas}},
+		},
+	}
+
+	comparison := CompareSchemas(left, right)
+	assert.Equal(t, "staging", comparison.LeftDatabase)
+	assert.Equal(t, "prod", comparison.RightDatabase)
+
+	var lines []string
+	for _, difference := range comparison.Differences {
+		lines = append(lines, difference.String())
+	}
+	assert.Equal(t, []string{
+		"- table old: GLOBAL",
+		"~ locality orders: REGIONAL BY ROW => REGIONAL BY TABLE IN PRIMARY REGION",
+		"~ column orders.note: STRING => STRING NOT NULL",
+		"+ column orders.total: DECIMAL DEFAULT 0",
+		"~ fk orders.orders_user_fkey: FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE NO ACTION ON DELETE CASCADE => " +
+			"FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE NO ACTION ON DELETE NO ACTION",
+		`- zone TABLE "public"."orders": gc.ttlseconds = 600`,
+	}, lines)
+
+	assert.Empty(t, CompareSchemas(left, left).Differences)
+}
+
+func TestRemoveDatabaseFromIndexDefinition(t *testing.T) {
+	assert.Equal(t, "CREATE INDEX i ON public.t USING btree (a ASC)",
+		removeDatabaseFromIndexDefinition("CREATE INDEX i ON d.public.t USING btree (a ASC)", "d"))
+	assert.Equal(t, "CREATE INDEX i ON public.t USING btree (a ASC)",
+		removeDatabaseFromIndexDefinition(`CREATE INDEX i ON "my-db".public.t USING btree (a ASC)`, "my-db"))
+}
@@ -5,9 +5,6 @@ import (
 	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
 )
 
-const ParallelSqlBlockBegin = "-- BEGIN BLOCK"
-const ParallelSqlBlockEnd = "-- END BLOCK"
-
 type Converter struct {
 	Config   ConverterConfig
 	Db       *db.Db
@@ -42,42 +39,95 @@ func NewConverter(config ConverterConfig) (*Converter, error) {
 	}, nil
 }
 
-func (c *Converter) Rbr2rbtSqlStatements(primaryRegion string) ([]string, error) {
-
-	var statements []string
+// Rbr2rbtPlan generates the plan that converts regional by row tables to regional by table in the primary
+// region. Zone configurations move all data to the primary region first so FK constraint resolution is fast,
+// then region FKs are replaced, localities are changed, the region column becomes a string and table and
+// index zone configurations are discarded. Steps that are already done, such as by an interrupted run, are
+// left out and the plan starts with the progress of each phase. With a filter, only the selected tables and
+// their zone configurations are converted. With RehomeRows, the rows of each table are updated to the
+// primary region in keyset batches once the table is regional by table.
+func (c *Converter) Rbr2rbtPlan(config Rbr2rbtConfig) (*Plan, error) {
 
 	// Get all zone configurations
 	zoneConfigs, err := c.Analyzer.AllZoneConfigurations()
 	if err != nil {
-		return statements, err
+		return nil, err
 	}
 
-	// Iterate through zone configs. If the first lease preference is not for the primary region
-	// add a statement to alter the zone configuration to use the primary region
-	// The idea is that this will move all data to the primary region, making FK constraint resolution faster
-	statements = append(statements, "-- FILE START zoneconfig.sql")
-	for _, zc := range zoneConfigs {
-		if len(zc.LeasePreferences) > 0 && zc.LeasePreferences[0].Value != fmt.Sprintf("region=%s", primaryRegion) {
-			statements = append(statements, ParallelSqlBlockBegin)
-			//sql := fmt.Sprintf("ALTER %s CONFIGURE ZONE USING num_voters=%d, voter_constraints = '[+region=%s]', lease_preferences = '[[+region=%s]]'",
-			//	zc.Target, zc.NumVoters, primaryRegion, primaryRegion)
-			sql := fmt.Sprintf("ALTER %s CONFIGURE ZONE USING num_replicas=%d, num_voters=%d, constraints = '[+region=%s]', voter_constraints = '[+region=%s]', lease_preferences = '[[+region=%s]]'",
-				zc.Target, zc.NumVoters, zc.NumVoters, primaryRegion, primaryRegion, primaryRegion)
-			statements = append(statements, sql)
-			statements = append(statements, ParallelSqlBlockEnd)
-		}
+	// Get all tables
+	tables, err := c.Analyzer.Tables(false, true, nil)
+	if err != nil {
+		return nil, err
 	}
-	statements = append(statements, "-- FILE END")
 
-	// Get all tables
-	tables, err := c.Analyzer.Tables(false, true)
+	// Get columns to find region columns already changed to strings
+	columns, err := c.Analyzer.Columns()
 	if err != nil {
-		return statements, err
+		return nil, err
+	}
+
+	// Get indexes that must be recreated when the region column changes type
+	indexes, err := c.Analyzer.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get views so we can flag the ones that depend on tables being changed
+	views, err := c.Analyzer.Views(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	selection := selectRbr2rbtTables(tables, config)
+	plan := rbr2rbtPlan(c.Config.Database, config, selection.Zones(zoneConfigs), selection.Tables, tables,
+		columns, indexes, views)
+	plan.Comment(selection.Comments()...)
+	return plan, nil
+}
+
+func rbr2rbtPlan(database string, config Rbr2rbtConfig, zoneConfigs []ZoneConfig, tables []Table, allTables []Table,
+	columns map[string][]Column, indexes map[string][]Index, views []View) *Plan {
+	plan := NewPlan("rbr2rbt", database)
+	primaryRegion := config.PrimaryRegion
+
+	// Tables and indexes with a zone configuration still to discard
+	tableZones := make(map[string]bool)
+	indexZones := make(map[string][]string)
+
+	// Iterate through zone configs. If the first lease preference is not for the primary region, add a statement
+	// that moves replicas and leaseholders to the primary region, changing only the fields that differ.
+	// The idea is that this will move all data to the primary region, making FK constraint resolution faster
+	phase := plan.AddPhase("zoneconfig")
+	for _, zc := range zoneConfigs {
+		if target, err := zc.ParsedTarget(); err == nil {
+			switch target.Type {
+			case ZoneTargetTypeTable:
+				tableZones[target.Table] = true
+			case ZoneTargetTypeIndex:
+				indexZones[target.Table] = append(indexZones[target.Table], target.Index)
+			}
+		}
+		if len(zc.LeasePreferences) == 0 {
+			continue
+		}
+		if !leasesOutsidePrimaryRegion(zc, primaryRegion) {
+			phase.Completed++
+			continue
+		}
+		if sql, changed := ZoneConfigDiffSql(zc, primaryRegionZoneConfig(zc, primaryRegion)); changed {
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "move replicas and leaseholders to the primary region",
+				Risk: PlanRiskMedium, Idempotent: true})
+		}
 	}
 
 	// Iterate over tables, checking for FK constraints that need to be changed
-	statements = append(statements, "-- FILE START fk.sql")
+	phase = plan.AddPhase("fk")
 	for _, table := range tables {
+		// FKs named like replacements whose region FK has been dropped are done
+		if rbr2rbtConverting(table, columns[table.Name]) {
+			phase.Completed += replacedFKCount(table.FKs)
+		}
+
 		// Iterate over pairs of FKs to check for redundant ones
 		// If it is region restricted, check to see if it is redundant
 		// If it is redundant, drop it and don't add another one
@@ -86,125 +136,271 @@ func (c *Converter) Rbr2rbtSqlStatements(primaryRegion string) ([]string, error)
 		for i, fk1 := range table.FKs {
 
 			// Only region restricted FKs only need to be modified
-			if fk1.RegionRestricted {
-
-				var fkStatements []string
+			if !fk1.RegionRestricted {
+				continue
+			}
 
-				isRedundant := false
+			var comments []string
+			var fkStatements []Statement
 
-				// Iterate over other FKs
-				for j, fk2 := range table.FKs {
+			// A replacement added by a previous run is redundant too, but only the drop is left to do
+			isReplaced := isReplacementFK(table.FKs, fk1, fk1.GenerateNameNoRegion())
+			if isReplaced {
+				comments = append(comments, fmt.Sprintf("replacement %s already exists",
+					quoteIdentifier(fk1.GenerateNameNoRegion())))
+			}
 
-					// Skips if this is the same FK
-					if i == j {
-						continue
-					}
+			isRedundant := false
 
-					// If this is not redundant, add one without region to replace the original
-					if fk1.IsRedundantWith(fk2) {
-						skipSql := "-- not replacing since this is a redundant FK"
-						fkStatements = append(fkStatements, skipSql)
-						isRedundant = true
-						break
-					}
+			// Iterate over other FKs
+			for j, fk2 := range table.FKs {
 
+				// Skips if this is the same FK
+				if i == j || isReplaced {
+					continue
 				}
 
-				if !isRedundant {
-
-					// Build the SQL to add the constraint without a region
-					addSql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT IF NOT EXISTS %s FOREIGN KEY (%s) REFERENCES %s (%s)",
-						quoteIdentifierWithDatabase(c.Config.Database, fk1.Table),
-						quoteIdentifier(fk1.GenerateNameNoRegion()),
-						quoteAndJoinIdentifiers(fk1.ColumnsNoRegion),
-						quoteIdentifier(fk1.ReferencedTable),
-						quoteAndJoinIdentifiers(fk1.ReferencedColumnsNoRegion))
-					if fk1.UpdateRule != "" {
-						addSql = fmt.Sprintf("%s ON UPDATE %s", addSql, fk1.UpdateRule)
-					}
-					if fk1.DeleteRule != "" {
-						addSql = fmt.Sprintf("%s ON DELETE %s", addSql, fk1.DeleteRule)
-					}
-					fkStatements = append(fkStatements, addSql)
+				// If this is not redundant, add one without region to replace the original
+				if fk1.IsRedundantWith(fk2) {
+					comments = append(comments, "not replacing since this is a redundant FK")
+					isRedundant = true
+					break
 				}
 
-				// Always drop the
-				dropSql := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s",
-					quoteIdentifierWithDatabase(c.Config.Database, fk1.Table), quoteIdentifier(fk1.Name))
-				fkStatements = append(fkStatements, dropSql)
-
-				statements = append(statements, wrapSqlInBlock(fkStatements)...)
+			}
 
+			if !isRedundant && !isReplaced {
+
+				// Build the SQL to add the constraint without a region
+				addSql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT IF NOT EXISTS %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+					quoteIdentifierWithDatabase(database, fk1.Table),
+					quoteIdentifier(fk1.GenerateNameNoRegion()),
+					quoteAndJoinIdentifiers(fk1.ColumnsNoRegion),
+					quoteIdentifier(fk1.ReferencedTable),
+					quoteAndJoinIdentifiers(fk1.ReferencedColumnsNoRegion))
+				if fk1.UpdateRule != "" {
+					addSql = fmt.Sprintf("%s ON UPDATE %s", addSql, fk1.UpdateRule)
+				}
+				if fk1.DeleteRule != "" {
+					addSql = fmt.Sprintf("%s ON DELETE %s", addSql, fk1.DeleteRule)
+				}
+				fkStatements = append(fkStatements, Statement{Sql: addSql, Target: fk1.Table,
+					Reason: fmt.Sprintf("replace %s without the region", fk1.Name), Risk: PlanRiskMedium, Idempotent: true})
 			}
 
-		}
+			// Always drop the FK with the region
+			dropSql := fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s",
+				quoteIdentifierWithDatabase(database, fk1.Table), quoteIdentifier(fk1.Name))
+			fkStatements = append(fkStatements, Statement{Sql: dropSql, Target: fk1.Table,
+				Reason: "drop the FK that includes the region", Risk: PlanRiskHigh, Idempotent: true})
 
+			phase.AddBlock(comments, fkStatements...)
+		}
 	}
 
-	statements = append(statements, "-- FILE END")
-	statements = append(statements, "-- FILE START table_locality.sql")
-
 	// Iterate over tables again to change locality
+	phase = plan.AddPhase("table_locality")
 	for _, table := range tables {
+		// Only regional by row tables need to be converted
+		if !table.Locality.IsRegionalByRow() {
+			if rbr2rbtConverting(table, columns[table.Name]) {
+				phase.Completed++
+			}
+			continue
+		}
 		// Add SQL to alter the locality of the table
-		sql := fmt.Sprintf("ALTER TABLE \"%s\".\"%s\" SET LOCALITY REGIONAL BY TABLE IN PRIMARY REGION", table.Database, table.Name)
-		statements = append(statements, wrapSqlInBlock([]string{sql})...)
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s",
+			quoteIdentifierWithDatabase(table.Database, table.Name), Locality{Type: LocalityTypeRegionalByTable})
+		phase.AddBlock(viewDependencyComments(views, table), Statement{Sql: sql, Target: table.Name,
+			Reason: "convert to regional by table in the primary region", Risk: PlanRiskMedium, Idempotent: true})
 	}
 
-	statements = append(statements, "-- FILE END")
-
-	/*
-		// Next iterate over tables again to run update crdb_region to the primary region
-		// this allows us to remove the non-primary regions from the database
-		statements = append(statements, "-- FILE START update_crdb_region.sql")
-
-		// Iterate over tables again to change locality
+	// Update the region column of every row to the primary region, so non-primary regions can be removed from
+	// the database. Rows are walked in keyset batches after the locality change, when updating the region no
+	// longer moves rows between partitions.
+	if config.RehomeRows {
+		phase = plan.AddPhase("rehome_rows")
 		for _, table := range tables {
-			// Add SQL to alter the locality of the table
-			sql := fmt.Sprintf("UPDATE %s SET crdb_region = '%s' WHERE id IN ( SELECT id FROM %s WHERE crdb_region != '%s' LIMIT 100)",
-				quoteIdentifierWithDatabase(table.Database, table.Name), primaryRegion,
-				quoteIdentifierWithDatabase(table.Database, table.Name), primaryRegion,
-			)
-			statements = append(statements, wrapSqlInBlock([]string{sql})...)
+			if !rbr2rbtConverting(table, columns[table.Name]) {
+				continue
+			}
+			column, ok := rbr2rbtRegionColumn(table, columns[table.Name])
+			if !ok {
+				column = Column{Name: table.Locality.RegionColumnName(), Type: RegionColumnType}
+			}
+			comments, statement := rehomeRowsBlock(database, table.Name, column, "", primaryRegion, indexes[table.Name],
+				columns[table.Name], config.RehomeBatchSize)
+			if statement == nil {
+				phase.AddBlock(comments)
+				continue
+			}
+			phase.AddBlock(comments, *statement)
 		}
+	}
 
-		statements = append(statements, "-- FILE END")
+	// As an alternative to updating the region column to the primary region, change it to a string so
+	// non-primary regions can be removed from the database. Indexes on the column are dropped first and
+	// recreated afterwards, and a computed region column keeps its values but is no longer computed.
+	phase = plan.AddPhase("change_crdb_region_type")
+	fks := keptFKs(allTables, tables)
+	for _, table := range tables {
+		// Tables that were not regional by row do not have a region column to change
+		if !rbr2rbtConverting(table, columns[table.Name]) {
+			continue
+		}
+		column, ok := rbr2rbtRegionColumn(table, columns[table.Name])
+		if !ok {
+			column = Column{Name: table.Locality.RegionColumnName(), Type: RegionColumnType}
+		}
+		if !isRegionColumnType(column.Type) {
+			phase.Completed++
+			continue
+		}
 
-	*/
+		comments := viewDependencyComments(views, table)
+		for _, fk := range cascadeDroppedFKs(table.Name, indexes[table.Name], column.Name, fks) {
+			comments = append(comments, fmt.Sprintf("WARNING: %s of %s references a unique index on %s, which is "+
+				"dropped with CASCADE and drops the FK", quoteIdentifier(fk.Name), quoteIdentifier(fk.Table),
+				quoteIdentifier(column.Name)))
+		}
+		drops, creates, primary := regionIndexStatements(database, table.Name, indexes[table.Name], column.Name, true)
+		if primary != nil {
+			comments = append(comments, fmt.Sprintf("WARNING: primary key %s includes %s, change the primary key "+
+				"before changing the type of the region column", quoteIdentifier(primary.Name), quoteIdentifier(column.Name)))
+			phase.AddBlock(comments)
+			continue
+		}
 
-	// As an alternative to updated crdb_region to the primary region, just iterate over tables again to change
-	// the crdb_region column to a string
-	statements = append(statements, "-- FILE START change_crdb_region_type.sql")
+		name := quoteIdentifierWithDatabase(table.Database, table.Name)
+		columnName := quoteIdentifier(column.Name)
+		statements := drops
+		if column.Computed != "" {
+			comments = append(comments, fmt.Sprintf("WARNING: %s is no longer computed as %s, new rows must set it",
+				columnName, column.Computed))
+			statements = append(statements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP STORED", name, columnName),
+				Target: table.Name, Reason: "computed columns cannot change type", Risk: PlanRiskHigh})
+		}
+		statements = append(statements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE STRING", name, columnName),
+			Target: table.Name, Reason: "remove the dependency on the region type", Risk: PlanRiskMedium, Idempotent: true})
+		switch {
+		case column.Computed != "":
+		case column.Name == DefaultRegionColumn:
+			statements = append(statements, Statement{
+				Sql:    fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT default_to_database_primary_region(gateway_region())::STRING", name, columnName),
+				Target: table.Name, Reason: "keep the gateway region default as a string", Risk: PlanRiskLow, Idempotent: true})
+		case column.Default != "":
+			statements = append(statements, Statement{
+				Sql:    fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT (%s)::STRING", name, columnName, column.Default),
+				Target: table.Name, Reason: "keep the default as a string", Risk: PlanRiskLow, Idempotent: true})
+		}
+		phase.AddBlock(comments, append(statements, creates...)...)
+	}
 
-	// Iterate over tables again to change locality
+	// Discard zone overrides for all tables and their indexes - they will default to RBT. Partition zone
+	// configurations go away with the implicit partitioning of the regional by row table.
+	phase = plan.AddPhase("zone_config_discard")
 	for _, table := range tables {
-		// Add SQL to alter the locality of the table
-		var sqls []string
-		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN crdb_region SET DATA TYPE STRING",
-			quoteIdentifierWithDatabase(table.Database, table.Name)))
-		sqls = append(sqls,
-			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN crdb_region SET DEFAULT default_to_database_primary_region(gateway_region())::STRING",
-				quoteIdentifierWithDatabase(table.Database, table.Name)))
-		statements = append(statements, wrapSqlInBlock(sqls)...)
+		if !tableZones[table.Name] && len(indexZones[table.Name]) == 0 {
+			if rbr2rbtConverting(table, columns[table.Name]) {
+				phase.Completed++
+			}
+			continue
+		}
+		name := quoteIdentifierWithDatabase(table.Database, table.Name)
+		var statements []Statement
+		if tableZones[table.Name] {
+			statements = append(statements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s CONFIGURE ZONE DISCARD", name),
+				Target: table.Name, Reason: "inherit the regional by table zone configuration", Risk: PlanRiskLow, Idempotent: true})
+		}
+		for _, index := range indexZones[table.Name] {
+			statements = append(statements, Statement{
+				Sql:    fmt.Sprintf("ALTER INDEX %s@%s CONFIGURE ZONE DISCARD", name, quoteIdentifier(index)),
+				Target: table.Name, Reason: "inherit the regional by table zone configuration", Risk: PlanRiskLow, Idempotent: true})
+		}
+		phase.AddBlock(nil, statements...)
 	}
 
-	statements = append(statements, "-- FILE END")
+	plan.Comment(plan.Progress()...)
+	return plan
+}
 
-	// Discard zone overrides for all tables - they will default to RBT
-	statements = append(statements, "-- FILE START zone_config_discard.sql")
-	for _, table := range tables {
-		// Add SQL to alter the locality of the table
-		sql := fmt.Sprintf("ALTER TABLE %s CONFIGURE ZONE DISCARD",
-			quoteIdentifierWithDatabase(table.Database, table.Name))
-		statements = append(statements, wrapSqlInBlock([]string{sql})...)
+// rbr2rbtConverting determines whether the table is converted by rbr2rbt. Tables are regional by row until
+// their locality is changed, after which the region column is left on the regional by table table.
+func rbr2rbtConverting(table Table, columns []Column) bool {
+	if table.Locality.IsRegionalByRow() {
+		return true
 	}
+	_, ok := rbr2rbtRegionColumn(table, columns)
+	return ok
+}
 
-	statements = append(statements, "-- FILE END")
+// replacedFKCount is the number of FKs without the region named like a replacement that no region FK is
+// still waiting for
+func replacedFKCount(fks []FKConstraint) int {
+	count := 0
+	for _, fk := range fks {
+		if fk.RegionRestricted || fk.Name != fk.GenerateNameNoRegion() {
+			continue
+		}
+		pending := false
+		for _, other := range fks {
+			pending = pending || (other.RegionRestricted && other.GenerateNameNoRegion() == fk.Name)
+		}
+		if !pending {
+			count++
+		}
+	}
+	return count
+}
+
+// leasesOutsidePrimaryRegion determines whether the first lease preference of the zone configuration is for a
+// region other than the primary region. These are the zone configurations rbr2rbt moves to the primary region.
+func leasesOutsidePrimaryRegion(zc ZoneConfig, primaryRegion string) bool {
+	if len(zc.LeasePreferences) == 0 {
+		return false
+	}
+	region, ok := zc.LeaseRegion()
+	return !ok || region != primaryRegion
+}
 
-	return statements, nil
+// primaryRegionZoneConfig returns the zone configuration with all replicas, voters and leaseholders
+// in the primary region. Other settings are kept.
+func primaryRegionZoneConfig(zc ZoneConfig, primaryRegion string) ZoneConfig {
+	primary := ZoneConfigConjunction{Constraints: []ZoneConfigConstraint{
+		{Type: ZoneConfigConstraintTypeRequired, Key: "region", Value: primaryRegion},
+	}}
+
+	// When num_voters is not set, every replica is a voter
+	voters := zc.NumVoters
+	if voters == 0 {
+		voters = zc.NumReplicas
+	}
+
+	desired := zc.Clone()
+	if voters > 0 {
+		desired.NumReplicas = voters
+		desired.NumVoters = voters
+		desired.Set(ZoneFieldNumReplicas)
+		desired.Set(ZoneFieldNumVoters)
+	}
+	desired.Constraints = []ZoneConfigConjunction{primary}
+	desired.VoterConstraints = []ZoneConfigConjunction{primary}
+	desired.LeasePreferences = []ZoneConfigConjunction{primary}
+	desired.Set(ZoneFieldConstraints)
+	desired.Set(ZoneFieldVoterConstraints)
+	desired.Set(ZoneFieldLeasePreferences)
+	return desired
 }
 
-// wrapSqlInBlock wraps statements in a begin and end that can be used for parallel execution
-func wrapSqlInBlock(statements []string) []string {
-	return append([]string{ParallelSqlBlockBegin}, append(statements, ParallelSqlBlockEnd)...)
+// viewDependencyComments returns a warning comment for each view that depends on the table, since the
+// view may block or be affected by changes to the table
+func viewDependencyComments(views []View, table Table) []string {
+	var comments []string
+	for _, view := range views {
+		if view.DependsOnTable(table.Name) {
+			comments = append(comments, fmt.Sprintf("WARNING: %s %s depends on table %s",
+				view.Kind, quoteIdentifier(view.Name), quoteIdentifier(table.Name)))
+		}
+	}
+	return comments
 }
@@ -0,0 +1,125 @@
+package analyze
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RowDistributionConfig controls how regional by row tables are counted
+type RowDistributionConfig struct {
+	// AsOf is the AS OF SYSTEM TIME expression all tables are read at. When empty, the follower read
+	// timestamp at the start is used so every table is read at the same time.
+	AsOf string
+	// SampleRate is the fraction of rows counted, with counts scaled back up. Zero or one counts every row.
+	// Every row is still scanned, sampling only reduces the rows that are grouped.
+	SampleRate float64
+	// DominantShare is the share of rows in one region at or above which a table is flagged as an RBT candidate
+	DominantShare float64
+	Filter        *TableFilter
+}
+
+const DefaultDominantShare = 0.95
+
+// RowDistribution is the number of rows and estimated bytes per region of a regional by row table
+type RowDistribution struct {
+	Table   Table
+	AsOf    string
+	Regions []RegionRowCount
+	// DominantRegion is the region holding at least the dominant share of the rows, if any
+	DominantRegion string
+}
+
+func (d RowDistribution) String() string {
+	var regions []string
+	for _, r := range d.Regions {
+		regions = append(regions, fmt.Sprintf("%s: %d rows (%.1f%%, %s)", r.Region, r.Rows, 100*d.Share(r), formatBytes(r.Bytes)))
+	}
+	s := fmt.Sprintf("Table: %s, Rows: %d, Logical Size: %s, Regions: [%s]",
+		d.Table.Name, d.TotalRows(), formatBytes(d.Table.LogicalSizeBytes), strings.Join(regions, ", "))
+	if d.DominantRegion != "" {
+		s += fmt.Sprintf(", RBT candidate in %s", d.DominantRegion)
+	}
+	return s
+}
+
+// TotalRows is the number of rows across all regions
+func (d RowDistribution) TotalRows() int {
+	total := 0
+	for _, r := range d.Regions {
+		total += r.Rows
+	}
+	return total
+}
+
+// Share is the fraction of the table's rows in the region
+func (d RowDistribution) Share(r RegionRowCount) float64 {
+	total := d.TotalRows()
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Rows) / float64(total)
+}
+
+// RowDistributions counts the rows per region of every regional by row table, reading all tables at the
+// same timestamp
+func (a *Analyzer) RowDistributions(config RowDistributionConfig) ([]RowDistribution, error) {
+	var distributions []RowDistribution
+
+	if math.IsNaN(config.SampleRate) || config.SampleRate < 0 || config.SampleRate > 1 {
+		return distributions, fmt.Errorf("sample rate %g must be greater than 0 and at most 1", config.SampleRate)
+	}
+
+	asOf := config.AsOf
+	if asOf == "" {
+		ts, err := a.Db.FollowerReadTimestamp()
+		if err != nil {
+			return distributions, err
+		}
+		asOf = quoteZoneString(ts.UTC().Format("2006-01-02 15:04:05.999999"))
+	} else if !strings.HasPrefix(asOf, "'") && !strings.Contains(asOf, "(") {
+		// Intervals and timestamps are string literals, functions such as follower_read_timestamp() are not
+		asOf = quoteZoneString(asOf)
+	}
+
+	tables, err := a.Tables(true, false, config.Filter)
+	if err != nil {
+		return distributions, err
+	}
+	for _, t := range tables {
+		if !t.Locality.IsRegionalByRow() {
+			continue
+		}
+		rows, err := a.Db.CountRowsByValue(quoteIdentifierWithDatabase(t.Database, t.Name),
+			quoteIdentifier(t.Locality.RegionColumn), asOf, config.SampleRate)
+		if err != nil {
+			return distributions, fmt.Errorf("table %s: %w", t.Name, err)
+		}
+		counts := make(map[string]int)
+		for _, row := range rows {
+			counts[row.Value] = row.Rows
+		}
+		distributions = append(distributions, newRowDistribution(t, asOf, counts, config.SampleRate, config.DominantShare))
+	}
+	return distributions, nil
+}
+
+// newRowDistribution builds the distribution from the counted rows per region, scaling sampled counts
+// and estimating bytes from the table's share of rows
+func newRowDistribution(t Table, asOf string, counts map[string]int, sampleRate float64, dominantShare float64) RowDistribution {
+	d := RowDistribution{Table: t, AsOf: asOf}
+	for _, region := range sortedKeys(counts) {
+		rows := counts[region]
+		if sampleRate > 0 && sampleRate < 1 {
+			rows = int(math.Round(float64(rows) / sampleRate))
+		}
+		d.Regions = append(d.Regions, RegionRowCount{Table: t, Region: region, Rows: rows})
+	}
+	for i := range d.Regions {
+		d.Regions[i].Bytes = uint64(float64(t.LogicalSizeBytes) * d.Share(d.Regions[i]))
+		if d.TotalRows() > 0 && d.Share(d.Regions[i]) >= dominantShare {
+			d.DominantRegion = d.Regions[i].Region
+		}
+	}
+	return d
+}
@@ -0,0 +1,37 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewRowDistribution(t *testing.T) {
+	table := Table{Name: "users", LogicalSizeBytes: 1000}
+
+	d := newRowDistribution(table, "'-1m'", map[string]int{"us-west1": 2, "us-east1": 98}, 0, DefaultDominantShare)
+	assert.Equal(t, 100, d.TotalRows())
+	assert.Equal(t, "us-east1", d.DominantRegion)
+	assert.Equal(t, []RegionRowCount{
+		{Table: table, Region: "us-east1", Rows: 98, Bytes: 980},
+		{Table: table, Region: "us-west1", Rows: 2, Bytes: 20},
+	}, d.Regions)
+	assert.Equal(t, "Table: users, Rows: 100, Logical Size: 1000 B, Regions: [us-east1: 98 rows (98.0%, 980 B), "+
+		"us-west1: 2 rows (2.0%, 20 B)], RBT candidate in us-east1", d.String())
+
+	// Sampled counts are scaled back up
+	d = newRowDistribution(table, "'-1m'", map[string]int{"us-west1": 5, "us-east1": 5}, 0.1, DefaultDominantShare)
+	assert.Equal(t, 100, d.TotalRows())
+	assert.Equal(t, "", d.DominantRegion)
+
+	d = newRowDistribution(table, "'-1m'", map[string]int{}, 0, DefaultDominantShare)
+	assert.Equal(t, 0, d.TotalRows())
+	assert.Equal(t, "", d.DominantRegion)
+}
+
+func TestRowDistributionsSampleRate(t *testing.T) {
+	a := &Analyzer{Config: AnalyzerConfig{Database: "d"}}
+	for _, rate := range []float64{-0.5, 1.5} {
+		_, err := a.RowDistributions(RowDistributionConfig{SampleRate: rate})
+		assert.ErrorContains(t, err, "must be greater than 0 and at most 1")
+	}
+}
@@ -0,0 +1,225 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DomicileFinding is a table or partition whose replicas can be placed outside its allowed regions
+type DomicileFinding struct {
+	Table    string
+	Target   string
+	Severity ZoneLintSeverity
+	Message  string
+}
+
+// DomicileConfig limits the check to tables and partitions homed in the regions, or all when empty
+type DomicileConfig struct {
+	Regions []string
+}
+
+// defaultNumReplicas is the number of replicas of a range without a zone configuration that sets it
+const defaultNumReplicas = 3
+
+func (f DomicileFinding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Target, f.Message)
+}
+
+// CheckDataDomiciling verifies that replicas of regional by table tables and regional by row partitions can only
+// be placed in their allowed regions. The allowed regions are those of the super region containing the home
+// region, or only the home region when it is not in a super region.
+func (a *Analyzer) CheckDataDomiciling(config DomicileConfig) ([]DomicileFinding, error) {
+	regions, err := a.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	if !regions.IsMultiRegion() {
+		return nil, fmt.Errorf("database %s is not a multi-region database", a.Config.Database)
+	}
+	tables, err := a.Tables(false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := a.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	return checkDataDomiciling(config, regions, tables, zones), nil
+}
+
+func checkDataDomiciling(config DomicileConfig, regions DatabaseRegions, tables []Table, zones []ZoneConfig) []DomicileFinding {
+	var findings []DomicileFinding
+
+	checked := func(region string) bool {
+		return len(config.Regions) == 0 || slices.Contains(config.Regions, region)
+	}
+
+	var databaseZone ZoneConfig
+	tableZones := make(map[string]ZoneConfig)
+	indexZones := make(map[string]ZoneConfig)
+	partitionZones := make(map[string][]ZoneConfig)
+	partitionTargets := make(map[string]ZoneTarget)
+	for _, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil {
+			continue
+		}
+		switch target.Type {
+		case ZoneTargetTypeDatabase:
+			databaseZone = zc
+		case ZoneTargetTypeTable:
+			tableZones[target.Table] = zc
+		case ZoneTargetTypeIndex:
+			indexZones[target.Table+"@"+target.Index] = zc
+		case ZoneTargetTypePartition:
+			partitionZones[target.Table] = append(partitionZones[target.Table], zc)
+			partitionTargets[zc.Target] = target
+		}
+	}
+
+	for _, t := range tables {
+		tableZone := inheritZoneConfig(tableZones[t.Name], databaseZone)
+		if tableZone.Target == "" {
+			tableZone.Target = fmt.Sprintf("TABLE %s", quoteIdentifier(t.Name))
+		}
+
+		switch t.Locality.Type {
+		case LocalityTypeGlobal:
+			if len(regions.SuperRegions) > 0 || len(config.Regions) > 0 {
+				findings = append(findings, DomicileFinding{Table: t.Name, Target: tableZone.Target,
+					Severity: ZoneLintSeverityWarning, Message: "GLOBAL tables are replicated to every region"})
+			}
+		case LocalityTypeRegionalByTable:
+			home := t.Locality.HomeRegion(regions.PrimaryRegion)
+			if !checked(home) {
+				continue
+			}
+			allowed := domicileAllowedRegions(regions, home)
+			findings = append(findings, checkZonePlacement(t.Name, tableZone, allowed)...)
+			for key, zc := range indexZones {
+				if strings.HasPrefix(key, t.Name+"@") {
+					findings = append(findings, checkZonePlacement(t.Name, inheritZoneConfig(zc, tableZone), allowed)...)
+				}
+			}
+		case LocalityTypeRegionalByRow:
+			partitions := partitionZones[t.Name]
+			for _, region := range regions.RegionNames() {
+				if !checked(region) {
+					continue
+				}
+				found := false
+				for _, zc := range partitions {
+					target := partitionTargets[zc.Target]
+					if target.Partition != region {
+						continue
+					}
+					found = true
+					effective := inheritZoneConfig(inheritZoneConfig(zc, indexZones[t.Name+"@"+target.Index]), tableZone)
+					findings = append(findings, checkZonePlacement(t.Name, effective, domicileAllowedRegions(regions, region))...)
+				}
+				if !found {
+					findings = append(findings, DomicileFinding{Table: t.Name, Target: tableZone.Target,
+						Severity: ZoneLintSeverityError,
+						Message:  fmt.Sprintf("no zone configuration found for partition %s", region)})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Table != findings[j].Table {
+			return findings[i].Table < findings[j].Table
+		}
+		return findings[i].Target < findings[j].Target
+	})
+	return findings
+}
+
+// domicileAllowedRegions returns the regions of the super region containing the home region, or only the
+// home region when it is not in a super region
+func domicileAllowedRegions(regions DatabaseRegions, home string) []string {
+	if superRegion, ok := regions.SuperRegionFor(home); ok {
+		return superRegion.Regions
+	}
+	return []string{home}
+}
+
+// checkZonePlacement reports replicas that the constraints could place outside the allowed regions.
+// Voter constraints only choose among the replicas, but regions they name still hold a replica.
+func checkZonePlacement(table string, zc ZoneConfig, allowed []string) []DomicileFinding {
+	var findings []DomicileFinding
+	add := func(format string, args ...any) {
+		findings = append(findings, DomicileFinding{Table: table, Target: zc.Target, Severity: ZoneLintSeverityError,
+			Message: fmt.Sprintf(format, args...)})
+	}
+
+	numReplicas := zc.NumReplicas
+	if numReplicas == 0 {
+		numReplicas = defaultNumReplicas
+	}
+
+	var placed []string
+	for _, conjunctions := range [][]ZoneConfigConjunction{zc.Constraints, zc.VoterConstraints} {
+		for _, conjunction := range conjunctions {
+			for _, region := range conjunction.Regions() {
+				if !slices.Contains(placed, region) {
+					placed = append(placed, region)
+				}
+			}
+		}
+	}
+	for _, region := range placed {
+		if !slices.Contains(allowed, region) {
+			add("replicas are constrained to %s outside of allowed regions [%s]", region, strings.Join(allowed, ", "))
+		}
+	}
+
+	if unconstrained := unconstrainedReplicas(zc.Constraints, numReplicas); unconstrained > 0 {
+		add("%d of %d replicas are not constrained to a region and can be placed outside of allowed regions [%s]",
+			unconstrained, numReplicas, strings.Join(allowed, ", "))
+	}
+	return findings
+}
+
+// unconstrainedReplicas is the number of replicas that constraints do not require to be in a region
+func unconstrainedReplicas(conjunctions []ZoneConfigConjunction, numReplicas int) int {
+	constrained := 0
+	for _, conjunction := range conjunctions {
+		if len(conjunction.Regions()) == 0 {
+			continue
+		}
+		// A conjunction applying to all replicas constrains every replica
+		if conjunction.NumReplicas == 0 {
+			return 0
+		}
+		constrained += conjunction.NumReplicas
+	}
+	if constrained >= numReplicas {
+		return 0
+	}
+	return numReplicas - constrained
+}
+
+// inheritZoneConfig fills the placement fields that are not set in the zone configuration from its parent
+func inheritZoneConfig(zc ZoneConfig, parent ZoneConfig) ZoneConfig {
+	effective := zc.Clone()
+	if !effective.Has(ZoneFieldNumReplicas) && parent.Has(ZoneFieldNumReplicas) {
+		effective.NumReplicas = parent.NumReplicas
+		effective.Set(ZoneFieldNumReplicas)
+	}
+	if !effective.Has(ZoneFieldNumVoters) && parent.Has(ZoneFieldNumVoters) {
+		effective.NumVoters = parent.NumVoters
+		effective.Set(ZoneFieldNumVoters)
+	}
+	if !effective.Has(ZoneFieldConstraints) && parent.Has(ZoneFieldConstraints) {
+		effective.Constraints = cloneConjunctions(parent.Constraints)
+		effective.Set(ZoneFieldConstraints)
+	}
+	if !effective.Has(ZoneFieldVoterConstraints) && parent.Has(ZoneFieldVoterConstraints) {
+		effective.VoterConstraints = cloneConjunctions(parent.VoterConstraints)
+		effective.Set(ZoneFieldVoterConstraints)
+	}
+	return effective
+}
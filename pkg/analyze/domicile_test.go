@@ -0,0 +1,50 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckDataDomiciling(t *testing.T) {
+	regions := DatabaseRegions{
+		Database:        "d",
+		PrimaryRegion:   "eu-west1",
+		Regions:         []Region{{Name: "eu-west1", Primary: true}, {Name: "eu-central1"}, {Name: "us-east1"}},
+		PlacementPolicy: PlacementPolicyRestricted,
+		SuperRegions:    []SuperRegion{{Name: "eu", Regions: []string{"eu-central1", "eu-west1"}}},
+	}
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	tables := []Table{
+		{Name: "customers", Locality: rbr},
+		{Name: "invoices", Locality: Locality{Type: LocalityTypeRegionalByTable}},
+		{Name: "us_only", Locality: Locality{Type: LocalityTypeRegionalByTable, Region: "us-east1"}},
+		{Name: "countries", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", `num_replicas = 3, constraints = '[+region=eu-west1]'`),
+		testZoneConfig(t, `PARTITION "eu-west1" OF INDEX d.public.customers@customers_pkey`,
+			`num_replicas = 3, constraints = '{+region=eu-west1: 2, +region=eu-central1: 1}'`),
+		testZoneConfig(t, `PARTITION "eu-central1" OF INDEX d.public.customers@customers_pkey`,
+			`num_replicas = 4, constraints = '{+region=eu-central1: 3}'`),
+		testZoneConfig(t, `PARTITION "us-east1" OF INDEX d.public.customers@customers_pkey`,
+			`constraints = '[+region=us-east1]'`),
+		testZoneConfig(t, "TABLE d.public.invoices", `num_replicas = 3, voter_constraints = '[+region=us-east1]'`),
+		testZoneConfig(t, "TABLE d.public.us_only", `constraints = '[+region=us-east1]'`),
+	}
+
+	var lines []string
+	for _, finding := range checkDataDomiciling(DomicileConfig{}, regions, tables, zones) {
+		lines = append(lines, finding.String())
+	}
+	assert.Equal(t, []string{
+		"WARNING TABLE \"countries\": GLOBAL tables are replicated to every region",
+		`ERROR PARTITION "eu-central1" OF INDEX d.public.customers@customers_pkey: 1 of 4 replicas are not constrained ` +
+			`to a region and can be placed outside of allowed regions [eu-central1, eu-west1]`,
+		"ERROR TABLE d.public.invoices: replicas are constrained to us-east1 outside of allowed regions [eu-central1, eu-west1]",
+	}, lines)
+
+	// Only us-east1, where us_only and the us-east1 partition are constrained to their home region
+	findings := checkDataDomiciling(DomicileConfig{Regions: []string{"us-east1"}}, regions, tables, zones)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "countries", findings[0].Table)
+}
@@ -0,0 +1,276 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DropRegionBlockers is everything that prevents ALTER DATABASE ... DROP REGION from succeeding
+type DropRegionBlockers struct {
+	Region string
+	// Primary is true when the region is the primary region, which must be changed first
+	Primary bool
+	// RBTTables are regional by table tables homed in the region
+	RBTTables []Table
+	// RBRRows are regional by row tables with rows homed in the region
+	RBRRows []RegionRowCount
+	// Zones are zone configurations with constraints or lease preferences naming the region
+	Zones        []ZoneConfig
+	SuperRegions []SuperRegion
+}
+
+// RegionRowCount is the number of rows of a regional by row table homed in a region
+type RegionRowCount struct {
+	Table  Table
+	Region string
+	Rows   int
+	// Bytes is the estimated logical bytes of the rows, from the table size and its share of the rows
+	Bytes uint64
+}
+
+func (b DropRegionBlockers) String() string {
+	return fmt.Sprintf("Region: %s, Primary: %t, RBT Tables: %d, RBR Tables With Rows: %d, Zone Configs: %d, Super Regions: %d",
+		b.Region, b.Primary, len(b.RBTTables), len(b.RBRRows), len(b.Zones), len(b.SuperRegions))
+}
+
+func (r RegionRowCount) String() string {
+	return fmt.Sprintf("Table: %s, Region: %s, Rows: %d", r.Table.Name, r.Region, r.Rows)
+}
+
+// IsEmpty is true when nothing blocks dropping the region
+func (b DropRegionBlockers) IsEmpty() bool {
+	return !b.Primary && len(b.RBTTables) == 0 && len(b.RBRRows) == 0 && len(b.Zones) == 0 && len(b.SuperRegions) == 0
+}
+
+// DropRegionBlockers finds the tables, rows, zone configurations and super regions that block dropping the region
+func (a *Analyzer) DropRegionBlockers(regions DatabaseRegions, region string) (DropRegionBlockers, error) {
+	blockers := DropRegionBlockers{Region: region, Primary: regions.PrimaryRegion == region}
+
+	if !regions.HasRegion(region) {
+		return blockers, fmt.Errorf("region %s is not a region of database %s", region, regions.Database)
+	}
+
+	tables, err := a.Tables(false, false, nil)
+	if err != nil {
+		return blockers, err
+	}
+	for _, t := range tables {
+		switch {
+		case t.Locality.Type == LocalityTypeRegionalByTable && t.Locality.HomeRegion(regions.PrimaryRegion) == region:
+			blockers.RBTTables = append(blockers.RBTTables, t)
+		case t.Locality.IsRegionalByRow():
+			rows, err := a.Db.CountRowsWithValue(quoteIdentifierWithDatabase(t.Database, t.Name),
+				quoteIdentifier(t.Locality.RegionColumn), region)
+			if err != nil {
+				return blockers, err
+			}
+			if rows > 0 {
+				blockers.RBRRows = append(blockers.RBRRows, RegionRowCount{Table: t, Region: region, Rows: rows})
+			}
+		}
+	}
+
+	zones, err := a.AllZoneConfigurations()
+	if err != nil {
+		return blockers, err
+	}
+	for _, zc := range zones {
+		if slices.Contains(zoneConfigRegions(zc), region) {
+			blockers.Zones = append(blockers.Zones, zc)
+		}
+	}
+
+	for _, superRegion := range regions.SuperRegions {
+		if slices.Contains(superRegion.Regions, region) {
+			blockers.SuperRegions = append(blockers.SuperRegions, superRegion)
+		}
+	}
+
+	return blockers, nil
+}
+
+// DropRegionPlan generates an ordered plan that removes everything blocking the region from being dropped
+// and then drops it. Rows and tables homed in the region are moved to the target region. When the region is
+// the primary region, newPrimaryRegion becomes the primary region first and is the default target.
+func (c *Converter) DropRegionPlan(region string, targetRegion string, newPrimaryRegion string,
+	rehomeBatchSize int) (*Plan, error) {
+	regions, err := c.Analyzer.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	blockers, err := c.Analyzer.DropRegionBlockers(regions, region)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.Analyzer.Columns()
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := c.Analyzer.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	return dropRegionPlan(regions, blockers, targetRegion, newPrimaryRegion, columns, indexes, rehomeBatchSize)
+}
+
+func dropRegionPlan(regions DatabaseRegions, blockers DropRegionBlockers, targetRegion string,
+	newPrimaryRegion string, columns map[string][]Column, indexes map[string][]Index, rehomeBatchSize int) (*Plan, error) {
+	region := blockers.Region
+
+	primaryRegion := regions.PrimaryRegion
+	if blockers.Primary {
+		if newPrimaryRegion == "" {
+			return nil, fmt.Errorf("%s is the primary region, a new primary region is required", region)
+		}
+		primaryRegion = newPrimaryRegion
+	}
+	if targetRegion == "" {
+		targetRegion = primaryRegion
+	}
+	for _, r := range []string{targetRegion, primaryRegion} {
+		if r == region || !regions.HasRegion(r) {
+			return nil, fmt.Errorf("region %s cannot replace %s in database %s", r, region, regions.Database)
+		}
+	}
+	database := quoteIdentifier(regions.Database)
+	plan := NewPlan("drop-region", regions.Database)
+	plan.Comment(dropRegionSummary(blockers)...)
+
+	// Tables in the primary region follow it, so the primary region moves before anything else
+	if blockers.Primary {
+		plan.AddPhase("primary_region").AddBlock(nil, Statement{
+			Sql:    fmt.Sprintf("ALTER DATABASE %s SET PRIMARY REGION %s", database, quoteIdentifier(primaryRegion)),
+			Target: regions.Database, Reason: fmt.Sprintf("%s is the primary region", region),
+			Risk: PlanRiskMedium, Idempotent: true,
+		})
+	}
+
+	// Rows are moved in keyset batches so no single statement rewrites the whole table. Computed region
+	// columns cannot be updated and are left with a warning.
+	phase := plan.AddPhase("rehome_rows")
+	for _, count := range blockers.RBRRows {
+		t := count.Table
+		column, ok := findColumn(columns[t.Name], t.Locality.RegionColumnName())
+		if !ok {
+			column = Column{Name: t.Locality.RegionColumnName(), Type: RegionColumnType}
+		}
+		comments, statement := rehomeRowsBlock(regions.Database, t.Name, column, region, targetRegion,
+			indexes[t.Name], columns[t.Name], rehomeBatchSize)
+		comments = append([]string{fmt.Sprintf("%s has %d rows in %s", quoteIdentifier(t.Name), count.Rows, region)},
+			comments...)
+		if statement == nil {
+			phase.AddBlock(comments)
+			continue
+		}
+		phase.AddBlock(comments, *statement)
+	}
+
+	phase = plan.AddPhase("table_locality")
+	for _, t := range blockers.RBTTables {
+		// Tables in the primary region have already moved with it
+		if t.Locality.Region == "" {
+			continue
+		}
+		locality := Locality{Type: LocalityTypeRegionalByTable, Region: targetRegion}
+		if targetRegion == primaryRegion {
+			locality.Region = ""
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s", quoteIdentifierWithDatabase(t.Database, t.Name), locality)
+		phase.AddBlock(nil, Statement{Sql: sql, Target: t.Name, Reason: fmt.Sprintf("homed in %s", region),
+			Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	phase = plan.AddPhase("zoneconfig")
+	for _, zc := range blockers.Zones {
+		if sql, changed := ZoneConfigDiffSql(zc, zoneConfigWithoutRegion(zc, region, targetRegion)); changed {
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: fmt.Sprintf("names %s", region),
+				Risk: PlanRiskMedium, Idempotent: true})
+		}
+	}
+
+	phase = plan.AddPhase("super_regions")
+	for _, superRegion := range blockers.SuperRegions {
+		remaining := removeString(slices.Clone(superRegion.Regions), region)
+		var sql string
+		if len(remaining) == 0 {
+			sql = fmt.Sprintf("ALTER DATABASE %s DROP SUPER REGION %s", database, quoteIdentifier(superRegion.Name))
+		} else {
+			sql = fmt.Sprintf("ALTER DATABASE %s ALTER SUPER REGION %s VALUES %s",
+				database, quoteIdentifier(superRegion.Name), quoteAndJoinIdentifiers(remaining))
+		}
+		phase.AddBlock(nil, Statement{Sql: sql, Target: superRegion.Name, Reason: fmt.Sprintf("contains %s", region),
+			Risk: PlanRiskMedium})
+	}
+
+	plan.AddPhase("drop_region").AddBlock(nil, Statement{
+		Sql:    fmt.Sprintf("ALTER DATABASE %s DROP REGION %s", database, quoteIdentifier(region)),
+		Target: regions.Database, Reason: fmt.Sprintf("drop region %s", region), Risk: PlanRiskHigh,
+	})
+
+	return plan, nil
+}
+
+// zoneConfigWithoutRegion returns the zone configuration with the region removed. Constraints that apply to
+// all replicas move to the target region, per-replica constraints and lease preferences for the region are
+// removed and prohibitions of the region are dropped. When no lease preference is left, the target is preferred.
+func zoneConfigWithoutRegion(zc ZoneConfig, region string, targetRegion string) ZoneConfig {
+	fixed := zc.Clone()
+	fixed.Constraints = conjunctionsWithoutRegion(fixed.Constraints, region, targetRegion)
+	fixed.VoterConstraints = conjunctionsWithoutRegion(fixed.VoterConstraints, region, targetRegion)
+
+	var preferences []ZoneConfigConjunction
+	for _, preference := range fixed.LeasePreferences {
+		if !slices.Contains(preference.Regions(), region) {
+			preferences = append(preferences, preference)
+		}
+	}
+	if len(preferences) == 0 && len(fixed.LeasePreferences) > 0 {
+		preferences = []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{
+			{Type: ZoneConfigConstraintTypeRequired, Key: "region", Value: targetRegion},
+		}}}
+	}
+	fixed.LeasePreferences = preferences
+	return fixed
+}
+
+func conjunctionsWithoutRegion(conjunctions []ZoneConfigConjunction, region string, targetRegion string) []ZoneConfigConjunction {
+	var result []ZoneConfigConjunction
+	for _, conjunction := range conjunctions {
+		if conjunction.NumReplicas > 0 && slices.Contains(conjunction.Regions(), region) {
+			continue
+		}
+		var constraints []ZoneConfigConstraint
+		for _, constraint := range conjunction.Constraints {
+			if r, ok := constraint.Region(); ok && r == region {
+				if constraint.Type == ZoneConfigConstraintTypeProhibited {
+					continue
+				}
+				constraint.Value = targetRegion
+			}
+			constraints = append(constraints, constraint)
+		}
+		if len(constraints) > 0 {
+			result = append(result, ZoneConfigConjunction{Constraints: constraints, NumReplicas: conjunction.NumReplicas})
+		}
+	}
+	return result
+}
+
+// dropRegionSummary describes the blockers as comments for the top of the plan
+func dropRegionSummary(blockers DropRegionBlockers) []string {
+	var lines []string
+	lines = append(lines, blockers.String())
+	for _, t := range blockers.RBTTables {
+		lines = append(lines, fmt.Sprintf("RBT table %s is homed in %s", quoteIdentifier(t.Name), blockers.Region))
+	}
+	for _, count := range blockers.RBRRows {
+		lines = append(lines, count.String())
+	}
+	for _, zc := range blockers.Zones {
+		lines = append(lines, fmt.Sprintf("zone config %s names %s", zc.Target, blockers.Region))
+	}
+	for _, superRegion := range blockers.SuperRegions {
+		lines = append(lines, superRegion.String())
+	}
+	return lines
+}
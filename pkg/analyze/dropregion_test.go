@@ -0,0 +1,105 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDropRegionPlan(t *testing.T) {
+	regions := DatabaseRegions{
+		Database:      "d",
+		PrimaryRegion: "us-east1",
+		Regions:       []Region{{Name: "us-east1", Primary: true}, {Name: "us-west1"}, {Name: "eu-west1"}},
+		SuperRegions:  []SuperRegion{{Name: "us", Regions: []string{"us-east1", "us-west1"}}},
+	}
+	zone := testZoneConfig(t, "TABLE d.public.orders",
+		`num_replicas = 5, constraints = '{+region=us-west1: 1, +region=eu-west1: 1}', `+
+			`voter_constraints = '[+region=us-west1]', lease_preferences = '[[+region=us-west1], [+region=eu-west1]]'`)
+	blockers := DropRegionBlockers{
+		Region: "us-west1",
+		RBTTables: []Table{
+			{Database: "d", Name: "west", Locality: Locality{Type: LocalityTypeRegionalByTable, Region: "us-west1"}},
+		},
+		RBRRows: []RegionRowCount{{
+			Table:  Table{Database: "d", Name: "users", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+			Region: "us-west1",
+			Rows:   42,
+		}, {
+			Table:  Table{Database: "d", Name: "accounts", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}},
+			Region: "us-west1",
+			Rows:   7,
+		}},
+		Zones:        []ZoneConfig{zone},
+		SuperRegions: regions.SuperRegions,
+	}
+
+	columns := map[string][]Column{
+		"users": {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"accounts": {{Name: "id", Type: "INT8"}, {Name: "region", Type: RegionColumnType,
+			Computed: "CASE WHEN id > 0 THEN 'us-east1' ELSE 'us-west1' END"}},
+	}
+	indexes := map[string][]Index{
+		"users": {{Name: "users_pkey", Primary: true,
+			Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id ASC)"}},
+	}
+
+	plan, err := dropRegionPlan(regions, blockers, "", "", columns, indexes, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-- Region: us-west1, Primary: false, RBT Tables: 1, RBR Tables With Rows: 2, Zone Configs: 1, Super Regions: 1",
+		`-- RBT table "west" is homed in us-west1`,
+		"-- Table: users, Region: us-west1, Rows: 42",
+		"-- Table: accounts, Region: us-west1, Rows: 7",
+		"-- zone config TABLE d.public.orders names us-west1",
+		"-- Super Region: us, Regions: [us-east1, us-west1]",
+		"-- FILE START rehome_rows.sql",
+		`-- "users" has 42 rows in us-west1`,
+		ParallelSqlBlockBegin,
+		KeysetBatchDirective,
+		`WITH batch AS (SELECT "id" FROM "d"."users" WHERE $1::STRING IS NULL OR ("id") > ($1::STRING::INT8) ` +
+			`ORDER BY "id" LIMIT 100), rehomed AS (UPDATE "d"."users" SET "crdb_region" = 'us-east1' ` +
+			`WHERE ("id") IN (SELECT "id" FROM batch) AND "crdb_region" = 'us-west1' RETURNING 1) ` +
+			`SELECT "id"::STRING, (SELECT count(*) FROM rehomed) FROM batch ORDER BY "id" DESC LIMIT 1;`,
+		ParallelSqlBlockEnd,
+		`-- "accounts" has 7 rows in us-west1`,
+		`-- WARNING: "region" is computed as CASE WHEN id > 0 THEN 'us-east1' ELSE 'us-west1' END and cannot be rehomed`,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."west" SET LOCALITY REGIONAL BY TABLE IN PRIMARY REGION;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zoneconfig.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE d.public.orders CONFIGURE ZONE USING constraints = '{+region=eu-west1: 1}', ` +
+			`voter_constraints = '[+region=us-east1]', lease_preferences = '[[+region=eu-west1]]';`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START super_regions.sql",
+		ParallelSqlBlockBegin,
+		`ALTER DATABASE "d" ALTER SUPER REGION "us" VALUES "us-east1";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START drop_region.sql",
+		ParallelSqlBlockBegin,
+		`ALTER DATABASE "d" DROP REGION "us-west1";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, plan.Lines())
+
+	// The primary region can only be dropped once another region is primary
+	blockers = DropRegionBlockers{Region: "us-east1", Primary: true}
+	_, err = dropRegionPlan(regions, blockers, "", "", nil, nil, 0)
+	assert.Error(t, err)
+	_, err = dropRegionPlan(regions, blockers, "", "ap-south1", nil, nil, 0)
+	assert.Error(t, err)
+
+	plan, err = dropRegionPlan(regions, blockers, "", "us-west1", nil, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER DATABASE "d" SET PRIMARY REGION "us-west1";`,
+		ParallelSqlBlockEnd,
+	}, plan.Lines()[2:5])
+}
@@ -5,14 +5,27 @@ import (
 	"fmt"
 	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
 	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Executor struct {
 	Config ExecutorConfig
 	Db     *db.Db
+	// State is the progress of the execution, read from and saved to StateFile or, for a plan, to the state
+	// file in the plan directory
+	State *ExecutionState
+	// schema loads the current schema of the database
+	schema func() (Schema, error)
+	// execute runs the statements of a block on one connection
+	execute func(statements []string) error
+	// mu serializes recording the state left by completed blocks, so a block never records an older schema
+	// than a block recorded before it
+	mu sync.Mutex
 }
 
 type ExecutorConfig struct {
@@ -21,6 +34,13 @@ type ExecutorConfig struct {
 	Concurrency   int
 	PreSql        string
 	UntilZeroRows bool
+	// ApproveHash is the plan hash, or a prefix of it, confirmed by the operator for ExecutePlan
+	ApproveHash string
+	// AllowDrift executes a plan even when the schema changed since the plan was generated
+	AllowDrift bool
+	// StateFile records completed blocks and the progress of keyset batch statements so an interrupted
+	// execution resumes, progress is not saved when empty. Plans keep their progress in the plan directory instead.
+	StateFile string
 }
 
 func NewExecutor(config ExecutorConfig) (*Executor, error) {
@@ -28,10 +48,22 @@ func NewExecutor(config ExecutorConfig) (*Executor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Executor{
+	state, err := ReadExecutionState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	e := &Executor{
 		Config: config,
 		Db:     d,
-	}, nil
+		State:  state,
+	}
+	// The schema is read from the executor connections, since follower reads would miss the latest changes
+	e.schema = func() (Schema, error) {
+		analyzer := &Analyzer{Config: AnalyzerConfig{DbUrl: config.DbUrl, Database: config.Database}, Db: d}
+		return analyzer.Schema()
+	}
+	e.execute = e.executeSQLStatements
+	return e, nil
 }
 
 func (e *Executor) ExecuteFromFile(filePath string) error {
@@ -42,32 +74,240 @@ func (e *Executor) ExecuteFromFile(filePath string) error {
 		return err
 	}
 
-	sqlChan := make(chan []string, len(statementBlocks))
-	var wg sync.WaitGroup
+	start := time.Now()
+	e.executeBlocks("", statementBlocks, func(i int, block []string) error {
+		return e.State.CompleteBlock("", i, block, nil)
+	})
+	logrus.Infof("Completed executing all SQL in %s", time.Since(start))
+	return nil
+}
+
+// PlanCheck is the state of a plan written to a directory compared with the database it runs against
+type PlanCheck struct {
+	Manifest *PlanManifest
+	// State is the execution state of the plan, with the phases already executed
+	State *ExecutionState
+	// Fingerprint is the current fingerprint of the part of the schema the remaining phases change
+	Fingerprint string
+	// Expected is the fingerprint the remaining phases expect, empty when it is not known
+	Expected string
+	// Differences are the schema changes the remaining phases do not expect, when the expected state is available
+	Differences []SchemaDifference
+}
+
+// Drifted determines whether the part of the schema the remaining phases change is not in the state they expect
+func (c PlanCheck) Drifted() bool {
+	return c.Expected == "" || c.Fingerprint != c.Expected
+}
+
+// CheckPlan reads and verifies the plan written to the directory and compares the part of the schema the
+// remaining phases change with the state they expect. Before any phase runs, that is the state the plan was
+// generated from or, for a plan that runs after another one, the state recorded by that plan. Afterwards, it
+// is the state recorded after the last completed block.
+func (e *Executor) CheckPlan(dir string) (*PlanCheck, error) {
+	manifest, err := ReadPlanManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Database != e.Config.Database {
+		return nil, fmt.Errorf("plan %s was generated for database %s, not %s", dir, manifest.Database, e.Config.Database)
+	}
+	if err := manifest.Verify(dir); err != nil {
+		return nil, err
+	}
+	state, err := ReadExecutionState(filepath.Join(dir, ExecutionStateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := e.schema()
+	if err != nil {
+		return nil, err
+	}
+	targets := manifest.Targets(state.Phases)
+	relevant := current.Relevant(targets)
+	check := &PlanCheck{Manifest: manifest, State: state, Fingerprint: relevant.Fingerprint()}
+
+	var expected *Schema
+	switch {
+	case state.Expected != nil:
+		recorded := state.Expected.Relevant(targets)
+		expected = &recorded
+		check.Expected = recorded.Fingerprint()
+	case len(state.Phases) == 0 && manifest.RunsAfter == "":
+		check.Expected = manifest.CatalogFingerprint
+		snapshot, err := ReadPlanSchema(dir)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			generated := snapshot.Relevant(targets)
+			expected = &generated
+		}
+	}
+	if check.Drifted() && expected != nil {
+		check.Differences = CompareSchemas(*expected, relevant).Differences
+	}
+	return check, nil
+}
+
+// ExecutePlan executes the phases of a plan written to the directory in manifest order. Blocks of a phase
+// run in parallel, and a phase only starts once every block of the previous phase succeeded. Phase files
+// are checked against the manifest checksums and the schema against the state the remaining phases expect
+// before anything runs, and the plan hash must be approved. Executed phases are recorded in the plan
+// directory and skipped when the execution is resumed.
+func (e *Executor) ExecutePlan(dir string) error {
+	check, err := e.CheckPlan(dir)
+	if err != nil {
+		return err
+	}
+	manifest := check.Manifest
+	if check.Drifted() {
+		for _, difference := range check.Differences {
+			logrus.Warnln(difference)
+		}
+	}
+	if check.Drifted() && !e.Config.AllowDrift {
+		if check.Expected == "" && manifest.RunsAfter != "" {
+			return fmt.Errorf("plan %s runs after the plan in %s, which has not executed any phase", dir,
+				filepath.Join(dir, manifest.RunsAfter))
+		}
+		// The expected state is recorded as blocks complete, so changes made by a block interrupted partway
+		// through cannot be told from drift
+		if check.State.Started != "" {
+			return fmt.Errorf("phase %s of plan %s was partly applied by a previous run and the schema no longer "+
+				"matches the state it recorded, drift must be allowed to resume", check.State.Started, dir)
+		}
+		return fmt.Errorf("schema changed since plan %s was generated, catalog fingerprint %s does not match %s",
+			dir, check.Fingerprint, check.Expected)
+	}
+	if !manifest.Approves(e.Config.ApproveHash) {
+		return fmt.Errorf("plan %s is not approved, confirm plan hash %s", dir, manifest.PlanHash)
+	}
 
+	e.State = check.State
 	start := time.Now()
+	for i, phase := range manifest.Phases {
+		if e.State.Executed(phase.Name) {
+			logrus.Infof("Phase %d of %d: %s, executed by a previous run", i+1, len(manifest.Phases), phase.Name)
+			continue
+		}
+		statementBlocks, err := NewSqlFileParser(filepath.Join(dir, phase.File)).Parse()
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Phase %d of %d: %s, %d steps", i+1, len(manifest.Phases), phase.Name, len(statementBlocks))
+		if err := e.State.StartPhase(phase.Name); err != nil {
+			return err
+		}
+		complete := func(i int, block []string) error {
+			return e.recordBlock(dir, manifest, phase.Name, i, block)
+		}
+		if failed := e.executeBlocks(phase.Name, statementBlocks, complete); failed > 0 {
+			return fmt.Errorf("phase %s: %d of %d steps failed, later phases were not run", phase.Name, failed,
+				len(statementBlocks))
+		}
+		if err := e.recordPhase(dir, manifest, phase.Name); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Completed executing plan %s in %s", manifest.Name, time.Since(start))
+	return nil
+}
+
+// recordPhase records the phase as executed along with the state the remaining phases expect. When the plan
+// has a rollback, the state the rollback expects is recorded in the rollback directory too.
+func (e *Executor) recordPhase(dir string, manifest *PlanManifest, phase string) error {
+	current, err := e.schema()
+	if err != nil {
+		return err
+	}
+	executed := append(slices.Clone(e.State.Phases), phase)
+	if err := e.State.CompletePhase(phase, current.Relevant(manifest.Targets(executed))); err != nil {
+		return err
+	}
+	return e.expectRollback(dir, manifest, current)
+}
+
+// recordBlock records the i-th block of the phase as completed along with the state the remaining phases,
+// including this one, expect, so a run interrupted partway through the phase resumes without drift. The
+// state the rollback expects is recorded too.
+func (e *Executor) recordBlock(dir string, manifest *PlanManifest, phase string, i int, block []string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current, err := e.schema()
+	if err != nil {
+		return err
+	}
+	expected := current.Relevant(manifest.Targets(e.State.Phases))
+	if err := e.State.CompleteBlock(phase, i, block, &expected); err != nil {
+		return err
+	}
+	return e.expectRollback(dir, manifest, current)
+}
+
+// expectRollback records the current schema as the state the rollback of the plan expects, when it has one
+func (e *Executor) expectRollback(dir string, manifest *PlanManifest, current Schema) error {
+	if manifest.Rollback == "" {
+		return nil
+	}
+	rollbackDir := filepath.Join(dir, manifest.Rollback)
+	rollback, err := ReadPlanManifest(rollbackDir)
+	if err != nil {
+		return err
+	}
+	state, err := ReadExecutionState(filepath.Join(rollbackDir, ExecutionStateFile))
+	if err != nil {
+		return err
+	}
+	return state.Expect(current.Relevant(rollback.Targets(state.Phases)))
+}
+
+// executeBlocks runs the blocks of the phase on concurrent connections and returns the number of blocks that
+// failed. Completed blocks are recorded with complete, and blocks a previous run completed are skipped.
+func (e *Executor) executeBlocks(phase string, statementBlocks [][]string, complete func(i int, block []string) error) int {
+	type indexedBlock struct {
+		i     int
+		block []string
+	}
+	sqlChan := make(chan indexedBlock, len(statementBlocks))
+	var wg sync.WaitGroup
+	var failed atomic.Int32
 
 	for i := 0; i < e.Config.Concurrency; i++ {
 		wg.Add(1)
 		go func(workerId int) {
 			defer wg.Done()
 			for batch := range sqlChan {
-				logrus.Infof("Executing [%d]:\n%s", workerId, strings.Join(batch, "\n---\n"))
-				if err := e.executeSQLStatements(batch); err != nil {
+				logrus.Infof("Executing [%d]:\n%s", workerId, strings.Join(batch.block, "\n---\n"))
+				if err := e.execute(batch.block); err != nil {
+					logrus.Errorf("Error [%d]: %v", workerId, err)
+					failed.Add(1)
+					continue
+				}
+				if err := complete(batch.i, batch.block); err != nil {
 					logrus.Errorf("Error [%d]: %v", workerId, err)
+					failed.Add(1)
 				}
 			}
 		}(i)
 	}
 
-	for _, block := range statementBlocks {
-		sqlChan <- block
+	skipped := 0
+	for i, block := range statementBlocks {
+		if e.State.BlockCompleted(phase, i, block) {
+			skipped++
+			continue
+		}
+		sqlChan <- indexedBlock{i: i, block: block}
 	}
 	close(sqlChan)
+	if skipped > 0 {
+		logrus.Infof("Skipped %d of %d steps completed by a previous run", skipped, len(statementBlocks))
+	}
 
 	wg.Wait()
-	logrus.Infof("Completed executing all SQL in %s", time.Since(start))
-	return nil
+	return int(failed.Load())
 }
 
 func (e *Executor) executeSQLStatements(statements []string) error {
@@ -85,6 +325,13 @@ func (e *Executor) executeSQLStatements(statements []string) error {
 
 	// Execute SQL statements
 	for i, statement := range statements {
+		if strings.HasPrefix(statement, KeysetBatchDirective) {
+			if err := e.executeKeysetBatches(conn, statement); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Execute once unless UntilZeroRows is set to true, in which case we are performing a batch
 		// operation and want to continue
 		keepGoing := true
@@ -0,0 +1,155 @@
+package analyze
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+// testExecutor returns an executor for database d that reads the schema from current instead of the database
+func testExecutor(current *Schema) *Executor {
+	state, _ := ReadExecutionState("")
+	return &Executor{Config: ExecutorConfig{Database: "d", Concurrency: 1}, State: state,
+		schema: func() (Schema, error) { return *current, nil }, execute: func([]string) error { return nil }}
+}
+
+func TestCheckPlan(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plan")
+	rollbackDir := filepath.Join(dir, "rollback")
+	current := Schema{Database: "d",
+		Tables: []SchemaTable{
+			{Name: "t", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+			{Name: "u", Locality: Locality{Type: LocalityTypeGlobal}},
+		},
+		Zones: []ZoneConfig{{Target: "DATABASE d", NumReplicas: 5, Fields: []string{ZoneFieldNumReplicas}}},
+	}
+	generated := current
+	require.NoError(t, OutDirPlanWriter{Dir: dir, Schema: &generated, Rollback: "rollback"}.Write(testPlan()))
+	rollback := NewPlan("test-rollback", "d")
+	rollback.AddPhase("rollback").AddBlock(nil, Statement{Sql: "ALTER TABLE t SET LOCALITY REGIONAL BY ROW",
+		Target: "t", Risk: PlanRiskMedium, Idempotent: true})
+	require.NoError(t, OutDirPlanWriter{Dir: rollbackDir, Schema: &generated, RunsAfter: ".."}.Write(rollback))
+	e := testExecutor(&current)
+
+	check, err := e.CheckPlan(dir)
+	require.NoError(t, err)
+	assert.False(t, check.Drifted())
+
+	// Changes to tables the plan does not change are not drift
+	current.Tables = []SchemaTable{current.Tables[0], {Name: "u", Locality: Locality{Type: LocalityTypeRegionalByTable}}}
+	check, err = e.CheckPlan(dir)
+	require.NoError(t, err)
+	assert.False(t, check.Drifted())
+
+	current.Tables = []SchemaTable{{Name: "t", Locality: Locality{Type: LocalityTypeGlobal}}, current.Tables[1]}
+	check, err = e.CheckPlan(dir)
+	require.NoError(t, err)
+	assert.True(t, check.Drifted())
+	assert.Equal(t, []SchemaDifference{{Kind: SchemaDiffKindLocality, Object: "t", Change: SchemaDiffChangeChanged,
+		Left: "REGIONAL BY ROW", Right: "GLOBAL"}}, check.Differences)
+
+	// The rollback expects the state the plan leaves, which is unknown until the plan executes a phase
+	check, err = e.CheckPlan(rollbackDir)
+	require.NoError(t, err)
+	assert.True(t, check.Drifted())
+	assert.Empty(t, check.Expected)
+
+	// Once the first phase changed t, the remaining phase only expects the database zone configuration it
+	// changes, and the rollback expects t as the first phase left it
+	e.State, err = ReadExecutionState(filepath.Join(dir, ExecutionStateFile))
+	require.NoError(t, err)
+	manifest, err := ReadPlanManifest(dir)
+	require.NoError(t, err)
+	require.NoError(t, e.recordPhase(dir, manifest, "first"))
+
+	current.Tables[0].FKs = []FKConstraint{{Name: "fk", Table: "t", Columns: []string{"u_id"}, ReferencedTable: "u",
+		ReferencedColumns: []string{"id"}}}
+	check, err = e.CheckPlan(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, check.State.Phases)
+	assert.False(t, check.Drifted())
+
+	check, err = e.CheckPlan(rollbackDir)
+	require.NoError(t, err)
+	assert.True(t, check.Drifted())
+	require.Len(t, check.Differences, 1)
+	assert.Equal(t, SchemaDiffKindFK, check.Differences[0].Kind)
+	assert.Equal(t, SchemaDiffChangeAdded, check.Differences[0].Change)
+	current.Tables[0].FKs = nil
+	check, err = e.CheckPlan(rollbackDir)
+	require.NoError(t, err)
+	assert.False(t, check.Drifted())
+
+	// The database zone configuration the remaining phase changes is checked
+	current.Zones = []ZoneConfig{{Target: "DATABASE d", NumReplicas: 3, Fields: []string{ZoneFieldNumReplicas}}}
+	check, err = e.CheckPlan(dir)
+	require.NoError(t, err)
+	assert.True(t, check.Drifted())
+}
+
+func TestExecutePlanResume(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plan")
+	current := Schema{Database: "d", Tables: []SchemaTable{{Name: "t", Locality: Locality{Type: LocalityTypeGlobal}}}}
+	plan := NewPlan("test", "d")
+	phase := plan.AddPhase("first")
+	for _, sql := range []string{"UPDATE t SET a = 1", "UPDATE t SET b = 1"} {
+		phase.AddBlock(nil, Statement{Sql: sql, Target: "t", Risk: PlanRiskLow, Idempotent: true})
+	}
+	phase = plan.AddPhase("second")
+	for _, sql := range []string{"UPDATE t SET c = 1", "UPDATE t SET d = 1", "UPDATE t SET e = 1"} {
+		phase.AddBlock(nil, Statement{Sql: sql, Target: "t", Risk: PlanRiskLow, Idempotent: true})
+	}
+	require.NoError(t, OutDirPlanWriter{Dir: dir, Schema: &current}.Write(plan))
+	manifest, err := ReadPlanManifest(dir)
+	require.NoError(t, err)
+
+	// Every executed block changes t, as the schema changes of a plan would
+	var executed []string
+	failing := "UPDATE t SET d = 1;"
+	execute := func(statements []string) error {
+		if statements[0] == failing {
+			return errors.New("connection reset")
+		}
+		executed = append(executed, statements...)
+		current.Tables[0].FKs = append(current.Tables[0].FKs, FKConstraint{Name: statements[0], Table: "t"})
+		return nil
+	}
+	e := testExecutor(&current)
+	e.Config.ApproveHash = manifest.PlanHash
+	e.execute = execute
+
+	// The run is interrupted by a failed block of the second phase
+	assert.EqualError(t, e.ExecutePlan(dir), "phase second: 1 of 3 steps failed, later phases were not run")
+	assert.Equal(t, []string{"UPDATE t SET a = 1;", "UPDATE t SET b = 1;", "UPDATE t SET c = 1;",
+		"UPDATE t SET e = 1;"}, executed)
+	state, err := ReadExecutionState(filepath.Join(dir, ExecutionStateFile))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, state.Phases)
+	assert.Len(t, state.Blocks["second"], 2)
+	assert.Equal(t, "second", state.Started)
+
+	// A change to t after the interrupted phase cannot be told from drift
+	executed, failing = nil, ""
+	e = testExecutor(&current)
+	e.Config.ApproveHash = manifest.PlanHash
+	current.Tables[0].Locality = Locality{Type: LocalityTypeRegionalByTable}
+	assert.EqualError(t, e.ExecutePlan(dir), "phase second of plan "+dir+" was partly applied by a previous run "+
+		"and the schema no longer matches the state it recorded, drift must be allowed to resume")
+	current.Tables[0].Locality = Locality{Type: LocalityTypeGlobal}
+
+	// A new execution resumes with the failed block, skipping the first phase and the completed blocks, and
+	// the changes of the completed blocks are not drift
+	e.execute = execute
+	require.NoError(t, e.ExecutePlan(dir))
+	assert.Equal(t, []string{"UPDATE t SET d = 1;"}, executed)
+	state, err = ReadExecutionState(filepath.Join(dir, ExecutionStateFile))
+	require.NoError(t, err)
+	assert.Empty(t, state.Started)
+
+	// A finished plan has nothing left to run
+	executed = nil
+	require.NoError(t, e.ExecutePlan(dir))
+	assert.Empty(t, executed)
+}
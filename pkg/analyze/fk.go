@@ -55,9 +55,11 @@ type FKRedundant struct {
 type Rule string
 
 const (
-	RuleNoAction Rule = "NO ACTION"
-	RuleCascade  Rule = "CASCADE"
-	RuleSetNull  Rule = "SET NULL"
+	RuleNoAction   Rule = "NO ACTION"
+	RuleRestrict   Rule = "RESTRICT"
+	RuleCascade    Rule = "CASCADE"
+	RuleSetNull    Rule = "SET NULL"
+	RuleSetDefault Rule = "SET DEFAULT"
 )
 
 func (fk FKConstraint) String() string {
@@ -73,12 +75,37 @@ func (fk FKConstraint) String() string {
 	)
 }
 
-// GenerateNameNoRegion is used to generate an FK constraint name that does not contain crdb_region
+// withRegionColumns sets whether the FK includes the region, which is when the region column of the table
+// references the region column of the referenced table, and the columns on both sides without it
+func (fk FKConstraint) withRegionColumns(column string, referencedColumn string) FKConstraint {
+	fk.RegionRestricted = false
+	fk.ColumnsNoRegion = nil
+	fk.ReferencedColumnsNoRegion = nil
+	for i, c := range fk.Columns {
+		if i < len(fk.ReferencedColumns) && c == column && fk.ReferencedColumns[i] == referencedColumn {
+			fk.RegionRestricted = true
+			continue
+		}
+		fk.ColumnsNoRegion = append(fk.ColumnsNoRegion, c)
+		if i < len(fk.ReferencedColumns) {
+			fk.ReferencedColumnsNoRegion = append(fk.ReferencedColumnsNoRegion, fk.ReferencedColumns[i])
+		}
+	}
+	return fk
+}
+
+// GenerateNameNoRegion is used to generate an FK constraint name that does not contain the region column
 // this is used for converting tables from RBR to RBT
 func (fk FKConstraint) GenerateNameNoRegion() string {
 	return fmt.Sprintf("%s_%s_fkey", fk.Table, strings.Join(fk.ColumnsNoRegion, "_"))
 }
 
+// GenerateNameWithRegion is used to generate an FK constraint name that contains crdb_region
+// this is used for converting tables from RBT to RBR
+func (fk FKConstraint) GenerateNameWithRegion() string {
+	return fmt.Sprintf("%s_crdb_region_%s_fkey", fk.Table, strings.Join(fk.ColumnsNoRegion, "_"))
+}
+
 func NewFKFilter(tables []string, constraints []string, ruleStrs []string) (*FKFilter, error) {
 	var filterRules []FKFilterRule
 	if len(ruleStrs) > 0 {
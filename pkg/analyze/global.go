@@ -0,0 +1,249 @@
+package analyze
+
+import (
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// GlobalRecommendationConfig holds the thresholds a table must meet to be recommended for LOCALITY GLOBAL
+type GlobalRecommendationConfig struct {
+	MaxSizeBytes uint64
+	MaxRows      int
+	MinReadRatio float64
+}
+
+const DefaultGlobalMaxSizeBytes = 100 * 1024 * 1024
+const DefaultGlobalMaxRows = 100000
+const DefaultGlobalMinReadRatio = 0.95
+
+// GlobalRecommendation is the assessment of whether a table should be GLOBAL
+type GlobalRecommendation struct {
+	Table       Table
+	Reads       int64
+	Writes      int64
+	Recommended bool
+	Reasons     []string
+	// RBRChildren are regional by row tables with FKs to the table whose checks would read locally if it were GLOBAL
+	RBRChildren []string
+}
+
+// writeTargetRe matches the table a statement changes, along with the preceding word to tell the UPDATE of
+// ON CONFLICT DO UPDATE and FOR UPDATE apart
+var writeTargetRe = regexp.MustCompile(`(?i)(\w+\s+)?\b(?:INSERT\s+INTO|UPSERT\s+INTO|UPDATE|DELETE\s+FROM)\s+((?:"[^"]*"|[\w$]+)(?:\s*\.\s*(?:"[^"]*"|[\w$]+))*)`)
+
+// tableStatementStats is the number of read and write statement executions on a table
+type tableStatementStats struct {
+	Reads  int64
+	Writes int64
+}
+
+func (r GlobalRecommendation) String() string {
+	recommendation := "keep"
+	if r.Recommended {
+		recommendation = "GLOBAL"
+	}
+	return fmt.Sprintf("Table: %s, Locality: %s, Logical Size: %s, Row Count: %d, Reads: %d, Writes: %d, Read Ratio: %.2f, Recommendation: %s, Reasons: %s",
+		r.Table.Name, r.Table.Locality, formatBytes(r.Table.LogicalSizeBytes), r.Table.EstimatedRowCount, r.Reads, r.Writes,
+		r.ReadRatio(), recommendation, strings.Join(r.Reasons, "; "))
+}
+
+// ReadRatio is the fraction of statement executions that are reads, or zero without statistics
+func (r GlobalRecommendation) ReadRatio() float64 {
+	if r.Reads+r.Writes == 0 {
+		return 0
+	}
+	return float64(r.Reads) / float64(r.Reads+r.Writes)
+}
+
+// GlobalRecommendations assesses every table that is not already GLOBAL using its size, row count and the
+// read/write ratio from statement statistics. Recommended tables are listed first.
+func (a *Analyzer) GlobalRecommendations(config GlobalRecommendationConfig) ([]GlobalRecommendation, error) {
+	tables, err := a.Tables(true, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.Db.StatementTables(a.Config.Database)
+	if err != nil {
+		return nil, err
+	}
+	return recommendGlobalTables(config, tables, tableStatementStatsFromRows(rows)), nil
+}
+
+// tableStatementStatsFromRows counts the executions of each statement as a write on the tables it changes and
+// as a read on the other tables it uses, such as the parent tables of FK checks
+func tableStatementStatsFromRows(rows []db.StatementTablesRow) map[string]tableStatementStats {
+	stats := make(map[string]tableStatementStats)
+	for _, row := range rows {
+		targets := statementWriteTargets(row.Query)
+		tables := row.Tables
+		for _, target := range targets {
+			if !slices.Contains(tables, target) {
+				tables = append(tables, target)
+			}
+		}
+		for _, table := range tables {
+			s := stats[table]
+			if slices.Contains(targets, table) {
+				s.Writes += row.Count
+			} else {
+				s.Reads += row.Count
+			}
+			stats[table] = s
+		}
+	}
+	return stats
+}
+
+// statementWriteTargets returns the tables a statement inserts into, upserts into, updates or deletes from,
+// including in common table expressions. Names are returned without the database and schema.
+func statementWriteTargets(query string) []string {
+	var targets []string
+	for _, matches := range writeTargetRe.FindAllStringSubmatch(query, -1) {
+		// ON CONFLICT DO UPDATE and SELECT FOR UPDATE do not name a table
+		if preceding := strings.TrimSpace(matches[1]); strings.EqualFold(preceding, "DO") || strings.EqualFold(preceding, "FOR") {
+			continue
+		}
+		parts := splitQualifiedName(matches[2])
+		if len(parts) == 0 {
+			continue
+		}
+		name := unquoteIdentifier(parts[len(parts)-1])
+		if !slices.Contains(targets, name) {
+			targets = append(targets, name)
+		}
+	}
+	return targets
+}
+
+func recommendGlobalTables(config GlobalRecommendationConfig, tables []Table, stats map[string]tableStatementStats) []GlobalRecommendation {
+	var recommendations []GlobalRecommendation
+
+	localities := make(map[string]Locality)
+	for _, t := range tables {
+		localities[t.Name] = t.Locality
+	}
+
+	for _, t := range tables {
+		if t.Locality.Type == LocalityTypeGlobal {
+			continue
+		}
+		r := GlobalRecommendation{Table: t, Reads: stats[t.Name].Reads, Writes: stats[t.Name].Writes, Recommended: true}
+		reject := func(format string, args ...any) {
+			r.Recommended = false
+			r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+		}
+
+		if t.LogicalSizeBytes > config.MaxSizeBytes {
+			reject("size %s is over %s", formatBytes(t.LogicalSizeBytes), formatBytes(config.MaxSizeBytes))
+		}
+		if t.EstimatedRowCount > config.MaxRows {
+			reject("%d rows is over %d", t.EstimatedRowCount, config.MaxRows)
+		}
+		if r.Reads+r.Writes == 0 {
+			reject("no statement statistics")
+		} else if r.ReadRatio() < config.MinReadRatio {
+			reject("read ratio %.2f is under %.2f", r.ReadRatio(), config.MinReadRatio)
+		}
+
+		// FK checks from regional by row children go to the home region of the parent unless the FK includes
+		// the region of a regional by row parent. A GLOBAL parent can be checked in any region.
+		for _, fk := range t.ReferencedFKs {
+			if !localities[fk.Table].IsRegionalByRow() || (t.Locality.IsRegionalByRow() && fk.RegionRestricted) {
+				continue
+			}
+			if !slices.Contains(r.RBRChildren, fk.Table) {
+				r.RBRChildren = append(r.RBRChildren, fk.Table)
+			}
+		}
+		if len(r.RBRChildren) > 0 {
+			r.Reasons = append(r.Reasons, fmt.Sprintf("FK checks from regional by row %s would read locally",
+				strings.Join(r.RBRChildren, ", ")))
+		}
+		if r.Recommended {
+			r.Reasons = append([]string{fmt.Sprintf("small and read-mostly with read ratio %.2f", r.ReadRatio())},
+				r.Reasons...)
+		}
+
+		recommendations = append(recommendations, r)
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Recommended && !recommendations[j].Recommended
+	})
+	return recommendations
+}
+
+// ToGlobalPlan generates the plan that changes the tables to LOCALITY GLOBAL. Zone configuration overrides
+// on the tables, their indexes and partitions conflict with the GLOBAL placement and are discarded first.
+func (c *Converter) ToGlobalPlan(tableNames []string) (*Plan, error) {
+	tables, err := c.Analyzer.Tables(false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := c.Analyzer.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	views, err := c.Analyzer.Views(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []Table
+	for _, name := range tableNames {
+		found := false
+		for _, t := range tables {
+			if t.Name == name {
+				selected = append(selected, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("table %s not found in database %s", name, c.Config.Database)
+		}
+	}
+
+	return toGlobalPlan(c.Config.Database, selected, zones, views), nil
+}
+
+func toGlobalPlan(database string, tables []Table, zones []ZoneConfig, views []View) *Plan {
+	plan := NewPlan("to-global", database)
+
+	phase := plan.AddPhase("zone_config_discard")
+	for _, table := range tables {
+		for _, zc := range zones {
+			target, err := zc.ParsedTarget()
+			if err != nil || target.Table != table.Name || target.Type == ZoneTargetTypeDatabase {
+				continue
+			}
+			sql := fmt.Sprintf("ALTER %s CONFIGURE ZONE DISCARD", zc.Target)
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "conflicts with GLOBAL placement",
+				Risk: PlanRiskLow, Idempotent: true})
+		}
+	}
+
+	phase = plan.AddPhase("table_locality")
+	for _, table := range tables {
+		if table.Locality.Type == LocalityTypeGlobal {
+			phase.AddBlock([]string{fmt.Sprintf("%s is already GLOBAL", quoteIdentifier(table.Name))})
+			continue
+		}
+		comments := viewDependencyComments(views, table)
+		if table.Locality.IsRegionalByRow() {
+			comments = append(comments, fmt.Sprintf(
+				"WARNING: %s is regional by row, FKs that include %s keep the column after the change",
+				quoteIdentifier(table.Name), table.Locality.RegionColumn))
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s",
+			quoteIdentifierWithDatabase(table.Database, table.Name), Locality{Type: LocalityTypeGlobal})
+		phase.AddBlock(comments, Statement{Sql: sql, Target: table.Name, Reason: "convert to GLOBAL",
+			Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	return plan
+}
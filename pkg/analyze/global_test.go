@@ -0,0 +1,107 @@
+package analyze
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecommendGlobalTables(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+
+	countryFK := FKConstraint{Name: "users_country_fkey", Table: "users", ReferencedTable: "countries"}
+	tables := []Table{
+		{Name: "users", Locality: rbr, LogicalSizeBytes: 10 << 30, EstimatedRowCount: 5000000, FKs: []FKConstraint{countryFK}},
+		{Name: "countries", Locality: rbt, LogicalSizeBytes: 64 << 10, EstimatedRowCount: 250, ReferencedFKs: []FKConstraint{countryFK}},
+		{Name: "counters", Locality: rbt, LogicalSizeBytes: 1 << 10, EstimatedRowCount: 10},
+		{Name: "idle", Locality: rbt},
+		{Name: "currencies", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	stats := map[string]tableStatementStats{
+		"users":     {Reads: 1000, Writes: 1000},
+		"countries": {Reads: 990, Writes: 10},
+		"counters":  {Reads: 100, Writes: 100},
+	}
+	config := GlobalRecommendationConfig{
+		MaxSizeBytes: DefaultGlobalMaxSizeBytes,
+		MaxRows:      DefaultGlobalMaxRows,
+		MinReadRatio: DefaultGlobalMinReadRatio,
+	}
+
+	recommendations := recommendGlobalTables(config, tables, stats)
+	assert.Len(t, recommendations, 4)
+
+	countries := recommendations[0]
+	assert.Equal(t, "countries", countries.Table.Name)
+	assert.True(t, countries.Recommended)
+	assert.Equal(t, []string{"users"}, countries.RBRChildren)
+	assert.Equal(t, []string{"small and read-mostly with read ratio 0.99",
+		"FK checks from regional by row users would read locally"}, countries.Reasons)
+
+	byName := make(map[string]GlobalRecommendation)
+	for _, r := range recommendations[1:] {
+		assert.False(t, r.Recommended)
+		byName[r.Table.Name] = r
+	}
+	assert.Equal(t, []string{"size 10.0 GB is over 100.0 MB", "5000000 rows is over 100000", "read ratio 0.50 is under 0.95"},
+		byName["users"].Reasons)
+	assert.Equal(t, []string{"read ratio 0.50 is under 0.95"}, byName["counters"].Reasons)
+	assert.Equal(t, []string{"no statement statistics"}, byName["idle"].Reasons)
+}
+
+func TestToGlobalPlan(t *testing.T) {
+	tables := []Table{
+		{Database: "d", Name: "countries", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+		{Database: "d", Name: "currencies", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	zones := []ZoneConfig{
+		{Target: "DATABASE d"},
+		{Target: "TABLE d.public.countries"},
+		{Target: `PARTITION "us-east1" OF INDEX d.public.countries@countries_pkey`},
+		{Target: "TABLE d.public.other"},
+	}
+
+	assert.Equal(t, []string{
+		"-- FILE START zone_config_discard.sql",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.countries CONFIGURE ZONE DISCARD;",
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER PARTITION "us-east1" OF INDEX d.public.countries@countries_pkey CONFIGURE ZONE DISCARD;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		`-- WARNING: "countries" is regional by row, FKs that include crdb_region keep the column after the change`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."countries" SET LOCALITY GLOBAL;`,
+		ParallelSqlBlockEnd,
+		`-- "currencies" is already GLOBAL`,
+		"-- FILE END",
+	}, toGlobalPlan("d", tables, zones, nil).Lines())
+}
+
+func TestStatementWriteTargets(t *testing.T) {
+	assert.Equal(t, []string{"items"}, statementWriteTargets("INSERT INTO items(id, order_id) VALUES ($1, $2)"))
+	assert.Equal(t, []string{"items"}, statementWriteTargets(`UPSERT INTO d.public."items"(id) VALUES ($1)`))
+	assert.Equal(t, []string{"orders"}, statementWriteTargets("INSERT INTO orders(id) VALUES ($1) ON CONFLICT (id) DO UPDATE SET id = excluded.id"))
+	assert.Equal(t, []string{"orders"}, statementWriteTargets("WITH x AS (DELETE FROM orders WHERE id = $1 RETURNING id) SELECT * FROM x"))
+	assert.Equal(t, []string{"users"}, statementWriteTargets("  update users SET name = $1 WHERE id = $2"))
+	assert.Empty(t, statementWriteTargets("WITH x AS (SELECT id FROM orders) SELECT * FROM x"))
+	assert.Empty(t, statementWriteTargets("(SELECT id FROM orders) UNION (SELECT id FROM items)"))
+	assert.Empty(t, statementWriteTargets("SELECT id FROM orders WHERE id = $1 FOR UPDATE"))
+}
+
+func TestTableStatementStatsFromRows(t *testing.T) {
+	// Inserts into the child check the FK on the parent, which stays read-only
+	stats := tableStatementStatsFromRows([]db.StatementTablesRow{
+		{Query: "INSERT INTO orders(id, country) VALUES ($1, $2)", Count: 100, Tables: []string{"orders", "countries"}},
+		{Query: "SELECT name FROM countries WHERE code = $1", Count: 50, Tables: []string{"countries"}},
+		{Query: "WITH c AS (SELECT code FROM countries) SELECT * FROM orders JOIN c ON true", Count: 5,
+			Tables: []string{"countries", "orders"}},
+	})
+	assert.Equal(t, map[string]tableStatementStats{
+		"orders":    {Reads: 5, Writes: 100},
+		"countries": {Reads: 155},
+	}, stats)
+}
@@ -0,0 +1,97 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keysetPlaceholderRe matches the placeholders a keyset batch statement takes the last key in
+var keysetPlaceholderRe = regexp.MustCompile(`\$(\d+)`)
+
+// KeysetProgress is how far a keyset batch statement got, so an interrupted execution resumes after the last
+// key of the last completed batch
+type KeysetProgress struct {
+	// Cursor is the last key of the last completed batch, as strings
+	Cursor  []string `json:"cursor,omitempty"`
+	Batches int      `json:"batches"`
+	Rows    int64    `json:"rows"`
+	Done    bool     `json:"done"`
+}
+
+// keysetStatement removes the directive and the terminating semicolon from a keyset batch statement and
+// returns the number of key columns it takes
+func keysetStatement(statement string) (string, int) {
+	sql := strings.TrimSpace(strings.TrimPrefix(statement, KeysetBatchDirective))
+	sql = strings.TrimSuffix(sql, ";")
+	keys := 0
+	for _, match := range keysetPlaceholderRe.FindAllStringSubmatch(sql, -1) {
+		n, _ := strconv.Atoi(match[1])
+		keys = max(keys, n)
+	}
+	return sql, keys
+}
+
+// executeKeysetBatches runs a keyset batch statement until it returns no rows. Each batch is passed the last
+// key of the previous batch, or NULLs for the first batch, and returns its last key and the number of rows it
+// changed. Progress is logged and recorded in the keyset state after each batch.
+func (e *Executor) executeKeysetBatches(conn *pgxpool.Conn, statement string) error {
+	sql, keys := keysetStatement(statement)
+	progress := e.State.Progress(statement)
+	if progress.Done {
+		logrus.Infof("Skipping keyset batches completed by a previous run, %d rows in %d batches: %s",
+			progress.Rows, progress.Batches, sql)
+		return nil
+	}
+	if progress.Batches > 0 {
+		logrus.Infof("Resuming keyset batches after batch %d, %d rows, cursor %v", progress.Batches, progress.Rows,
+			progress.Cursor)
+	}
+
+	start := time.Now()
+	for {
+		args := make([]any, keys)
+		for i := 0; i < keys && i < len(progress.Cursor); i++ {
+			args[i] = progress.Cursor[i]
+		}
+		cursor := make([]string, keys)
+		var rows int64
+		dest := make([]any, 0, keys+1)
+		for i := range cursor {
+			dest = append(dest, &cursor[i])
+		}
+		dest = append(dest, &rows)
+
+		batchStart := time.Now()
+		err := conn.QueryRow(context.Background(), sql, args...).Scan(dest...)
+		if errors.Is(err, pgx.ErrNoRows) {
+			progress.Done = true
+			if err := e.State.Update(statement, progress); err != nil {
+				return err
+			}
+			logrus.Infof("Completed %d keyset batches, %d rows, in %s: %s", progress.Batches, progress.Rows,
+				time.Since(start), sql)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed on keyset batch %d after cursor %v '%s': %w", progress.Batches+1,
+				progress.Cursor, sql, err)
+		}
+
+		progress.Cursor = cursor
+		progress.Batches++
+		progress.Rows += rows
+		if err := e.State.Update(statement, progress); err != nil {
+			return err
+		}
+		logrus.Infof("Keyset batch %d changed %d rows in %s, %d rows total, cursor %v", progress.Batches, rows,
+			time.Since(batchStart), progress.Rows, cursor)
+	}
+}
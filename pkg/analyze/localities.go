@@ -0,0 +1,337 @@
+package analyze
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// LocalitySpecVersion is the version of the locality spec format
+const LocalitySpecVersion = 1
+
+// LocalitySpec is the desired locality of the tables of a database, read from a file such as localities.yaml
+type LocalitySpec struct {
+	Version int `yaml:"version"`
+	// Database is checked against the database the plan is generated for, when set
+	Database string `yaml:"database,omitempty"`
+	// Tables are matched in order and the first entry matching a table applies. Tables that match no entry
+	// are left as they are.
+	Tables []LocalitySpecEntry `yaml:"tables"`
+}
+
+// LocalitySpecEntry is the desired locality and zone configuration of the tables matching a pattern
+type LocalitySpecEntry struct {
+	// Match is a table name glob, or a regular expression when prefixed with "re:"
+	Match string `yaml:"match"`
+	// Locality is RBR, RBT or GLOBAL, or a locality as in SET LOCALITY such as REGIONAL BY TABLE IN "us-east1"
+	Locality string `yaml:"locality"`
+	// Region is the home region of RBT tables, the primary region when empty
+	Region string `yaml:"region,omitempty"`
+	// RegionColumn is the region column of RBR tables, crdb_region when empty
+	RegionColumn string `yaml:"region_column,omitempty"`
+	// Zone is the table zone configuration as a list of fields, such as "gc.ttlseconds = 600". Fields of the
+	// current table zone configuration that are not listed are reset. The zone configuration is left as it
+	// is when empty, unless the locality changes.
+	Zone string `yaml:"zone,omitempty"`
+
+	patterns []tablePattern
+	locality Locality
+	zone     *ZoneConfig
+}
+
+// LocalityChange is the difference between the current and the desired locality of a table
+type LocalityChange struct {
+	Table Table
+	From  Locality
+	To    Locality
+	// Zone is the desired table zone configuration, nil when the spec does not set one
+	Zone *ZoneConfig
+}
+
+// ReadLocalitySpec reads and validates a locality spec. JSON is a subset of YAML, so both formats are read
+// with the YAML decoder.
+func ReadLocalitySpec(path string) (LocalitySpec, error) {
+	var spec LocalitySpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("locality spec %s: %w", path, err)
+	}
+	if err := spec.parse(); err != nil {
+		return spec, fmt.Errorf("locality spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// parse validates the spec and parses the patterns, localities and zone configurations of its entries
+func (s *LocalitySpec) parse() error {
+	if s.Version != LocalitySpecVersion {
+		return fmt.Errorf("unsupported version %d, expected %d", s.Version, LocalitySpecVersion)
+	}
+	for i := range s.Tables {
+		entry := &s.Tables[i]
+		if entry.Match == "" {
+			return fmt.Errorf("table entry %d has no match", i+1)
+		}
+		patterns, err := parseTablePatterns([]string{entry.Match})
+		if err != nil {
+			return err
+		}
+		entry.patterns = patterns
+
+		if localityType, err := parseLocalityType(entry.Locality); err == nil {
+			entry.locality = Locality{Type: localityType}
+		} else if entry.locality, err = ParseLocality(entry.Locality); err != nil || entry.locality.Type == "" {
+			return fmt.Errorf("table entry %s: invalid locality %q", entry.Match, entry.Locality)
+		}
+		switch {
+		case entry.Region != "" && entry.locality.Type != LocalityTypeRegionalByTable:
+			return fmt.Errorf("table entry %s: region is only valid for RBT", entry.Match)
+		case entry.RegionColumn != "" && entry.locality.Type != LocalityTypeRegionalByRow:
+			return fmt.Errorf("table entry %s: region_column is only valid for RBR", entry.Match)
+		case entry.Region != "":
+			entry.locality.Region = entry.Region
+		case entry.RegionColumn != "":
+			entry.locality.RegionColumn = entry.RegionColumn
+		case entry.locality.IsRegionalByRow() && entry.locality.RegionColumn == "":
+			entry.locality.RegionColumn = DefaultRegionColumn
+		}
+
+		if entry.Zone != "" {
+			zone, err := parseZoneConfig(entry.Zone)
+			if err != nil {
+				return fmt.Errorf("table entry %s: %w", entry.Match, err)
+			}
+			entry.zone = &zone
+		}
+	}
+	return nil
+}
+
+// Changes returns the tables whose locality or zone configuration the spec changes, in table order
+func (s LocalitySpec) Changes(tables []Table) []LocalityChange {
+	var changes []LocalityChange
+	for _, t := range tables {
+		for _, entry := range s.Tables {
+			if !matchesAnyTablePattern(entry.patterns, t.Name) {
+				continue
+			}
+			if entry.locality != t.Locality || entry.zone != nil {
+				changes = append(changes, LocalityChange{Table: t, From: t.Locality, To: entry.locality, Zone: entry.zone})
+			}
+			break
+		}
+	}
+	return changes
+}
+
+// UnmatchedEntries returns the patterns of entries that match none of the tables, which are likely mistakes
+func (s LocalitySpec) UnmatchedEntries(tables []Table) []string {
+	var unmatched []string
+	for _, entry := range s.Tables {
+		found := false
+		for _, t := range tables {
+			found = found || matchesAnyTablePattern(entry.patterns, t.Name)
+		}
+		if !found {
+			unmatched = append(unmatched, entry.Match)
+		}
+	}
+	return unmatched
+}
+
+// LocalityChanged determines whether the table changes locality
+func (c LocalityChange) LocalityChanged() bool {
+	return c.From != c.To
+}
+
+func (c LocalityChange) String() string {
+	if !c.LocalityChanged() {
+		return fmt.Sprintf("%s: %s, zone configuration only", c.Table.Name, c.From)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Table.Name, c.From, c.To)
+}
+
+// ApplyLocalitiesPlan generates the plan that brings the tables to the localities and zone configurations of
+// the spec. It generalizes rbr2rbt and rbt2rbr: tables leaving regional by row have their region FKs replaced
+// and their region column changed to a string, and tables becoming regional by row get the region type back
+// and FKs that include the region. Zone configuration overrides of tables changing locality are discarded.
+func (c *Converter) ApplyLocalitiesPlan(spec LocalitySpec) (*Plan, error) {
+	if spec.Database != "" && spec.Database != c.Config.Database {
+		return nil, fmt.Errorf("locality spec is for database %s, not %s", spec.Database, c.Config.Database)
+	}
+	regions, err := c.Analyzer.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	if !regions.IsMultiRegion() {
+		return nil, fmt.Errorf("database %s is not a multi-region database", c.Config.Database)
+	}
+	zones, err := c.Analyzer.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := c.Analyzer.Tables(false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.Analyzer.Columns()
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := c.Analyzer.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	views, err := c.Analyzer.Views(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := applyLocalitiesPlan(c.Config.Database, regions.PrimaryRegion, spec.Changes(tables), zones, tables,
+		columns, indexes, views)
+	for _, match := range spec.UnmatchedEntries(tables) {
+		plan.Comment(fmt.Sprintf("WARNING: %s matches no tables", match))
+	}
+	return plan, nil
+}
+
+func applyLocalitiesPlan(database string, primaryRegion string, changes []LocalityChange, zones []ZoneConfig,
+	tables []Table, columns map[string][]Column, indexes map[string][]Index, views []View) *Plan {
+	plan := NewPlan("apply-localities", database)
+
+	// Tables leaving and entering regional by row need their FKs and region column changed as well
+	var leaving, entering []Table
+	changed := make(map[string]bool)
+	leavingNames := make(map[string]bool)
+	targets := make(map[string]Locality)
+	var skipComments []string
+	for _, change := range changes {
+		plan.Comment(change.String())
+		if !change.LocalityChanged() {
+			continue
+		}
+		switch {
+		case change.From.IsRegionalByRow() && !change.To.IsRegionalByRow():
+			leaving = append(leaving, change.Table)
+			leavingNames[change.Table.Name] = true
+		case !change.From.IsRegionalByRow() && change.To.IsRegionalByRow():
+			column, ok := findColumn(columns[change.Table.Name], change.To.RegionColumnName())
+			// A computed region column that is not of the region type cannot be changed
+			if ok && column.Computed != "" && !isRegionColumnType(column.Type) {
+				skipComments = append(skipComments, fmt.Sprintf(
+					"WARNING: skipping %s, computed column %s is %s and must be %s to be used as the region column",
+					quoteIdentifier(change.Table.Name), column.Name, column.Type, RegionColumnType))
+				continue
+			}
+			if !ok && change.To.RegionColumnName() != DefaultRegionColumn {
+				skipComments = append(skipComments, fmt.Sprintf("WARNING: skipping %s, region column %s does not exist",
+					quoteIdentifier(change.Table.Name), quoteIdentifier(change.To.RegionColumnName())))
+				continue
+			}
+			entering = append(entering, change.Table)
+			targets[change.Table.Name] = Locality{Type: LocalityTypeRegionalByRow, RegionColumn: change.To.RegionColumnName()}
+		}
+		changed[change.Table.Name] = true
+	}
+	plan.Comment(skipComments...)
+
+	// Region FKs from tables that stay regional by row block changing the type of the region column
+	for _, t := range leaving {
+		for _, fk := range t.ReferencedFKs {
+			if fk.RegionRestricted && !leavingNames[fk.Table] {
+				plan.Comment(fmt.Sprintf("WARNING: %s stays regional by row but must change with %s, FK %s includes the region",
+					fk.Table, t.Name, fk.Name))
+			}
+		}
+	}
+
+	// rbr2rbt replaces the region FKs and changes the region column of the tables leaving regional by row
+	leavingPlan := rbr2rbtPlan(database, Rbr2rbtConfig{PrimaryRegion: primaryRegion}, nil, leaving, tables, columns,
+		indexes, views)
+
+	// rbt2rbr restores the region type of the region column in the spec and adds region FKs for the tables
+	// entering regional by row, linking them with the tables that stay regional by row
+	rbrTables := entering
+	for _, t := range tables {
+		if t.Locality.IsRegionalByRow() && !changed[t.Name] {
+			rbrTables = append(rbrTables, t)
+		}
+	}
+	enteringPlan := rbt2rbrPlan(database, rbrTables, targets, columns, views)
+
+	appendPhase(plan, "fk_without_region", leavingPlan, "fk")
+	appendPhase(plan, "region_type", enteringPlan, "change_crdb_region_type")
+
+	// Overrides on the table, its indexes and partitions belong to the previous locality
+	phase := plan.AddPhase("zone_config_discard")
+	for _, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil || target.Type == ZoneTargetTypeDatabase || !changed[target.Table] {
+			continue
+		}
+		phase.AddBlock(nil, Statement{Sql: fmt.Sprintf("ALTER %s CONFIGURE ZONE DISCARD", zc.Target), Target: zc.Target,
+			Reason: "belongs to the previous locality", Risk: PlanRiskLow, Idempotent: true})
+	}
+
+	phase = plan.AddPhase("table_locality")
+	for _, change := range changes {
+		if !changed[change.Table.Name] {
+			continue
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s", quoteIdentifierWithDatabase(database, change.Table.Name), change.To)
+		phase.AddBlock(viewDependencyComments(views, change.Table), Statement{Sql: sql, Target: change.Table.Name,
+			Reason: fmt.Sprintf("convert from %s", change.From), Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	appendPhase(plan, "fk_with_region", enteringPlan, "fk")
+	appendPhase(plan, "region_column_to_string", leavingPlan, "change_crdb_region_type")
+
+	// The zone configuration of the spec replaces the current table zone configuration, which was discarded
+	// when the locality changed
+	phase = plan.AddPhase("zone_config")
+	for _, change := range changes {
+		if change.Zone == nil || (change.LocalityChanged() && !changed[change.Table.Name]) {
+			continue
+		}
+		target := ZoneTarget{Type: ZoneTargetTypeTable, Database: database, Schema: "public", Table: change.Table.Name}
+		current := ZoneConfig{Target: target.String()}
+		if !change.LocalityChanged() {
+			if zc, ok := tableZoneConfig(zones, change.Table.Name); ok {
+				current = zc
+			}
+		}
+		desired := change.Zone.Clone()
+		desired.Target = current.Target
+		if sql, ok := ZoneConfigDiffSql(current, desired); ok {
+			phase.AddBlock(nil, Statement{Sql: sql, Target: current.Target, Reason: "zone configuration of the spec",
+				Risk: PlanRiskMedium, Idempotent: true})
+		}
+	}
+
+	plan.Comment(plan.Progress()...)
+	return plan
+}
+
+// appendPhase adds the phase of another plan to the plan under a new name
+func appendPhase(plan *Plan, name string, from *Plan, fromName string) {
+	phase := plan.AddPhase(name)
+	if source, ok := from.Phase(fromName); ok {
+		phase.Comments = source.Comments
+		phase.Blocks = source.Blocks
+		phase.Completed = source.Completed
+	}
+}
+
+// tableZoneConfig returns the zone configuration of the table itself
+func tableZoneConfig(zones []ZoneConfig, table string) (ZoneConfig, bool) {
+	for _, zc := range zones {
+		if target, err := zc.ParsedTarget(); err == nil && target.Type == ZoneTargetTypeTable &&
+			target.Table == table {
+			return zc, true
+		}
+	}
+	return ZoneConfig{}, false
+}
@@ -0,0 +1,189 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeLocalitySpec(t *testing.T, spec string) (LocalitySpec, error) {
+	path := filepath.Join(t.TempDir(), "localities.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(spec), 0644))
+	return ReadLocalitySpec(path)
+}
+
+func TestReadLocalitySpec(t *testing.T) {
+	spec, err := writeLocalitySpec(t, `
+version: 1
+database: d
+tables:
+  - match: users
+    locality: GLOBAL
+  - match: "re:^(orders|items)$"
+    locality: RBR
+  - match: "audit_*"
+    locality: RBT
+    region: b
+    zone: "gc.ttlseconds = 600"
+  - match: payments
+    locality: REGIONAL BY ROW AS home
+`)
+	require.NoError(t, err)
+	assert.Equal(t, "d", spec.Database)
+	require.Len(t, spec.Tables, 4)
+	assert.Equal(t, Locality{Type: LocalityTypeGlobal}, spec.Tables[0].locality)
+	assert.Equal(t, Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}, spec.Tables[1].locality)
+	assert.Equal(t, Locality{Type: LocalityTypeRegionalByTable, Region: "b"}, spec.Tables[2].locality)
+	assert.Equal(t, 600, spec.Tables[2].zone.GcTtlSeconds)
+	assert.Equal(t, Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "home"}, spec.Tables[3].locality)
+
+	_, err = writeLocalitySpec(t, "version: 2\n")
+	assert.ErrorContains(t, err, "unsupported version 2, expected 1")
+	_, err = writeLocalitySpec(t, "version: 1\ntables:\n  - match: users\n    locality: SOMEWHERE\n")
+	assert.ErrorContains(t, err, `table entry users: invalid locality "SOMEWHERE"`)
+	_, err = writeLocalitySpec(t, "version: 1\ntables:\n  - match: users\n    locality: GLOBAL\n    region: b\n")
+	assert.ErrorContains(t, err, "table entry users: region is only valid for RBT")
+}
+
+func TestApplyLocalitiesPlan(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regionFK := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"crdb_region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"crdb_region", "id"}, RegionRestricted: true,
+		ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}}
+	itemsFK := FKConstraint{Name: "items_order_id_fkey", Table: "items", Columns: []string{"order_id"},
+		ReferencedTable: "orders", ReferencedColumns: []string{"id"}, ColumnsNoRegion: []string{"order_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr, ReferencedFKs: []FKConstraint{regionFK}},
+		{Database: "d", Name: "orders", Locality: rbr, FKs: []FKConstraint{regionFK}, ReferencedFKs: []FKConstraint{itemsFK}},
+		{Database: "d", Name: "items", Locality: Locality{Type: LocalityTypeRegionalByTable}, FKs: []FKConstraint{itemsFK}},
+		{Database: "d", Name: "audit_log", Locality: Locality{Type: LocalityTypeRegionalByTable, Region: "b"}},
+	}
+	columns := map[string][]Column{
+		"users":  {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"orders": {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"items":  {{Name: "order_id", Type: "INT8"}},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", "num_replicas = 3"),
+		testZoneConfig(t, "TABLE d.public.users", "gc.ttlseconds = 600"),
+		testZoneConfig(t, "TABLE d.public.audit_log", "gc.ttlseconds = 600, num_replicas = 5"),
+	}
+	spec, err := writeLocalitySpec(t, `
+version: 1
+tables:
+  - match: users
+    locality: GLOBAL
+  - match: "re:^(orders|items)$"
+    locality: RBR
+  - match: "audit_*"
+    locality: RBT
+    region: b
+    zone: "gc.ttlseconds = 3600"
+  - match: "missing_*"
+    locality: GLOBAL
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"missing_*"}, spec.UnmatchedEntries(tables))
+
+	changes := spec.Changes(tables)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "users: REGIONAL BY ROW -> GLOBAL", changes[0].String())
+	assert.Equal(t, "items: REGIONAL BY TABLE IN PRIMARY REGION -> REGIONAL BY ROW", changes[1].String())
+	assert.Equal(t, `audit_log: REGIONAL BY TABLE IN "b", zone configuration only`, changes[2].String())
+
+	plan := applyLocalitiesPlan("d", "a", changes, zones, tables, columns, nil, nil)
+	lines := plan.Lines()
+	start := slices.Index(lines, "-- FILE START fk_without_region.sql")
+	require.Positive(t, start)
+	assert.Contains(t, lines, "-- WARNING: orders stays regional by row but must change with users, FK fk_user includes the region")
+	assert.Equal(t, []string{
+		"-- FILE START fk_without_region.sql",
+		"-- FILE END",
+		"-- FILE START region_type.sql",
+		"-- FILE END",
+		"-- FILE START zone_config_discard.sql",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.users CONFIGURE ZONE DISCARD;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."users" SET LOCALITY GLOBAL;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START fk_with_region.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" ADD CONSTRAINT IF NOT EXISTS "items_crdb_region_order_id_fkey" FOREIGN KEY ` +
+			`("crdb_region","order_id") REFERENCES "orders" ("crdb_region","id");`,
+		`ALTER TABLE "d"."items" DROP CONSTRAINT IF EXISTS "items_order_id_fkey";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START region_column_to_string.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."users" ALTER COLUMN "crdb_region" SET DATA TYPE STRING;`,
+		`ALTER TABLE "d"."users" ALTER COLUMN "crdb_region" SET DEFAULT default_to_database_primary_region(gateway_region())::STRING;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zone_config.sql",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.audit_log CONFIGURE ZONE USING gc.ttlseconds = 3600, num_replicas = COPY FROM PARENT;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, lines[start:])
+	assert.Equal(t, "-- Progress: zone_config 0 of 1 steps done, 1 remaining", lines[start-1])
+	assert.True(t, strings.HasPrefix(lines[0], "-- users: "))
+}
+
+func TestApplyLocalitiesPlanRegionColumn(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	paymentsFK := FKConstraint{Name: "payments_user_id_fkey", Table: "payments", Columns: []string{"user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"id"}, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr, ReferencedFKs: []FKConstraint{paymentsFK}},
+		{Database: "d", Name: "payments", Locality: Locality{Type: LocalityTypeRegionalByTable}, FKs: []FKConstraint{paymentsFK}},
+	}
+	columns := map[string][]Column{
+		"users":    {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"payments": {{Name: "user_id", Type: "INT8"}, {Name: "home", Type: "STRING"}},
+	}
+	spec, err := writeLocalitySpec(t, `
+version: 1
+tables:
+  - match: payments
+    locality: REGIONAL BY ROW AS home
+`)
+	require.NoError(t, err)
+
+	// The region column of the spec is changed to the region type and used by the region FK
+	plan := applyLocalitiesPlan("d", "a", spec.Changes(tables), nil, tables, columns, nil, nil)
+	phase, _ := plan.Phase("region_type")
+	assert.Equal(t, []string{
+		`-- WARNING: every home value in "payments" must be a database region before the type can be changed`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ALTER COLUMN "home" SET DATA TYPE crdb_internal_region USING "home"::crdb_internal_region;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+	phase, _ = plan.Phase("table_locality")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" SET LOCALITY REGIONAL BY ROW AS "home";`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+	phase, _ = plan.Phase("fk_with_region")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ADD CONSTRAINT IF NOT EXISTS "payments_crdb_region_user_id_fkey" FOREIGN KEY ` +
+			`("home","user_id") REFERENCES "users" ("crdb_region","id");`,
+		`ALTER TABLE "d"."payments" DROP CONSTRAINT IF EXISTS "payments_user_id_fkey";`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+}
@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LocalityType is the broad table locality - regional by row, regional by table or global
+type LocalityType string
+
+const (
+	LocalityTypeRegionalByRow   LocalityType = "RBR"
+	LocalityTypeRegionalByTable LocalityType = "RBT"
+	LocalityTypeGlobal          LocalityType = "GLOBAL"
+)
+
+// DefaultRegionColumn is the region column used by REGIONAL BY ROW tables without an AS clause
+const DefaultRegionColumn = "crdb_region"
+
+// Locality is a parsed table locality, as shown by SHOW TABLES
+type Locality struct {
+	Type LocalityType
+	// Region is the home region of a REGIONAL BY TABLE table, empty when it is the primary region
+	Region string
+	// RegionColumn is the column that determines the home region of REGIONAL BY ROW rows
+	RegionColumn string
+}
+
+var localityRbtRe = regexp.MustCompile(`(?i)^REGIONAL\s+BY\s+TABLE(?:\s+IN\s+(.+))?$`)
+var localityRbrRe = regexp.MustCompile(`(?i)^REGIONAL\s+BY\s+ROW(?:\s+AS\s+(.+))?$`)
+var localityPrimaryRe = regexp.MustCompile(`(?i)^PRIMARY\s+REGION$`)
+
+// ParseLocality parses a locality string such as REGIONAL BY TABLE IN "us-east1" or REGIONAL BY ROW AS region.
+// An empty string is a table in a database that is not multi-region.
+func ParseLocality(s string) (Locality, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Locality{}, nil
+	}
+	if strings.EqualFold(s, "GLOBAL") {
+		return Locality{Type: LocalityTypeGlobal}, nil
+	}
+	if matches := localityRbtRe.FindStringSubmatch(s); matches != nil {
+		locality := Locality{Type: LocalityTypeRegionalByTable}
+		region := strings.TrimSpace(matches[1])
+		if region != "" && !localityPrimaryRe.MatchString(region) {
+			locality.Region = unquoteIdentifier(region)
+		}
+		return locality, nil
+	}
+	if matches := localityRbrRe.FindStringSubmatch(s); matches != nil {
+		locality := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+		if column := strings.TrimSpace(matches[1]); column != "" {
+			locality.RegionColumn = unquoteIdentifier(column)
+		}
+		return locality, nil
+	}
+	return Locality{}, fmt.Errorf("invalid locality: %q", s)
+}
+
+// RegionColumnName is the region column of a REGIONAL BY ROW table. Other tables may have a crdb_region
+// column left behind by a conversion from REGIONAL BY ROW, so it is the default.
+func (l Locality) RegionColumnName() string {
+	if l.IsRegionalByRow() && l.RegionColumn != "" {
+		return l.RegionColumn
+	}
+	return DefaultRegionColumn
+}
+
+// String returns the locality as it would appear in ALTER TABLE ... SET LOCALITY
+func (l Locality) String() string {
+	switch l.Type {
+	case LocalityTypeGlobal:
+		return "GLOBAL"
+	case LocalityTypeRegionalByTable:
+		if l.Region == "" {
+			return "REGIONAL BY TABLE IN PRIMARY REGION"
+		}
+		return fmt.Sprintf("REGIONAL BY TABLE IN %s", quoteIdentifier(l.Region))
+	case LocalityTypeRegionalByRow:
+		if l.RegionColumn == "" || l.RegionColumn == DefaultRegionColumn {
+			return "REGIONAL BY ROW"
+		}
+		return fmt.Sprintf("REGIONAL BY ROW AS %s", quoteIdentifier(l.RegionColumn))
+	default:
+		return ""
+	}
+}
+
+// HomeRegion returns the region a REGIONAL BY TABLE table is homed in, resolving the primary region.
+// Other localities do not have a single home region.
+func (l Locality) HomeRegion(primaryRegion string) string {
+	if l.Type != LocalityTypeRegionalByTable {
+		return ""
+	}
+	if l.Region == "" {
+		return primaryRegion
+	}
+	return l.Region
+}
+
+// IsRegionalByRow is true for REGIONAL BY ROW tables
+func (l Locality) IsRegionalByRow() bool {
+	return l.Type == LocalityTypeRegionalByRow
+}
+
+func parseLocalityType(s string) (LocalityType, error) {
+	switch LocalityType(strings.ToUpper(strings.TrimSpace(s))) {
+	case LocalityTypeRegionalByRow:
+		return LocalityTypeRegionalByRow, nil
+	case LocalityTypeRegionalByTable:
+		return LocalityTypeRegionalByTable, nil
+	case LocalityTypeGlobal:
+		return LocalityTypeGlobal, nil
+	default:
+		return "", fmt.Errorf("invalid locality: %q", s)
+	}
+}
+
+// unquoteIdentifier removes double quotes from an identifier, unescaping embedded quotes
+func unquoteIdentifier(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
@@ -0,0 +1,41 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLocality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Locality
+		str      string
+	}{
+		{"", Locality{}, ""},
+		{"GLOBAL", Locality{Type: LocalityTypeGlobal}, "GLOBAL"},
+		{"REGIONAL BY TABLE IN PRIMARY REGION", Locality{Type: LocalityTypeRegionalByTable}, "REGIONAL BY TABLE IN PRIMARY REGION"},
+		{"REGIONAL BY TABLE", Locality{Type: LocalityTypeRegionalByTable}, "REGIONAL BY TABLE IN PRIMARY REGION"},
+		{`REGIONAL BY TABLE IN "us-west1"`, Locality{Type: LocalityTypeRegionalByTable, Region: "us-west1"}, `REGIONAL BY TABLE IN "us-west1"`},
+		{"REGIONAL BY ROW", Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "crdb_region"}, "REGIONAL BY ROW"},
+		{"REGIONAL BY ROW AS region", Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}, `REGIONAL BY ROW AS "region"`},
+		{`regional by row as "Home Region"`, Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "Home Region"}, `REGIONAL BY ROW AS "Home Region"`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			locality, err := ParseLocality(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, locality)
+			assert.Equal(t, test.str, locality.String())
+		})
+	}
+
+	_, err := ParseLocality("REGIONAL BY PARTITION")
+	assert.Error(t, err)
+}
+
+func TestLocalityHomeRegion(t *testing.T) {
+	assert.Equal(t, "us-east1", Locality{Type: LocalityTypeRegionalByTable}.HomeRegion("us-east1"))
+	assert.Equal(t, "us-west1", Locality{Type: LocalityTypeRegionalByTable, Region: "us-west1"}.HomeRegion("us-east1"))
+	assert.Equal(t, "", Locality{Type: LocalityTypeRegionalByRow}.HomeRegion("us-east1"))
+}
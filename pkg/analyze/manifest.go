@@ -0,0 +1,220 @@
+package analyze
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PlanManifestFile is the name of the manifest written with the numbered phase files of a plan
+const PlanManifestFile = "manifest.json"
+
+// PlanSchemaFile is the name of the schema snapshot written with a plan, to show drift before execution
+const PlanSchemaFile = "schema.json"
+
+// MinApproveHashLength is the shortest prefix of the plan hash accepted as an approval
+const MinApproveHashLength = 12
+
+// PlanManifest describes a plan written to a directory, so the phases can be executed in order and checked
+// against the files and the catalog they were generated from
+type PlanManifest struct {
+	Name     string `json:"name"`
+	Database string `json:"database"`
+	// CatalogFingerprint is the fingerprint of the part of the schema the plan changes, when the plan was
+	// generated. It is empty for a plan that runs after another one.
+	CatalogFingerprint string `json:"catalog_fingerprint"`
+	// RunsAfter is the directory of the plan this plan runs after, relative to this plan. The execution of
+	// that plan records the state this plan expects.
+	RunsAfter string `json:"runs_after,omitempty"`
+	// Rollback is the directory of the rollback plan written with this plan, relative to this plan
+	Rollback string `json:"rollback,omitempty"`
+	// PlanHash covers the catalog fingerprint, the linked plans and every phase file, and is what an operator
+	// approves before the plan is executed
+	PlanHash  string          `json:"plan_hash"`
+	CreatedAt time.Time       `json:"created_at"`
+	Comments  []string        `json:"comments,omitempty"`
+	Phases    []ManifestPhase `json:"phases"`
+}
+
+// ManifestPhase is a phase file of a plan written to a directory
+type ManifestPhase struct {
+	Name       string `json:"name"`
+	File       string `json:"file"`
+	Statements int    `json:"statements"`
+	Steps      int    `json:"steps"`
+	// Sha256 is the checksum of the phase file
+	Sha256 string `json:"sha256"`
+	// Targets are the tables and zone configurations the phase changes
+	Targets []string `json:"targets,omitempty"`
+}
+
+// OutDirPlanWriter writes each phase of the plan to a file numbered by phase order, such as
+// 01_zoneconfig.sql, along with a manifest and the schema the plan was generated from. It refuses to write
+// to a directory that is not empty. A plan that runs after another one, such as a rollback, is written
+// without the schema, since the state it expects is recorded when the other plan executes.
+type OutDirPlanWriter struct {
+	Dir    string
+	Schema *Schema
+	// RunsAfter is the directory of the plan this plan runs after, relative to Dir
+	RunsAfter string
+	// Rollback is the directory of the rollback plan written with this plan, relative to Dir
+	Rollback string
+}
+
+func (w OutDirPlanWriter) Write(plan *Plan) error {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty, refusing to overwrite a plan", w.Dir)
+	}
+	if err := os.MkdirAll(w.Dir, 0755); err != nil {
+		return err
+	}
+
+	manifest := PlanManifest{
+		Name:      plan.Name,
+		Database:  plan.Database,
+		CreatedAt: time.Now().UTC(),
+		Comments:  plan.Comments,
+		RunsAfter: w.RunsAfter,
+		Rollback:  w.Rollback,
+	}
+	for i, phase := range plan.Phases {
+		file := fmt.Sprintf("%02d_%s", i+1, phase.FileName())
+		var content []byte
+		if lines := phase.Lines(); len(lines) > 0 {
+			content = []byte(strings.Join(lines, "\n") + "\n")
+		}
+		if err := os.WriteFile(filepath.Join(w.Dir, file), content, 0644); err != nil {
+			return err
+		}
+		manifest.Phases = append(manifest.Phases, ManifestPhase{
+			Name:       phase.Name,
+			File:       file,
+			Statements: phase.StatementCount(),
+			Steps:      phase.StepCount(),
+			Sha256:     sha256Hex(content),
+			Targets:    phase.Targets(),
+		})
+	}
+	if w.Schema != nil && w.RunsAfter == "" {
+		manifest.CatalogFingerprint = w.Schema.Relevant(manifest.Targets(nil)).Fingerprint()
+		if err := writeJsonFile(filepath.Join(w.Dir, PlanSchemaFile), w.Schema); err != nil {
+			return err
+		}
+	}
+
+	manifest.PlanHash = manifest.Hash()
+	if err := writeJsonFile(filepath.Join(w.Dir, PlanManifestFile), manifest); err != nil {
+		return err
+	}
+	logrus.Infof("Wrote plan %s to %s, plan hash %s", plan.Name, w.Dir, manifest.PlanHash)
+	return nil
+}
+
+// ReadPlanManifest reads the manifest of a plan written to the directory
+func ReadPlanManifest(dir string) (*PlanManifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, PlanManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest PlanManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+	return &manifest, nil
+}
+
+// ReadPlanSchema reads the schema snapshot written with the plan, or nil when the plan has none
+func ReadPlanSchema(dir string) (*Schema, error) {
+	b, err := os.ReadFile(filepath.Join(dir, PlanSchemaFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema in %s: %w", dir, err)
+	}
+	return &schema, nil
+}
+
+// Targets are the tables and zone configurations changed by the phases that were not executed
+func (m PlanManifest) Targets(executed []string) []string {
+	var targets []string
+	for _, phase := range m.Phases {
+		if slices.Contains(executed, phase.Name) {
+			continue
+		}
+		for _, target := range phase.Targets {
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// Hash is the SHA-256 of the catalog fingerprint, the plans this plan is linked to and the phase files with
+// their checksums and targets, in phase order
+func (m PlanManifest) Hash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", m.CatalogFingerprint)
+	fmt.Fprintf(h, "%s %s\n", m.RunsAfter, m.Rollback)
+	for _, phase := range m.Phases {
+		fmt.Fprintf(h, "%s %s %s\n", phase.File, phase.Sha256, strings.Join(phase.Targets, ", "))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Approves determines whether the hash confirmed by an operator is the plan hash, or a prefix of it of at
+// least MinApproveHashLength characters
+func (m PlanManifest) Approves(hash string) bool {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	return len(hash) >= MinApproveHashLength && strings.HasPrefix(m.PlanHash, hash)
+}
+
+// Verify checks that the manifest and the phase files in the directory have not changed since the plan
+// was written
+func (m PlanManifest) Verify(dir string) error {
+	if hash := m.Hash(); hash != m.PlanHash {
+		return fmt.Errorf("manifest in %s has changed since the plan was written, plan hash %s does not match %s",
+			dir, hash, m.PlanHash)
+	}
+	for _, phase := range m.Phases {
+		content, err := os.ReadFile(filepath.Join(dir, phase.File))
+		if err != nil {
+			return err
+		}
+		if sum := sha256Hex(content); sum != phase.Sha256 {
+			return fmt.Errorf("%s has changed since the plan was written, checksum %s does not match %s",
+				phase.File, sum, phase.Sha256)
+		}
+	}
+	return nil
+}
+
+func writeJsonFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,150 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutDirPlanWriter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plan")
+	schema := Schema{Database: "d", Tables: []SchemaTable{
+		{Name: "t", Locality: Locality{Type: LocalityTypeRegionalByTable}, Columns: []Column{{Name: "id", Type: "INT8"}}},
+		{Name: "users", Locality: Locality{Type: LocalityTypeGlobal}, Columns: []Column{{Name: "id", Type: "INT8"}}},
+	}}
+	require.NoError(t, OutDirPlanWriter{Dir: dir, Schema: &schema, Rollback: "rollback"}.Write(testPlan()))
+
+	manifest, err := ReadPlanManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "test", manifest.Name)
+	assert.Equal(t, "d", manifest.Database)
+	assert.Equal(t, "rollback", manifest.Rollback)
+
+	// Only the part of the schema the phases change is fingerprinted
+	assert.Equal(t, []string{"t", "d"}, manifest.Targets(nil))
+	assert.Equal(t, []string{"d"}, manifest.Targets([]string{"first"}))
+	assert.Equal(t, schema.Relevant([]string{"t", "d"}).Fingerprint(), manifest.CatalogFingerprint)
+	assert.Len(t, manifest.PlanHash, 64)
+	assert.Equal(t, []string{"summary"}, manifest.Comments)
+	require.Len(t, manifest.Phases, 2)
+	assert.Equal(t, "01_first.sql", manifest.Phases[0].File)
+	assert.Equal(t, 2, manifest.Phases[0].Statements)
+	assert.Equal(t, 1, manifest.Phases[0].Steps)
+	assert.Equal(t, "02_second.sql", manifest.Phases[1].File)
+	assert.Len(t, manifest.Phases[1].Sha256, 64)
+	assert.NoError(t, manifest.Verify(dir))
+
+	// The schema snapshot shows drift before execution
+	snapshot, err := ReadPlanSchema(dir)
+	require.NoError(t, err)
+	assert.Equal(t, schema.Fingerprint(), snapshot.Fingerprint())
+
+	// The plan hash covers the manifest
+	altered := *manifest
+	altered.CatalogFingerprint = "abc"
+	assert.ErrorContains(t, altered.Verify(dir), "manifest in "+dir+" has changed since the plan was written")
+
+	// Phase files are executed as batches
+	batches, err := NewSqlFileParser(filepath.Join(dir, "02_second.sql")).Parse()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"ALTER DATABASE d DROP REGION a;"}}, batches)
+
+	// A plan that runs after another one expects the state recorded by the other plan
+	runsAfter := filepath.Join(dir, "rollback")
+	require.NoError(t, OutDirPlanWriter{Dir: runsAfter, Schema: &schema, RunsAfter: ".."}.Write(testPlan()))
+	manifest, err = ReadPlanManifest(runsAfter)
+	require.NoError(t, err)
+	assert.Equal(t, "..", manifest.RunsAfter)
+	assert.Empty(t, manifest.CatalogFingerprint)
+	snapshot, err = ReadPlanSchema(runsAfter)
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+	manifest, err = ReadPlanManifest(dir)
+	require.NoError(t, err)
+
+	// An existing plan is never overwritten
+	assert.EqualError(t, OutDirPlanWriter{Dir: dir}.Write(testPlan()), dir+" is not empty, refusing to overwrite a plan")
+
+	// Edited phase files no longer match the manifest
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "02_second.sql"), []byte("DROP DATABASE d;\n"), 0644))
+	assert.ErrorContains(t, manifest.Verify(dir), "02_second.sql has changed since the plan was written")
+}
+
+func TestPlanManifestApproves(t *testing.T) {
+	manifest := PlanManifest{CatalogFingerprint: "abc", Phases: []ManifestPhase{{File: "01_first.sql", Sha256: "def"}}}
+	manifest.PlanHash = manifest.Hash()
+
+	assert.True(t, manifest.Approves(manifest.PlanHash))
+	assert.True(t, manifest.Approves(" "+strings.ToUpper(manifest.PlanHash[:MinApproveHashLength])+"\n"))
+	assert.False(t, manifest.Approves(manifest.PlanHash[:MinApproveHashLength-1]))
+	assert.False(t, manifest.Approves(""))
+	assert.False(t, manifest.Approves(strings.Repeat("0", 64)))
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	schema := func() Schema {
+		return Schema{
+			Database: "d",
+			Tables: []SchemaTable{
+				{Name: "users", Locality: Locality{Type: LocalityTypeGlobal}, Columns: []Column{{Name: "id", Type: "INT8"}}},
+				{Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+			},
+			Zones: []ZoneConfig{{Target: "DATABASE d", NumReplicas: 5, Fields: []string{ZoneFieldNumReplicas}}},
+		}
+	}
+
+	fingerprint := schema().Fingerprint()
+	assert.Len(t, fingerprint, 64)
+
+	// Table order does not matter, definitions do
+	reordered := schema()
+	reordered.Tables[0], reordered.Tables[1] = reordered.Tables[1], reordered.Tables[0]
+	assert.Equal(t, fingerprint, reordered.Fingerprint())
+
+	changed := schema()
+	changed.Tables[0].Columns[0].Type = "UUID"
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+
+	changed = schema()
+	changed.Zones[0].NumReplicas = 3
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+}
+
+func TestSchemaRelevant(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	fk := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"user_id"}, ReferencedTable: "users",
+		ReferencedColumns: []string{"id"}}
+	databaseZone := ZoneConfig{Target: "DATABASE d", NumReplicas: 5, Fields: []string{ZoneFieldNumReplicas}}
+	ordersZone := ZoneConfig{Target: "TABLE d.public.orders", NumReplicas: 3, Fields: []string{ZoneFieldNumReplicas}}
+	indexZone := ZoneConfig{Target: "INDEX d.public.orders@orders_user_idx", NumReplicas: 3,
+		Fields: []string{ZoneFieldNumReplicas}}
+	usersZone := ZoneConfig{Target: "TABLE d.public.users", NumReplicas: 3, Fields: []string{ZoneFieldNumReplicas}}
+	schema := Schema{
+		Database: "d",
+		Tables: []SchemaTable{
+			{Name: "users", Locality: rbr, Columns: []Column{{Name: "id", Type: "INT8"}}},
+			{Name: "orders", Locality: rbr, Columns: []Column{{Name: "id", Type: "INT8"}}, FKs: []FKConstraint{fk},
+				Indexes: []Index{{Name: "orders_pkey", Primary: true}}},
+		},
+		Zones: []ZoneConfig{databaseZone, ordersZone, indexZone, usersZone},
+	}
+
+	// Tables keep their locality, FKs and zone configurations, but not their columns and indexes
+	assert.Equal(t, Schema{Database: "d", Tables: []SchemaTable{{Name: "orders", Locality: rbr, FKs: []FKConstraint{fk}}},
+		Zones: []ZoneConfig{ordersZone, indexZone}}, schema.Relevant([]string{"orders"}))
+
+	// Zone configuration targets and the database name only keep the zone configuration
+	assert.Equal(t, Schema{Database: "d", Zones: []ZoneConfig{databaseZone, usersZone}},
+		schema.Relevant([]string{"d", "TABLE d.public.users"}))
+
+	// Changes outside the targets do not change the fingerprint
+	fingerprint := schema.Relevant([]string{"orders"}).Fingerprint()
+	schema.Tables[0].Locality = Locality{Type: LocalityTypeGlobal}
+	schema.Tables[1].Columns[0].Type = "UUID"
+	assert.Equal(t, fingerprint, schema.Relevant([]string{"orders"}).Fingerprint())
+	schema.Zones[2].NumReplicas = 5
+	assert.NotEqual(t, fingerprint, schema.Relevant([]string{"orders"}).Fingerprint())
+}
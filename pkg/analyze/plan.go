@@ -0,0 +1,218 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const ParallelSqlBlockBegin = "-- BEGIN BLOCK"
+const ParallelSqlBlockEnd = "-- END BLOCK"
+const PlanFileStart = "-- FILE START"
+const PlanFileEnd = "-- FILE END"
+
+// KeysetBatchDirective precedes a statement the executor runs in keyset batches until it returns no rows
+const KeysetBatchDirective = "-- KEYSET BATCH"
+
+// PlanRisk is how much damage a statement can do if it goes wrong
+type PlanRisk string
+
+const (
+	// PlanRiskLow statements only change metadata and are cheap to undo
+	PlanRiskLow PlanRisk = "low"
+	// PlanRiskMedium statements rewrite or move data, or change constraints
+	PlanRiskMedium PlanRisk = "medium"
+	// PlanRiskHigh statements remove something that cannot be recovered without a rollback plan or backup
+	PlanRiskHigh PlanRisk = "high"
+)
+
+// Plan is an ordered set of phases that migrate a database. Each phase is written to its own file and
+// its blocks can be executed in parallel with execute parallel.
+type Plan struct {
+	Name     string   `json:"name"`
+	Database string   `json:"database"`
+	Comments []string `json:"comments,omitempty"`
+	Phases   []*Phase `json:"phases"`
+}
+
+// Phase is a step of a plan that must complete before the next phase starts
+type Phase struct {
+	Name     string   `json:"name"`
+	Comments []string `json:"comments,omitempty"`
+	Blocks   []Block  `json:"blocks"`
+	// Completed is the number of steps of the phase that were already done when the plan was generated
+	Completed int `json:"completed,omitempty"`
+}
+
+// Block is a group of statements that run in order on one connection. Blocks of a phase run in parallel.
+// A block without statements only carries comments.
+type Block struct {
+	Comments   []string    `json:"comments,omitempty"`
+	Statements []Statement `json:"statements,omitempty"`
+}
+
+// Statement is a single SQL statement with what it changes and why
+type Statement struct {
+	Sql string `json:"sql"`
+	// Target is the object the statement changes, e.g., a table or zone configuration target
+	Target     string   `json:"target,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Risk       PlanRisk `json:"risk"`
+	Idempotent bool     `json:"idempotent"`
+	// KeysetBatch statements take the last key of the previous batch as parameters and return the last key of
+	// their batch followed by the number of rows changed
+	KeysetBatch bool `json:"keyset_batch,omitempty"`
+}
+
+// NewPlan creates an empty plan for the database
+func NewPlan(name string, database string) *Plan {
+	return &Plan{Name: name, Database: database}
+}
+
+// AddPhase appends a phase with the name and returns it so blocks can be added
+func (p *Plan) AddPhase(name string) *Phase {
+	phase := &Phase{Name: name}
+	p.Phases = append(p.Phases, phase)
+	return phase
+}
+
+// Phase returns the phase with the name
+func (p *Plan) Phase(name string) (*Phase, bool) {
+	for _, phase := range p.Phases {
+		if phase.Name == name {
+			return phase, true
+		}
+	}
+	return nil, false
+}
+
+// Comment adds comments to the top of the plan, outside of any phase
+func (p *Plan) Comment(comments ...string) {
+	p.Comments = append(p.Comments, comments...)
+}
+
+// StatementCount is the number of statements in all phases
+func (p *Plan) StatementCount() int {
+	count := 0
+	for _, phase := range p.Phases {
+		count += phase.StatementCount()
+	}
+	return count
+}
+
+// Progress summarizes how many steps of each phase are done, where each block with statements is a step
+func (p *Plan) Progress() []string {
+	var lines []string
+	for _, phase := range p.Phases {
+		total := phase.Completed + phase.StepCount()
+		lines = append(lines, fmt.Sprintf("Progress: %s %d of %d steps done, %d remaining",
+			phase.Name, phase.Completed, total, phase.StepCount()))
+	}
+	return lines
+}
+
+// Lines renders the plan as SQL lines, with phases between -- FILE START and -- FILE END markers and blocks
+// between -- BEGIN BLOCK and -- END BLOCK markers. Statements are terminated with a semicolon.
+func (p *Plan) Lines() []string {
+	var lines []string
+	lines = append(lines, commentLines(p.Comments)...)
+	for _, phase := range p.Phases {
+		lines = append(lines, fmt.Sprintf("%s %s", PlanFileStart, phase.FileName()))
+		lines = append(lines, phase.Lines()...)
+		lines = append(lines, PlanFileEnd)
+	}
+	return lines
+}
+
+// Json serializes the plan
+func (p *Plan) Json() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// FileName is the name of the file the phase is written to
+func (ph *Phase) FileName() string {
+	return ph.Name + ".sql"
+}
+
+// Comment adds comments to the top of the phase
+func (ph *Phase) Comment(comments ...string) {
+	ph.Comments = append(ph.Comments, comments...)
+}
+
+// AddBlock appends a block of statements preceded by comments
+func (ph *Phase) AddBlock(comments []string, statements ...Statement) {
+	ph.Blocks = append(ph.Blocks, Block{Comments: comments, Statements: statements})
+}
+
+// StatementCount is the number of statements in the phase
+func (ph *Phase) StatementCount() int {
+	count := 0
+	for _, block := range ph.Blocks {
+		count += len(block.Statements)
+	}
+	return count
+}
+
+// StepCount is the number of blocks with statements in the phase
+func (ph *Phase) StepCount() int {
+	count := 0
+	for _, block := range ph.Blocks {
+		if len(block.Statements) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Targets are the objects the statements of the phase change, in statement order
+func (ph *Phase) Targets() []string {
+	var targets []string
+	for _, block := range ph.Blocks {
+		for _, statement := range block.Statements {
+			if statement.Target != "" && !slices.Contains(targets, statement.Target) {
+				targets = append(targets, statement.Target)
+			}
+		}
+	}
+	return targets
+}
+
+// Lines renders the contents of the phase file
+func (ph *Phase) Lines() []string {
+	var lines []string
+	lines = append(lines, commentLines(ph.Comments)...)
+	for _, block := range ph.Blocks {
+		lines = append(lines, block.Lines()...)
+	}
+	return lines
+}
+
+// Lines renders the block comments and its statements between block markers
+func (b Block) Lines() []string {
+	lines := commentLines(b.Comments)
+	if len(b.Statements) == 0 {
+		return lines
+	}
+	lines = append(lines, ParallelSqlBlockBegin)
+	for _, statement := range b.Statements {
+		if statement.KeysetBatch {
+			lines = append(lines, KeysetBatchDirective)
+		}
+		lines = append(lines, statement.Sql+";")
+	}
+	return append(lines, ParallelSqlBlockEnd)
+}
+
+// commentLines prefixes comments with -- unless they already are SQL comments
+func commentLines(comments []string) []string {
+	var lines []string
+	for _, comment := range comments {
+		if strings.HasPrefix(comment, "--") {
+			lines = append(lines, comment)
+		} else {
+			lines = append(lines, "-- "+comment)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,70 @@
+package analyze
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testPlan() *Plan {
+	plan := NewPlan("test", "d")
+	plan.Comment("summary")
+	phase := plan.AddPhase("first")
+	phase.AddBlock([]string{"WARNING: check first"},
+		Statement{Sql: "ALTER TABLE t SET LOCALITY GLOBAL", Target: "t", Reason: "convert", Risk: PlanRiskMedium, Idempotent: true},
+		Statement{Sql: "ALTER TABLE t CONFIGURE ZONE DISCARD", Target: "t", Risk: PlanRiskLow, Idempotent: true})
+	phase.AddBlock([]string{"-- u is already GLOBAL"})
+	plan.AddPhase("second").AddBlock(nil, Statement{Sql: "ALTER DATABASE d DROP REGION a", Target: "d", Risk: PlanRiskHigh})
+	return plan
+}
+
+func TestPlanLines(t *testing.T) {
+	plan := testPlan()
+	assert.Equal(t, 3, plan.StatementCount())
+	assert.Equal(t, []string{
+		"-- summary",
+		"-- FILE START first.sql",
+		"-- WARNING: check first",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE t SET LOCALITY GLOBAL;",
+		"ALTER TABLE t CONFIGURE ZONE DISCARD;",
+		ParallelSqlBlockEnd,
+		"-- u is already GLOBAL",
+		"-- FILE END",
+		"-- FILE START second.sql",
+		ParallelSqlBlockBegin,
+		"ALTER DATABASE d DROP REGION a;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, plan.Lines())
+}
+
+func TestPlanJson(t *testing.T) {
+	b, err := testPlan().Json()
+	require.NoError(t, err)
+
+	var plan Plan
+	require.NoError(t, json.Unmarshal(b, &plan))
+	assert.Equal(t, testPlan(), &plan)
+}
+
+func TestPhaseFilesPlanWriter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, PhaseFilesPlanWriter{Dir: dir}.Write(testPlan()))
+
+	b, err := os.ReadFile(filepath.Join(dir, "default.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "-- summary\n", string(b))
+
+	// Blocks in the phase files are executed as batches
+	batches, err := NewSqlFileParser(filepath.Join(dir, "first.sql")).Parse()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"ALTER TABLE t SET LOCALITY GLOBAL;", "ALTER TABLE t CONFIGURE ZONE DISCARD;"}}, batches)
+
+	// Files of a previous plan are not overwritten
+	assert.EqualError(t, PhaseFilesPlanWriter{Dir: dir}.Write(testPlan()),
+		filepath.Join(dir, "default.sql")+" already exists, refusing to overwrite a plan")
+}
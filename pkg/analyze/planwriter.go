@@ -0,0 +1,105 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PlanWriter writes a plan to a destination
+type PlanWriter interface {
+	Write(plan *Plan) error
+}
+
+// SqlPlanWriter writes the plan as SQL with file and block markers, such as to stdout
+type SqlPlanWriter struct {
+	Out io.Writer
+}
+
+// JsonPlanWriter writes the plan with statement metadata as JSON
+type JsonPlanWriter struct {
+	Out io.Writer
+}
+
+// PhaseFilesPlanWriter writes each phase of the plan to its own file in the directory. Plan comments are
+// written to default.sql. Nothing is written when any of the files already exists.
+type PhaseFilesPlanWriter struct {
+	Dir string
+}
+
+// SingleFilePlanWriter writes the plan as SQL with file and block markers to one file
+type SingleFilePlanWriter struct {
+	Path string
+}
+
+func (w SqlPlanWriter) Write(plan *Plan) error {
+	return writeLines(w.Out, plan.Lines())
+}
+
+func (w JsonPlanWriter) Write(plan *Plan) error {
+	b, err := plan.Json()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w.Out, string(b))
+	return err
+}
+
+func (w PhaseFilesPlanWriter) Write(plan *Plan) error {
+	var names []string
+	var contents [][]string
+	if len(plan.Comments) > 0 {
+		names, contents = append(names, "default.sql"), append(contents, commentLines(plan.Comments))
+	}
+	for _, phase := range plan.Phases {
+		names, contents = append(names, phase.FileName()), append(contents, phase.Lines())
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(w.Dir, name)); err == nil {
+			return fmt.Errorf("%s already exists, refusing to overwrite a plan", filepath.Join(w.Dir, name))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	err := os.MkdirAll(w.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	for i, name := range names {
+		err = writeLinesToFile(filepath.Join(w.Dir, name), contents[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w SingleFilePlanWriter) Write(plan *Plan) error {
+	if dir := filepath.Dir(w.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return writeLinesToFile(w.Path, plan.Lines())
+}
+
+func writeLinesToFile(path string, lines []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeLines(f, lines)
+}
+
+func writeLines(out io.Writer, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(out, strings.Join(lines, "\n")+"\n")
+	return err
+}
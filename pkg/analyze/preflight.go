@@ -0,0 +1,270 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PreflightCheck is a check run before a conversion is generated
+type PreflightCheck string
+
+const (
+	PreflightCheckPrimaryRegion   PreflightCheck = "primary-region"
+	PreflightCheckLocality        PreflightCheck = "locality"
+	PreflightCheckConstraintName  PreflightCheck = "constraint-name"
+	PreflightCheckRegionColumn    PreflightCheck = "region-column"
+	PreflightCheckSchemaChangeJob PreflightCheck = "schema-change-job"
+	PreflightCheckRegionReference PreflightCheck = "region-reference"
+)
+
+// MaxIdentifierLength is the longest identifier, such as a constraint name, that is accepted without truncation
+const MaxIdentifierLength = 63
+
+// PreflightFinding is a problem that would make a generated conversion fail partway through
+type PreflightFinding struct {
+	Check   PreflightCheck
+	Target  string
+	Message string
+}
+
+// SchemaChangeJob is a schema change job that has not finished
+type SchemaChangeJob struct {
+	ID          int64
+	Status      string
+	Description string
+}
+
+// Function is a user-defined function and its CREATE statement
+type Function struct {
+	Schema     string
+	Name       string
+	Definition string
+}
+
+// rbr2rbtPreflightState is everything the rbr2rbt preflight checks look at
+type rbr2rbtPreflightState struct {
+	Regions DatabaseRegions
+	Tables  []Table
+	// AllTables are every table of the database, whose FKs may reference indexes of the selected tables
+	AllTables []Table
+	// Columns of each table find the region columns of tables a previous run already made regional by table
+	Columns map[string][]Column
+	// Constraints are the constraint names of each table
+	Constraints map[string][]string
+	Indexes     map[string][]Index
+	// Jobs are the unfinished schema change jobs on the database, its tables and types
+	Jobs      []SchemaChangeJob
+	Functions []Function
+	Views     []View
+}
+
+func (f PreflightFinding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Check, f.Target, f.Message)
+}
+
+// Rbr2rbtPreflight checks that the rbr2rbt conversion of the selected tables can run to completion. Every
+// finding blocks the conversion. Tables a previous run already made regional by table are only checked for
+// the steps left, so the checks pass again when the conversion is regenerated after partial progress.
+func (a *Analyzer) Rbr2rbtPreflight(config Rbr2rbtConfig) ([]PreflightFinding, error) {
+	var state rbr2rbtPreflightState
+	var err error
+
+	state.Regions, err = a.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := a.Tables(false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	state.Tables = selectRbr2rbtTables(tables, config).Tables
+	state.AllTables = tables
+	state.Columns, err = a.Columns()
+	if err != nil {
+		return nil, err
+	}
+	state.Views, err = a.Views(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	crows, err := a.Db.Constraints(a.Config.Database)
+	if err != nil {
+		return nil, err
+	}
+	state.Constraints = make(map[string][]string)
+	for _, crow := range crows {
+		state.Constraints[crow.Table] = append(state.Constraints[crow.Table], crow.Name)
+	}
+	state.Indexes, err = a.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	jrows, err := a.Db.SchemaChangeJobs(a.Config.Database)
+	if err != nil {
+		return nil, err
+	}
+	for _, jrow := range jrows {
+		state.Jobs = append(state.Jobs, SchemaChangeJob{ID: jrow.ID, Status: jrow.Status, Description: jrow.Description})
+	}
+
+	frows, err := a.Db.Functions(a.Config.Database)
+	if err != nil {
+		return nil, err
+	}
+	for _, frow := range frows {
+		state.Functions = append(state.Functions, Function{Schema: frow.Schema, Name: frow.Name, Definition: frow.CreateStatement})
+	}
+
+	return rbr2rbtPreflight(config.PrimaryRegion, state), nil
+}
+
+func rbr2rbtPreflight(primaryRegion string, state rbr2rbtPreflightState) []PreflightFinding {
+	var findings []PreflightFinding
+	add := func(check PreflightCheck, target string, format string, args ...any) {
+		findings = append(findings, PreflightFinding{Check: check, Target: target, Message: fmt.Sprintf(format, args...)})
+	}
+
+	regions := state.Regions
+	database := regions.Database
+	switch {
+	case !regions.IsMultiRegion():
+		add(PreflightCheckPrimaryRegion, database, "database is not a multi-region database")
+	case !regions.HasRegion(primaryRegion):
+		add(PreflightCheckPrimaryRegion, database, "%s is not a region of the database, regions are [%s]",
+			primaryRegion, strings.Join(regions.RegionNames(), ", "))
+	case regions.PrimaryRegion != primaryRegion:
+		add(PreflightCheckPrimaryRegion, database, "%s is not the primary region, the primary region is %s",
+			primaryRegion, regions.PrimaryRegion)
+	}
+
+	// Region columns of the tables being converted, including those a previous run already made regional by
+	// table, whose type still changes. Secondary indexes on the column are recreated by the conversion, but
+	// the primary key cannot be, and FKs referencing a unique index on the column would be dropped with it.
+	rbr := make(map[string]string)
+	converting := 0
+	fks := keptFKs(state.AllTables, state.Tables)
+	for _, table := range state.Tables {
+		if !rbr2rbtConverting(table, state.Columns[table.Name]) {
+			continue
+		}
+		converting++
+		regionColumn, ok := rbr2rbtRegionColumn(table, state.Columns[table.Name])
+		if !ok {
+			regionColumn = Column{Name: table.Locality.RegionColumnName(), Type: RegionColumnType}
+		}
+		if !isRegionColumnType(regionColumn.Type) {
+			continue
+		}
+		column := regionColumn.Name
+		rbr[table.Name] = column
+		_, _, primary := regionIndexStatements(database, table.Name, state.Indexes[table.Name], column, true)
+		if primary != nil {
+			add(PreflightCheckRegionColumn, table.Name, "primary key %s includes %s, which changes type",
+				primary.Name, column)
+		}
+		for _, fk := range cascadeDroppedFKs(table.Name, state.Indexes[table.Name], column, fks) {
+			add(PreflightCheckRegionColumn, table.Name, "FK %s of %s references a unique index on %s, which is "+
+				"dropped with CASCADE to change the type", fk.Name, fk.Table, column)
+		}
+	}
+	if converting == 0 {
+		add(PreflightCheckLocality, database, "there are no selected REGIONAL BY ROW tables to convert")
+	}
+
+	for _, table := range state.Tables {
+		findings = append(findings, fkNameFindings(table, state.Constraints[table.Name])...)
+	}
+
+	// Jobs are already limited to those changing the database, its tables or types
+	for _, job := range state.Jobs {
+		add(PreflightCheckSchemaChangeJob, fmt.Sprintf("job %d", job.ID), "%s schema change must finish first: %s",
+			job.Status, job.Description)
+	}
+
+	// Objects referencing the region column block changing its type
+	var columns []string
+	for _, table := range state.Tables {
+		if column, ok := rbr[table.Name]; ok && !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	for _, view := range state.Views {
+		for _, dep := range view.DependsOn {
+			if column, ok := rbr[dep]; ok && columnReferenceRe(column).MatchString(view.Definition) {
+				add(PreflightCheckRegionReference, view.Name, "%s references %s, which changes type", view.Kind, column)
+				break
+			}
+		}
+	}
+	for _, function := range state.Functions {
+		for _, column := range columns {
+			if columnReferenceRe(column).MatchString(function.Definition) {
+				add(PreflightCheckRegionReference, function.Name, "function references %s, which changes type", column)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Check < findings[j].Check
+	})
+	return findings
+}
+
+// fkNameFindings checks the names of the FKs without the region that replace the table's region FKs. A name
+// that is already taken by another constraint would make ADD CONSTRAINT IF NOT EXISTS skip the FK.
+func fkNameFindings(table Table, constraints []string) []PreflightFinding {
+	var findings []PreflightFinding
+	generated := make(map[string]string)
+	for i, fk := range table.FKs {
+		if !fk.RegionRestricted {
+			continue
+		}
+		redundant := false
+		for j, other := range table.FKs {
+			if i != j && fk.IsRedundantWith(other) {
+				redundant = true
+				break
+			}
+		}
+		if redundant {
+			continue
+		}
+
+		name := fk.GenerateNameNoRegion()
+		target := fmt.Sprintf("%s.%s", table.Name, fk.Name)
+		if len(name) > MaxIdentifierLength {
+			findings = append(findings, PreflightFinding{Check: PreflightCheckConstraintName, Target: target,
+				Message: fmt.Sprintf("replacement FK name %s is %d characters, over the limit of %d",
+					name, len(name), MaxIdentifierLength)})
+		}
+		if other, ok := generated[name]; ok {
+			findings = append(findings, PreflightFinding{Check: PreflightCheckConstraintName, Target: target,
+				Message: fmt.Sprintf("replacement FK name %s is also generated for %s", name, other)})
+		}
+		generated[name] = fk.Name
+		for _, constraint := range constraints {
+			if constraint == name && !isReplacementFK(table.FKs, fk, name) {
+				findings = append(findings, PreflightFinding{Check: PreflightCheckConstraintName, Target: target,
+					Message: fmt.Sprintf("replacement FK name %s is already used by another constraint", name)})
+			}
+		}
+	}
+	return findings
+}
+
+// isReplacementFK determines whether the constraint with the name is the FK without the region that replaces
+// the region FK, such as when a previous conversion was interrupted
+func isReplacementFK(fks []FKConstraint, fk FKConstraint, name string) bool {
+	for _, other := range fks {
+		if other.Name == name && !other.RegionRestricted && other.ReferencedTable == fk.ReferencedTable &&
+			equalSlices(other.Columns, fk.ColumnsNoRegion) && equalSlices(other.ReferencedColumns, fk.ReferencedColumnsNoRegion) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRbr2rbtPreflight(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regions := DatabaseRegions{Database: "d", PrimaryRegion: "us-east1",
+		Regions: []Region{{Name: "us-east1", Primary: true}, {Name: "us-west1"}}}
+	regionFK := func(table string, name string, column string) FKConstraint {
+		return FKConstraint{Name: name, Table: table, Columns: []string{"crdb_region", column}, ReferencedTable: "users",
+			ReferencedColumns: []string{"crdb_region", "id"}, UpdateRule: RuleCascade, DeleteRule: RuleNoAction,
+			RegionRestricted: true, ColumnsNoRegion: []string{column}, ReferencedColumnsNoRegion: []string{"id"}}
+	}
+	long := strings.Repeat("x", 60)
+
+	state := rbr2rbtPreflightState{
+		Regions: regions,
+		Tables: []Table{
+			{Database: "d", Name: "users", Locality: rbr},
+			{Database: "d", Name: "orders", Locality: rbr, FKs: []FKConstraint{regionFK("orders", "fk_user", "user_id")}},
+			{Database: "d", Name: "items", Locality: rbr, FKs: []FKConstraint{regionFK("items", "fk_"+long, long)}},
+			{Database: "d", Name: "custom", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}},
+		},
+		Constraints: map[string][]string{"orders": {"orders_pkey", "fk_user", "orders_user_id_fkey"}},
+		Indexes: map[string][]Index{"custom": {
+			{Name: "custom_pkey", Primary: true, Definition: "CREATE UNIQUE INDEX custom_pkey ON public.custom USING btree (region ASC, id ASC)"},
+			{Name: "custom_region_idx", Definition: "CREATE INDEX custom_region_idx ON public.custom USING btree (region ASC)"},
+		}},
+		Jobs: []SchemaChangeJob{
+			{ID: 1, Status: "running", Description: "ALTER TABLE d.public.orders ADD COLUMN note STRING"},
+			{ID: 2, Status: "paused", Description: "CREATE INDEX ON orders (note)"},
+		},
+		Functions: []Function{{Schema: "public", Name: "home", Definition: "CREATE FUNCTION home() ... SELECT crdb_region FROM users"}},
+		Views: []View{
+			{Name: "v", Kind: ObjectKindView, Definition: "SELECT id, crdb_region FROM d.public.users", DependsOn: []string{"users"}},
+			{Name: "w", Kind: ObjectKindView, Definition: "SELECT id FROM d.public.users", DependsOn: []string{"users"}},
+		},
+	}
+
+	findings := rbr2rbtPreflight("us-west1", state)
+	assert.Equal(t, []PreflightFinding{
+		{Check: PreflightCheckConstraintName, Target: "orders.fk_user",
+			Message: "replacement FK name orders_user_id_fkey is already used by another constraint"},
+		{Check: PreflightCheckConstraintName, Target: "items.fk_" + long,
+			Message: "replacement FK name items_" + long + "_fkey is 71 characters, over the limit of 63"},
+		{Check: PreflightCheckPrimaryRegion, Target: "d", Message: "us-west1 is not the primary region, the primary region is us-east1"},
+		{Check: PreflightCheckRegionColumn, Target: "custom",
+			Message: "primary key custom_pkey includes region, which changes type"},
+		{Check: PreflightCheckRegionReference, Target: "v", Message: "view references crdb_region, which changes type"},
+		{Check: PreflightCheckRegionReference, Target: "home", Message: "function references crdb_region, which changes type"},
+		{Check: PreflightCheckSchemaChangeJob, Target: "job 1",
+			Message: "running schema change must finish first: ALTER TABLE d.public.orders ADD COLUMN note STRING"},
+		{Check: PreflightCheckSchemaChangeJob, Target: "job 2",
+			Message: "paused schema change must finish first: CREATE INDEX ON orders (note)"},
+	}, findings)
+
+	// A replacement FK left by an interrupted conversion is not a collision
+	state.Tables[1].FKs = append(state.Tables[1].FKs, FKConstraint{Name: "orders_user_id_fkey", Table: "orders",
+		Columns: []string{"user_id"}, ReferencedTable: "users", ReferencedColumns: []string{"id"},
+		UpdateRule: RuleNoAction, DeleteRule: RuleCascade, ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}})
+	state.Tables = state.Tables[:3]
+	state.Jobs, state.Functions, state.Views = nil, nil, nil
+	state.Tables[2].FKs = nil
+	assert.Empty(t, rbr2rbtPreflight("us-east1", state))
+
+	// FKs left in place that reference a unique index on the region column would be dropped with the index,
+	// while region FKs of the selected tables are replaced before the type changes
+	state.Indexes = map[string][]Index{"users": {{Name: "users_region_id_key",
+		Definition: "CREATE UNIQUE INDEX users_region_id_key ON public.users USING btree (crdb_region ASC, id ASC)"}}}
+	auditFK := FKConstraint{Name: "fk_audit_user", Table: "audit", Columns: []string{"region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"id", "crdb_region"}}
+	state.AllTables = append(state.Tables, Table{Database: "d", Name: "audit", FKs: []FKConstraint{auditFK}})
+	assert.Equal(t, []PreflightFinding{
+		{Check: PreflightCheckRegionColumn, Target: "users", Message: "FK fk_audit_user of audit references a unique " +
+			"index on crdb_region, which is dropped with CASCADE to change the type"},
+	}, rbr2rbtPreflight("us-east1", state))
+
+	assert.Equal(t, []PreflightFinding{
+		{Check: PreflightCheckLocality, Target: "d", Message: "there are no selected REGIONAL BY ROW tables to convert"},
+		{Check: PreflightCheckPrimaryRegion, Target: "d", Message: "ap-south1 is not a region of the database, regions are [us-east1, us-west1]"},
+	}, rbr2rbtPreflight("ap-south1", rbr2rbtPreflightState{Regions: regions}))
+}
+
+func TestRbr2rbtPreflightRerun(t *testing.T) {
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+	regions := DatabaseRegions{Database: "d", PrimaryRegion: "us-east1",
+		Regions: []Region{{Name: "us-east1", Primary: true}, {Name: "us-west1"}}}
+
+	// A previous run made both tables regional by table and changed the type of the users region column
+	state := rbr2rbtPreflightState{
+		Regions: regions,
+		Tables:  []Table{{Database: "d", Name: "users", Locality: rbt}, {Database: "d", Name: "orders", Locality: rbt}},
+		Columns: map[string][]Column{
+			"users": {{Name: "crdb_region", Type: "STRING",
+				Default: "default_to_database_primary_region(gateway_region())::STRING"}},
+			"orders": {{Name: "crdb_region", Type: RegionColumnType}},
+		},
+		Indexes: map[string][]Index{
+			"users": {{Name: "users_pkey", Primary: true,
+				Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (crdb_region ASC, id ASC)"}},
+			"orders": {{Name: "orders_pkey", Primary: true,
+				Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (crdb_region ASC, id ASC)"}},
+		},
+		Views: []View{
+			{Name: "v", Kind: ObjectKindView, Definition: "SELECT id, crdb_region FROM d.public.users", DependsOn: []string{"users"}},
+			{Name: "w", Kind: ObjectKindView, Definition: "SELECT id, crdb_region FROM d.public.orders", DependsOn: []string{"orders"}},
+		},
+	}
+	state.AllTables = state.Tables
+
+	// Only the column whose type is still to change is checked
+	assert.Equal(t, []PreflightFinding{
+		{Check: PreflightCheckRegionColumn, Target: "orders",
+			Message: "primary key orders_pkey includes crdb_region, which changes type"},
+		{Check: PreflightCheckRegionReference, Target: "w", Message: "view references crdb_region, which changes type"},
+	}, rbr2rbtPreflight("us-east1", state))
+
+	// Once the conversion is done, a rerun has nothing to block
+	state.Columns["orders"] = state.Columns["users"]
+	assert.Empty(t, rbr2rbtPreflight("us-east1", state))
+
+	// Regional by table tables without evidence of a conversion are not converted
+	state.Columns = map[string][]Column{"users": {{Name: "crdb_region", Type: "STRING"}}}
+	assert.Equal(t, []PreflightFinding{
+		{Check: PreflightCheckLocality, Target: "d", Message: "there are no selected REGIONAL BY ROW tables to convert"},
+	}, rbr2rbtPreflight("us-east1", state))
+}
@@ -0,0 +1,323 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EstimateRates are the throughputs used to turn the data a plan moves, scans and rewrites into durations.
+// They depend on the hardware, the number of nodes and the load on the cluster, so the defaults are only a
+// starting point.
+type EstimateRates struct {
+	// MoveBytesPerSecond is the rate replicas are rebalanced into the primary region
+	MoveBytesPerSecond uint64
+	// ScanBytesPerSecond is the rate an FK validation scans the referencing table
+	ScanBytesPerSecond uint64
+	// RewriteBytesPerSecond is the rate a schema change backfills a new primary index
+	RewriteBytesPerSecond uint64
+	// StatementOverhead is the time each statement takes regardless of the data, such as waiting for a
+	// schema change job to start and for leases to be released
+	StatementOverhead time.Duration
+	// RangeOverhead is the time added for each range a statement moves, scans or rewrites
+	RangeOverhead time.Duration
+}
+
+// DefaultEstimateRates are conservative rates for a busy cluster
+var DefaultEstimateRates = EstimateRates{
+	MoveBytesPerSecond:    32 << 20,
+	ScanBytesPerSecond:    64 << 20,
+	RewriteBytesPerSecond: 16 << 20,
+	StatementOverhead:     5 * time.Second,
+	RangeOverhead:         100 * time.Millisecond,
+}
+
+// TableEstimate is the work a plan does on a table across all phases
+type TableEstimate struct {
+	Table Table
+	// MovedBytes is the logical bytes of replicas moved into the primary region
+	MovedBytes     uint64
+	ScannedBytes   uint64
+	RewrittenBytes uint64
+	FKValidations  int
+	Rewrites       int
+	Duration       time.Duration
+}
+
+// PhaseEstimate is the work and duration of a phase
+type PhaseEstimate struct {
+	Phase          string
+	Steps          int
+	MovedBytes     uint64
+	ScannedBytes   uint64
+	RewrittenBytes uint64
+	// Serial is the duration when steps run one at a time
+	Serial time.Duration
+	// Longest is the longest step, which bounds the phase no matter the concurrency
+	Longest time.Duration
+	// Parallel is the duration when steps run with the concurrency of the estimate
+	Parallel time.Duration
+	// UsefulConcurrency is the lowest concurrency that reaches the shortest duration of the phase
+	UsefulConcurrency int
+}
+
+// PlanEstimate is the estimated duration and data movement of a plan, by phase and by table
+type PlanEstimate struct {
+	Concurrency int
+	Rates       EstimateRates
+	Phases      []PhaseEstimate
+	Tables      []TableEstimate
+}
+
+// statementWork is the work and duration of a single statement on a table
+type statementWork struct {
+	table          string
+	movedBytes     uint64
+	scannedBytes   uint64
+	rewrittenBytes uint64
+	fkValidation   bool
+	rewrite        bool
+	duration       time.Duration
+}
+
+func (e TableEstimate) String() string {
+	return fmt.Sprintf("Estimate: table %s, Logical Size: %s, Ranges: %d, Moves: %s, Scans: %s, Rewrites: %s, "+
+		"FK Validations: %d, Type/Locality Rewrites: %d, Duration: %s",
+		e.Table.Name, formatBytes(e.Table.LogicalSizeBytes), e.Table.RangeCount, formatBytes(e.MovedBytes),
+		formatBytes(e.ScannedBytes), formatBytes(e.RewrittenBytes), e.FKValidations, e.Rewrites, e.Duration)
+}
+
+func (e PhaseEstimate) String() string {
+	return fmt.Sprintf("Estimate: phase %s, Steps: %d, Moves: %s, Scans: %s, Rewrites: %s, Serial: %s, "+
+		"Longest Step: %s, Parallel: %s, Useful Concurrency: %d",
+		e.Phase, e.Steps, formatBytes(e.MovedBytes), formatBytes(e.ScannedBytes), formatBytes(e.RewrittenBytes),
+		e.Serial, e.Longest, e.Parallel, e.UsefulConcurrency)
+}
+
+// Total is the duration of all phases, which run one after the other, with the concurrency of the estimate
+func (e PlanEstimate) Total() time.Duration {
+	var total time.Duration
+	for _, phase := range e.Phases {
+		total += phase.Parallel
+	}
+	return total
+}
+
+// Comments describes the estimate for the top of the plan
+func (e PlanEstimate) Comments() []string {
+	comments := []string{fmt.Sprintf("Estimate: %s total with execute parallel concurrency %d, moving %s/s, "+
+		"scanning %s/s and rewriting %s/s", e.Total(), e.Concurrency, formatBytes(e.Rates.MoveBytesPerSecond),
+		formatBytes(e.Rates.ScanBytesPerSecond), formatBytes(e.Rates.RewriteBytesPerSecond))}
+	for _, phase := range e.Phases {
+		comments = append(comments, phase.String())
+	}
+	for _, table := range e.Tables {
+		comments = append(comments, table.String())
+	}
+	return comments
+}
+
+// Rbr2rbtEstimate estimates the duration and data movement of a generated rbr2rbt plan from the size and
+// range count of each table, with steps run at the concurrency used by execute parallel
+func (c *Converter) Rbr2rbtEstimate(plan *Plan, rates EstimateRates, concurrency int) (PlanEstimate, error) {
+	regions, err := c.Analyzer.DatabaseRegions()
+	if err != nil {
+		return PlanEstimate{}, err
+	}
+	tables, err := c.Analyzer.Tables(true, false, nil)
+	if err != nil {
+		return PlanEstimate{}, err
+	}
+	return estimateRbr2rbtPlan(plan, regions, tables, rates, concurrency), nil
+}
+
+func estimateRbr2rbtPlan(plan *Plan, regions DatabaseRegions, tables []Table, rates EstimateRates, concurrency int) PlanEstimate {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	estimate := PlanEstimate{Concurrency: concurrency, Rates: rates}
+
+	byName := make(map[string]Table)
+	localities := make(map[string]Locality)
+	for _, t := range tables {
+		byName[t.Name] = t
+		localities[t.Name] = t.Locality
+	}
+	tableEstimates := make(map[string]*TableEstimate)
+
+	for _, phase := range plan.Phases {
+		// Zone configuration changes of a table or its partitions take precedence over the database
+		covered := make(map[string]bool)
+		if phase.Name == "zoneconfig" {
+			for _, block := range phase.Blocks {
+				for _, statement := range block.Statements {
+					if target, err := ParseZoneTarget(statement.Target); err == nil && target.Type != ZoneTargetTypeDatabase {
+						covered[target.Table] = true
+					}
+				}
+			}
+		}
+
+		phaseEstimate := PhaseEstimate{Phase: phase.Name, Steps: phase.StepCount()}
+		var durations []time.Duration
+		for _, block := range phase.Blocks {
+			if len(block.Statements) == 0 {
+				continue
+			}
+			var blockDuration time.Duration
+			for _, statement := range block.Statements {
+				// Discarding zone configurations in zone_config_discard is estimated as a metadata change, since
+				// the zoneconfig phase already counts the replicas of every table moving to the primary region
+				var works []statementWork
+				if phase.Name == "zoneconfig" {
+					works = rates.zoneMoveWork(statement, regions, tables, localities, covered)
+				} else {
+					works = []statementWork{rates.schemaChangeWork(phase.Name, statement, byName[statement.Target])}
+				}
+				for _, work := range works {
+					blockDuration += work.duration
+					phaseEstimate.MovedBytes += work.movedBytes
+					phaseEstimate.ScannedBytes += work.scannedBytes
+					phaseEstimate.RewrittenBytes += work.rewrittenBytes
+
+					t, ok := byName[work.table]
+					if !ok {
+						continue
+					}
+					te, ok := tableEstimates[t.Name]
+					if !ok {
+						te = &TableEstimate{Table: t}
+						tableEstimates[t.Name] = te
+					}
+					te.MovedBytes += work.movedBytes
+					te.ScannedBytes += work.scannedBytes
+					te.RewrittenBytes += work.rewrittenBytes
+					te.Duration += work.duration
+					if work.fkValidation {
+						te.FKValidations++
+					}
+					if work.rewrite {
+						te.Rewrites++
+					}
+				}
+			}
+			durations = append(durations, blockDuration)
+			phaseEstimate.Serial += blockDuration
+			phaseEstimate.Longest = max(phaseEstimate.Longest, blockDuration)
+		}
+
+		phaseEstimate.Parallel = scheduledDuration(durations, concurrency)
+		shortest := scheduledDuration(durations, len(durations))
+		for phaseEstimate.UsefulConcurrency = 1; phaseEstimate.UsefulConcurrency < len(durations); phaseEstimate.UsefulConcurrency++ {
+			if scheduledDuration(durations, phaseEstimate.UsefulConcurrency) == shortest {
+				break
+			}
+		}
+		estimate.Phases = append(estimate.Phases, phaseEstimate)
+	}
+
+	for _, te := range tableEstimates {
+		estimate.Tables = append(estimate.Tables, *te)
+	}
+	sort.Slice(estimate.Tables, func(i, j int) bool {
+		if estimate.Tables[i].Duration != estimate.Tables[j].Duration {
+			return estimate.Tables[i].Duration > estimate.Tables[j].Duration
+		}
+		return estimate.Tables[i].Table.Name < estimate.Tables[j].Table.Name
+	})
+	return estimate
+}
+
+// zoneMoveWork estimates the replicas a zone configuration change moves into the primary region. The voters
+// of rows homed in other regions move, while non-voters are only removed. A partition holds the rows of its
+// region, assuming rows are spread evenly over the regions, and the database zone configuration applies to
+// the tables without changes of their own.
+func (r EstimateRates) zoneMoveWork(statement Statement, regions DatabaseRegions, tables []Table,
+	localities map[string]Locality, covered map[string]bool) []statementWork {
+	target, err := ParseZoneTarget(statement.Target)
+	if err != nil {
+		return []statementWork{{duration: r.StatementOverhead}}
+	}
+	numRegions := uint64(max(len(regions.Regions), 1))
+
+	var works []statementWork
+	for _, t := range tables {
+		// Fractions of the table in regions other than the primary region
+		var moved, total uint64
+		switch {
+		case target.Type == ZoneTargetTypeDatabase && !covered[t.Name]:
+			moved, total = numRegions-1, numRegions
+		case target.Type == ZoneTargetTypePartition && target.Table == t.Name && target.Partition != regions.PrimaryRegion:
+			moved, total = 1, numRegions
+		case (target.Type == ZoneTargetTypeTable || target.Type == ZoneTargetTypeIndex) && target.Table == t.Name:
+			moved, total = numRegions-1, numRegions
+		default:
+			continue
+		}
+		tableTarget := ZoneTarget{Type: ZoneTargetTypeTable, Database: t.Database, Table: t.Name}
+		voters := uint64(expectedZoneConfig(regions, tableTarget, localities).NumVoters)
+		bytes := t.LogicalSizeBytes * voters * moved / total
+		ranges := t.RangeCount * int(moved) / int(total)
+		works = append(works, statementWork{table: t.Name, movedBytes: bytes,
+			duration: bytesDuration(bytes, r.MoveBytesPerSecond) + time.Duration(ranges)*r.RangeOverhead})
+	}
+
+	// The statement itself takes time even when nothing moves
+	if len(works) == 0 {
+		return []statementWork{{table: target.Table, duration: r.StatementOverhead}}
+	}
+	works[0].duration += r.StatementOverhead
+	return works
+}
+
+// schemaChangeWork estimates a statement of a phase other than zoneconfig on the table. Adding an FK scans the
+// referencing table to validate it. Changing the locality or the region column type, recreating an index on
+// the region column and rehoming rows rewrite the table. Other statements only change metadata.
+func (r EstimateRates) schemaChangeWork(phase string, statement Statement, t Table) statementWork {
+	work := statementWork{table: statement.Target, duration: r.StatementOverhead}
+	rangeDuration := time.Duration(t.RangeCount) * r.RangeOverhead
+	switch {
+	case phase == "fk" && strings.Contains(statement.Sql, "ADD CONSTRAINT"):
+		work.fkValidation = true
+		work.scannedBytes = t.LogicalSizeBytes
+		work.duration += bytesDuration(t.LogicalSizeBytes, r.ScanBytesPerSecond) + rangeDuration
+	case phase == "table_locality", phase == "rehome_rows", phase == "change_crdb_region_type" &&
+		(strings.Contains(statement.Sql, "SET DATA TYPE") || strings.HasPrefix(statement.Sql, "CREATE")):
+		work.rewrite = true
+		work.rewrittenBytes = t.LogicalSizeBytes
+		work.duration += bytesDuration(t.LogicalSizeBytes, r.RewriteBytesPerSecond) + rangeDuration
+	}
+	return work
+}
+
+// bytesDuration is the time to process the bytes at the rate, rounded to the second
+func bytesDuration(bytes uint64, bytesPerSecond uint64) time.Duration {
+	if bytesPerSecond == 0 {
+		return 0
+	}
+	return time.Duration(float64(bytes) / float64(bytesPerSecond) * float64(time.Second)).Round(time.Second)
+}
+
+// scheduledDuration is how long the steps take when execute parallel runs them in order, each starting on
+// the first connection that is free
+func scheduledDuration(durations []time.Duration, concurrency int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	workers := make([]time.Duration, max(concurrency, 1))
+	for _, d := range durations {
+		first := 0
+		for i := range workers {
+			if workers[i] < workers[first] {
+				first = i
+			}
+		}
+		workers[first] += d
+	}
+	var longest time.Duration
+	for _, w := range workers {
+		longest = max(longest, w)
+	}
+	return longest
+}
@@ -0,0 +1,56 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEstimateRbr2rbtPlan(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regionFK := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"crdb_region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"crdb_region", "id"}, RegionRestricted: true,
+		ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr, LogicalSizeBytes: 10 << 20, RangeCount: 1},
+		{Database: "d", Name: "orders", Locality: rbr, LogicalSizeBytes: 20 << 20, RangeCount: 2, FKs: []FKConstraint{regionFK}},
+	}
+	columns := map[string][]Column{
+		"users":  {{Name: "crdb_region", Type: RegionColumnType}},
+		"orders": {{Name: "crdb_region", Type: RegionColumnType}},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", "num_replicas = 3, lease_preferences = '[[+region=b]]'"),
+		testZoneConfig(t, "TABLE d.public.orders", "gc.ttlseconds = 600, lease_preferences = '[[+region=b]]'"),
+	}
+	regions := DatabaseRegions{Database: "d", PrimaryRegion: "a", Regions: []Region{{Name: "a"}, {Name: "b"}},
+		SurvivalGoal: SurvivalGoalZone}
+	rates := EstimateRates{MoveBytesPerSecond: 1 << 20, ScanBytesPerSecond: 1 << 20, RewriteBytesPerSecond: 1 << 20,
+		StatementOverhead: time.Second}
+
+	plan := rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, tables, columns, nil, nil)
+	estimate := estimateRbr2rbtPlan(plan, regions, tables, rates, 2)
+
+	// Voters of the rows homed in b move, users through the database and orders through its own zone
+	assert.Equal(t, PhaseEstimate{Phase: "zoneconfig", Steps: 2, MovedBytes: 45 << 20, Serial: 47 * time.Second,
+		Longest: 31 * time.Second, Parallel: 31 * time.Second, UsefulConcurrency: 2}, estimate.Phases[0])
+	assert.Equal(t, PhaseEstimate{Phase: "fk", Steps: 1, ScannedBytes: 20 << 20, Serial: 22 * time.Second,
+		Longest: 22 * time.Second, Parallel: 22 * time.Second, UsefulConcurrency: 1}, estimate.Phases[1])
+	assert.Equal(t, PhaseEstimate{Phase: "table_locality", Steps: 2, RewrittenBytes: 30 << 20, Serial: 32 * time.Second,
+		Longest: 21 * time.Second, Parallel: 21 * time.Second, UsefulConcurrency: 2}, estimate.Phases[2])
+	assert.Equal(t, 22*time.Second, estimate.Phases[3].Parallel)
+	assert.Equal(t, time.Second, estimate.Phases[4].Parallel)
+	assert.Equal(t, 97*time.Second, estimate.Total())
+
+	assert.Equal(t, []TableEstimate{
+		{Table: tables[1], MovedBytes: 30 << 20, ScannedBytes: 20 << 20, RewrittenBytes: 40 << 20, FKValidations: 1,
+			Rewrites: 2, Duration: 97 * time.Second},
+		{Table: tables[0], MovedBytes: 15 << 20, RewrittenBytes: 20 << 20, Rewrites: 2, Duration: 39 * time.Second},
+	}, estimate.Tables)
+
+	// One connection runs the steps one after the other
+	estimate = estimateRbr2rbtPlan(plan, regions, tables, rates, 1)
+	assert.Equal(t, 47*time.Second, estimate.Phases[0].Parallel)
+	assert.Equal(t, "Estimate: 2m16s total with execute parallel concurrency 1, moving 1.0 MB/s, scanning 1.0 MB/s "+
+		"and rewriting 1.0 MB/s", estimate.Comments()[0])
+}
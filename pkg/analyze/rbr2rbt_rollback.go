@@ -0,0 +1,184 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rbr2rbtRollbackPlan generates the plan that undoes Rbr2rbtPlan, built from the state before the conversion.
+// It must be generated at the same time as the conversion plan, before any of the conversion runs. Phases undo
+// the conversion in reverse order: the region column type and default, the table localities, the FKs and
+// finally the zone configurations. With a filter, only the selected tables are rolled back.
+func (c *Converter) Rbr2rbtRollbackPlan(config Rbr2rbtConfig) (*Plan, error) {
+	zones, err := c.Analyzer.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := c.Analyzer.Tables(false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.Analyzer.Columns()
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := c.Analyzer.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	selection := selectRbr2rbtTables(tables, config)
+	plan := rbr2rbtRollbackPlan(c.Config.Database, config.PrimaryRegion, selection.Tables, tables, columns, indexes,
+		selection.Zones(zones))
+	if config.RehomeRows {
+		plan.Comment("WARNING: rows rehomed to the primary region stay in the primary region after the rollback")
+	}
+	return plan, nil
+}
+
+func rbr2rbtRollbackPlan(database string, primaryRegion string, tables []Table, allTables []Table, columns map[string][]Column,
+	indexes map[string][]Index, zones []ZoneConfig) *Plan {
+	plan := NewPlan("rbr2rbt-rollback", database)
+
+	// Tables a previous run already converted no longer show their state before the conversion
+	var converted []string
+	for _, table := range tables {
+		if !table.Locality.IsRegionalByRow() && rbr2rbtConverting(table, columns[table.Name]) {
+			converted = append(converted, quoteIdentifier(table.Name))
+		}
+	}
+	if len(converted) > 0 {
+		plan.Comment(fmt.Sprintf("WARNING: %s already converted to regional by table by a previous run and left "+
+			"out of the rollback, use the rollback generated before that run", strings.Join(converted, ", ")))
+	}
+
+	// Indexes on the region column are dropped again and recreated with their original definitions
+	phase := plan.AddPhase("rollback_change_crdb_region_type")
+	fks := keptFKs(allTables, tables)
+	for _, table := range tables {
+		if !table.Locality.IsRegionalByRow() {
+			continue
+		}
+		name := quoteIdentifierWithDatabase(table.Database, table.Name)
+		column, ok := rbr2rbtRegionColumn(table, columns[table.Name])
+		if !ok {
+			column = Column{Name: table.Locality.RegionColumnName(), Type: RegionColumnType}
+		}
+		columnName := quoteIdentifier(column.Name)
+
+		var comments []string
+		for _, fk := range cascadeDroppedFKs(table.Name, indexes[table.Name], column.Name, fks) {
+			comments = append(comments, fmt.Sprintf("WARNING: %s of %s references a unique index on %s, which is "+
+				"dropped with CASCADE and drops the FK", quoteIdentifier(fk.Name), quoteIdentifier(fk.Table),
+				columnName))
+		}
+		drops, creates, primary := regionIndexStatements(database, table.Name, indexes[table.Name], column.Name, false)
+		if primary != nil {
+			comments = append(comments, fmt.Sprintf("primary key %s includes %s, the conversion does not change its type",
+				quoteIdentifier(primary.Name), columnName))
+			phase.AddBlock(comments)
+			continue
+		}
+		if column.Computed != "" {
+			comments = append(comments, fmt.Sprintf("WARNING: %s was computed as %s, which cannot be restored in place",
+				columnName, column.Computed))
+		}
+
+		statements := append(drops, Statement{
+			Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::%s",
+				name, columnName, column.Type, columnName, column.Type),
+			Target: table.Name, Reason: "restore the region column type", Risk: PlanRiskMedium, Idempotent: true,
+		})
+		if column.Default != "" {
+			statements = append(statements, Statement{
+				Sql:    fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s", name, columnName, column.Default),
+				Target: table.Name, Reason: "restore the region column default", Risk: PlanRiskLow, Idempotent: true,
+			})
+		} else {
+			statements = append(statements, Statement{
+				Sql:    fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", name, columnName),
+				Target: table.Name, Reason: "restore the region column default", Risk: PlanRiskLow, Idempotent: true,
+			})
+		}
+		phase.AddBlock(comments, append(statements, creates...)...)
+	}
+
+	phase = plan.AddPhase("rollback_table_locality")
+	for _, table := range tables {
+		if !table.Locality.IsRegionalByRow() {
+			continue
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s",
+			quoteIdentifierWithDatabase(table.Database, table.Name), table.Locality)
+		phase.AddBlock(nil, Statement{Sql: sql, Target: table.Name, Reason: "restore the locality",
+			Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	// Each region FK replaced by the conversion is added back with its original columns and rules, then the
+	// FK without the region is dropped unless it already existed before the conversion
+	phase = plan.AddPhase("rollback_fk")
+	for _, table := range tables {
+		for i, fk := range table.FKs {
+			if !fk.RegionRestricted {
+				continue
+			}
+			isRedundant := false
+			for j, other := range table.FKs {
+				if i != j && fk.IsRedundantWith(other) {
+					isRedundant = true
+					break
+				}
+			}
+
+			addSql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT IF NOT EXISTS %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+				quoteIdentifierWithDatabase(database, fk.Table),
+				quoteIdentifier(fk.Name),
+				quoteAndJoinIdentifiers(fk.Columns),
+				quoteIdentifier(fk.ReferencedTable),
+				quoteAndJoinIdentifiers(fk.ReferencedColumns))
+			if fk.UpdateRule != "" {
+				addSql = fmt.Sprintf("%s ON UPDATE %s", addSql, fk.UpdateRule)
+			}
+			if fk.DeleteRule != "" {
+				addSql = fmt.Sprintf("%s ON DELETE %s", addSql, fk.DeleteRule)
+			}
+			var comments []string
+			statements := []Statement{{Sql: addSql, Target: fk.Table, Reason: fmt.Sprintf("restore %s", fk.Name),
+				Risk: PlanRiskMedium, Idempotent: true}}
+			if isRedundant {
+				comments = append(comments, "not dropping the FK without the region since it existed before")
+			} else {
+				statements = append(statements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s",
+					quoteIdentifierWithDatabase(database, fk.Table), quoteIdentifier(fk.GenerateNameNoRegion())),
+					Target: fk.Table, Reason: "drop the FK added by the conversion", Risk: PlanRiskHigh, Idempotent: true})
+			}
+			phase.AddBlock(comments, statements...)
+		}
+	}
+
+	// Table and index zone configurations were discarded and partitions are recreated with the regional by row
+	// locality, so they are set in full. Other zone configurations that rbr2rbt moved to the primary region
+	// are moved back.
+	phase = plan.AddPhase("rollback_zoneconfig")
+	for _, zc := range zones {
+		if len(zc.Fields) == 0 {
+			continue
+		}
+		target, err := zc.ParsedTarget()
+		if err == nil && (target.Type == ZoneTargetTypeTable || target.Type == ZoneTargetTypeIndex ||
+			target.Type == ZoneTargetTypePartition) {
+			phase.AddBlock(nil, Statement{Sql: zc.Sql(), Target: zc.Target, Reason: "restore the zone configuration",
+				Risk: PlanRiskMedium, Idempotent: true})
+			continue
+		}
+		if !leasesOutsidePrimaryRegion(zc, primaryRegion) {
+			continue
+		}
+		if sql, changed := ZoneConfigDiffSql(primaryRegionZoneConfig(zc, primaryRegion), zc); changed {
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "restore the zone configuration",
+				Risk: PlanRiskMedium, Idempotent: true})
+		}
+	}
+
+	return plan
+}
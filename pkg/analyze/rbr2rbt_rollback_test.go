@@ -0,0 +1,104 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRbr2rbtRollbackPlan(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regionFK := FKConstraint{Name: "fk_user_ref_users", Table: "orders", Columns: []string{"crdb_region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"crdb_region", "id"}, UpdateRule: RuleCascade,
+		DeleteRule: RuleNoAction, RegionRestricted: true, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	cascadeFK := FKConstraint{Name: "orders_user_id_fkey", Table: "orders", Columns: []string{"user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"id"}, UpdateRule: RuleCascade,
+		DeleteRule: RuleCascade, ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}}
+	itemsFK := FKConstraint{Name: "fk_order_ref_orders", Table: "items", Columns: []string{"crdb_region", "order_id"},
+		ReferencedTable: "orders", ReferencedColumns: []string{"crdb_region", "id"}, UpdateRule: RuleCascade,
+		DeleteRule: RuleNoAction, RegionRestricted: true, ColumnsNoRegion: []string{"order_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+
+	tables := []Table{
+		{Database: "d", Name: "orders", Locality: rbr, FKs: []FKConstraint{regionFK, cascadeFK}},
+		{Database: "d", Name: "items", Locality: rbr, FKs: []FKConstraint{itemsFK}},
+		{Database: "d", Name: "settings", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	columns := map[string][]Column{
+		"orders": {{Name: "crdb_region", Type: "crdb_internal_region",
+			Default: "default_to_database_primary_region(gateway_region())::public.crdb_internal_region"}},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", `num_replicas = 4, num_voters = 3, constraints = '{+region=a: 1, +region=b: 1}', `+
+			`lease_preferences = '[[+region=b]]'`),
+		testZoneConfig(t, "TABLE d.public.orders", `gc.ttlseconds = 600`),
+	}
+
+	assert.Equal(t, []string{
+		"-- FILE START rollback_change_crdb_region_type.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DATA TYPE crdb_internal_region USING "crdb_region"::crdb_internal_region;`,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DEFAULT default_to_database_primary_region(gateway_region())::public.crdb_internal_region;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" ALTER COLUMN "crdb_region" SET DATA TYPE crdb_internal_region USING "crdb_region"::crdb_internal_region;`,
+		`ALTER TABLE "d"."items" ALTER COLUMN "crdb_region" DROP DEFAULT;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START rollback_table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START rollback_fk.sql",
+		"-- not dropping the FK without the region since it existed before",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ADD CONSTRAINT IF NOT EXISTS "fk_user_ref_users" FOREIGN KEY ("crdb_region","user_id") ` +
+			`REFERENCES "users" ("crdb_region","id") ON UPDATE CASCADE ON DELETE NO ACTION;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" ADD CONSTRAINT IF NOT EXISTS "fk_order_ref_orders" FOREIGN KEY ("crdb_region","order_id") ` +
+			`REFERENCES "orders" ("crdb_region","id") ON UPDATE CASCADE ON DELETE NO ACTION;`,
+		`ALTER TABLE "d"."items" DROP CONSTRAINT IF EXISTS "items_order_id_fkey";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START rollback_zoneconfig.sql",
+		ParallelSqlBlockBegin,
+		`ALTER DATABASE d CONFIGURE ZONE USING num_replicas = 4, constraints = '{+region=a: 1, +region=b: 1}', ` +
+			`lease_preferences = '[[+region=b]]', voter_constraints = COPY FROM PARENT;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.orders CONFIGURE ZONE USING gc.ttlseconds = 600;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, rbr2rbtRollbackPlan("d", "a", tables, tables, columns, nil, zones).Lines())
+}
+
+func TestRbr2rbtRollbackPlanConverted(t *testing.T) {
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+	tables := []Table{
+		{Database: "d", Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow,
+			RegionColumn: DefaultRegionColumn}},
+		{Database: "d", Name: "items", Locality: rbt},
+		{Database: "d", Name: "settings", Locality: rbt},
+	}
+	columns := map[string][]Column{
+		"items": {{Name: "crdb_region", Type: "STRING",
+			Default: "default_to_database_primary_region(gateway_region())::STRING"}},
+		"settings": {{Name: "crdb_region", Type: "STRING"}},
+	}
+
+	// Tables a previous run converted are reported, tables that were never regional by row are not
+	plan := rbr2rbtRollbackPlan("d", "a", tables, tables, columns, nil, nil)
+	assert.Equal(t, []string{`WARNING: "items" already converted to regional by table by a previous run and ` +
+		`left out of the rollback, use the rollback generated before that run`}, plan.Comments)
+	phase, _ := plan.Phase("rollback_table_locality")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+}
@@ -0,0 +1,150 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rbr2rbtConfig configures the rbr2rbt conversion
+type Rbr2rbtConfig struct {
+	PrimaryRegion string
+	// Filter limits the conversion to matching tables, all tables are converted when nil
+	Filter *TableFilter
+	// IncludeDependents adds tables linked to selected tables by region FKs instead of only warning about them
+	IncludeDependents bool
+	// RehomeRows updates the region column of every row to the primary region in keyset batches
+	RehomeRows bool
+	// RehomeBatchSize is the number of rows each rehome batch walks, DefaultRehomeBatchSize when 0
+	RehomeBatchSize int
+}
+
+// TableDependency is a table that must be converted together with a selected table because an FK between
+// them includes the region
+type TableDependency struct {
+	Table         string
+	SelectedTable string
+	FK            string
+	Included      bool
+}
+
+// Rbr2rbtSelection is the tables a conversion applies to
+type Rbr2rbtSelection struct {
+	Tables       []Table
+	Dependencies []TableDependency
+	// Subset is true when only some of the tables are converted
+	Subset bool
+}
+
+func (d TableDependency) String() string {
+	if d.Included {
+		return fmt.Sprintf("including %s with %s, FK %s includes the region", d.Table, d.SelectedTable, d.FK)
+	}
+	return fmt.Sprintf("WARNING: %s is not selected but must be converted with %s, FK %s includes the region",
+		d.Table, d.SelectedTable, d.FK)
+}
+
+// selectRbr2rbtTables selects the tables matching the filter and follows region FKs in both directions to find
+// the tables that must be converted with them. A region FK from a table left regional by row blocks changing
+// the type of the region column it references, and a region FK to it cannot be replaced on one side only.
+func selectRbr2rbtTables(tables []Table, config Rbr2rbtConfig) Rbr2rbtSelection {
+	if config.Filter == nil {
+		return Rbr2rbtSelection{Tables: tables}
+	}
+	selection := Rbr2rbtSelection{Subset: true}
+
+	byName := make(map[string]Table)
+	selected := make(map[string]bool)
+	var queue []string
+	for _, t := range tables {
+		byName[t.Name] = t
+		if config.Filter.Matches(t) {
+			selected[t.Name] = true
+			queue = append(queue, t.Name)
+		}
+	}
+
+	reported := make(map[string]bool)
+	for len(queue) > 0 {
+		t := byName[queue[0]]
+		queue = queue[1:]
+
+		var linked []FKConstraint
+		linked = append(linked, t.FKs...)
+		linked = append(linked, t.ReferencedFKs...)
+		for _, fk := range linked {
+			if !fk.RegionRestricted {
+				continue
+			}
+			other := fk.ReferencedTable
+			if other == t.Name {
+				other = fk.Table
+			}
+			if _, ok := byName[other]; !ok || selected[other] || reported[other] {
+				continue
+			}
+			reported[other] = true
+			selection.Dependencies = append(selection.Dependencies, TableDependency{
+				Table: other, SelectedTable: t.Name, FK: fk.Name, Included: config.IncludeDependents,
+			})
+			if config.IncludeDependents {
+				selected[other] = true
+				queue = append(queue, other)
+			}
+		}
+	}
+
+	for _, t := range tables {
+		if selected[t.Name] {
+			selection.Tables = append(selection.Tables, t)
+		}
+	}
+	sort.SliceStable(selection.Dependencies, func(i, j int) bool {
+		return selection.Dependencies[i].Table < selection.Dependencies[j].Table
+	})
+	return selection
+}
+
+// Contains determines whether the table is converted
+func (s Rbr2rbtSelection) Contains(name string) bool {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Zones returns the zone configurations of the selected tables, their indexes and partitions. The database
+// zone configuration applies to every table, so it is only included when all tables are converted.
+func (s Rbr2rbtSelection) Zones(zones []ZoneConfig) []ZoneConfig {
+	if !s.Subset {
+		return zones
+	}
+	var selected []ZoneConfig
+	for _, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil || target.Type == ZoneTargetTypeDatabase || !s.Contains(target.Table) {
+			continue
+		}
+		selected = append(selected, zc)
+	}
+	return selected
+}
+
+// Comments describes the subset and its dependencies for the top of the plan
+func (s Rbr2rbtSelection) Comments() []string {
+	if !s.Subset {
+		return nil
+	}
+	var names []string
+	for _, t := range s.Tables {
+		names = append(names, t.Name)
+	}
+	comments := []string{fmt.Sprintf("converting %d tables: %s", len(names), strings.Join(names, ", ")),
+		"the database zone configuration is not changed when converting a subset of tables"}
+	for _, dependency := range s.Dependencies {
+		comments = append(comments, dependency.String())
+	}
+	return comments
+}
@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSelectRbr2rbtTables(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regionFK := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"crdb_region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"crdb_region", "id"}, RegionRestricted: true,
+		ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr, ReferencedFKs: []FKConstraint{regionFK}},
+		{Database: "d", Name: "orders", Locality: rbr, FKs: []FKConstraint{regionFK}},
+		{Database: "d", Name: "events", Locality: rbr},
+	}
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", "num_replicas = 3"),
+		testZoneConfig(t, "TABLE d.public.orders", "gc.ttlseconds = 600"),
+		testZoneConfig(t, "TABLE d.public.users", "gc.ttlseconds = 600"),
+	}
+
+	// Without a filter every table is converted
+	selection := selectRbr2rbtTables(tables, Rbr2rbtConfig{PrimaryRegion: "a"})
+	assert.False(t, selection.Subset)
+	assert.Equal(t, tables, selection.Tables)
+	assert.Equal(t, zones, selection.Zones(zones))
+	assert.Nil(t, selection.Comments())
+
+	filter, err := NewTableFilter([]string{"orders"}, nil, nil, 0, 0, "", 0)
+	require.NoError(t, err)
+
+	selection = selectRbr2rbtTables(tables, Rbr2rbtConfig{PrimaryRegion: "a", Filter: filter})
+	assert.True(t, selection.Subset)
+	assert.Equal(t, tables[1:2], selection.Tables)
+	assert.Equal(t, zones[1:2], selection.Zones(zones))
+	assert.Equal(t, []string{
+		"converting 1 tables: orders",
+		"the database zone configuration is not changed when converting a subset of tables",
+		"WARNING: users is not selected but must be converted with orders, FK fk_user includes the region",
+	}, selection.Comments())
+
+	selection = selectRbr2rbtTables(tables, Rbr2rbtConfig{PrimaryRegion: "a", Filter: filter, IncludeDependents: true})
+	assert.Equal(t, tables[0:2], selection.Tables)
+	assert.Equal(t, zones[1:], selection.Zones(zones))
+	assert.Equal(t, []TableDependency{{Table: "users", SelectedTable: "orders", FK: "fk_user", Included: true}},
+		selection.Dependencies)
+
+	// Tables without region FKs are converted on their own
+	filter, err = NewTableFilter(nil, []string{"users", "orders"}, nil, 0, 0, "", 0)
+	require.NoError(t, err)
+	selection = selectRbr2rbtTables(tables, Rbr2rbtConfig{PrimaryRegion: "a", Filter: filter})
+	assert.Equal(t, tables[2:], selection.Tables)
+	assert.Empty(t, selection.Dependencies)
+}
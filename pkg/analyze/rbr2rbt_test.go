@@ -0,0 +1,217 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRbr2rbtPlan(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	regionFK := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"crdb_region", "user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"crdb_region", "id"}, UpdateRule: RuleCascade,
+		DeleteRule: RuleNoAction, RegionRestricted: true, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr},
+		{Database: "d", Name: "orders", Locality: rbr, FKs: []FKConstraint{regionFK}},
+		{Database: "d", Name: "settings", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	columns := map[string][]Column{
+		"users":  {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"orders": {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+	}
+	// Only zone configurations with leaseholders outside the primary region are moved to it
+	zones := []ZoneConfig{
+		testZoneConfig(t, "DATABASE d", "num_replicas = 3, lease_preferences = '[[+region=b]]'"),
+		testZoneConfig(t, "TABLE d.public.orders", "gc.ttlseconds = 600"),
+	}
+
+	plan := rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, tables, columns, nil, nil)
+	phase, _ := plan.Phase("zoneconfig")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		"ALTER DATABASE d CONFIGURE ZONE USING num_voters = 3, constraints = '[+region=a]', " +
+			"voter_constraints = '[+region=a]', lease_preferences = '[[+region=a]]';",
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+	assert.Equal(t, []string{
+		"Progress: zoneconfig 0 of 1 steps done, 1 remaining",
+		"Progress: fk 0 of 1 steps done, 1 remaining",
+		"Progress: table_locality 0 of 2 steps done, 2 remaining",
+		"Progress: change_crdb_region_type 0 of 2 steps done, 2 remaining",
+		"Progress: zone_config_discard 1 of 2 steps done, 1 remaining",
+	}, plan.Comments)
+
+	// Leaseholders already in the primary region leave the zone configuration as it is
+	primaryLeases := []ZoneConfig{testZoneConfig(t, "DATABASE d", "num_replicas = 3, constraints = '[+region=b]', "+
+		"lease_preferences = '[[+region=a]]'")}
+	plan = rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, primaryLeases, tables, tables, columns, nil, nil)
+	phase, _ = plan.Phase("zoneconfig")
+	assert.Empty(t, phase.Blocks)
+	assert.Equal(t, 1, phase.Completed)
+
+	// Rerun after the zone configurations, the replacement FK, the users locality and type and the
+	// orders zone discard are done
+	zones = []ZoneConfig{testZoneConfig(t, "DATABASE d", "num_replicas = 3, num_voters = 3, "+
+		"constraints = '[+region=a]', voter_constraints = '[+region=a]', lease_preferences = '[[+region=a]]'")}
+	tables[0].Locality = Locality{Type: LocalityTypeRegionalByTable}
+	tables[1].FKs = append(tables[1].FKs, FKConstraint{Name: "orders_user_id_fkey", Table: "orders",
+		Columns: []string{"user_id"}, ReferencedTable: "users", ReferencedColumns: []string{"id"},
+		UpdateRule: RuleCascade, DeleteRule: RuleNoAction, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}})
+	columns["users"][1] = Column{Name: "crdb_region", Type: "STRING",
+		Default: "default_to_database_primary_region(gateway_region())::STRING"}
+
+	plan = rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, tables, columns, nil, nil)
+	assert.Equal(t, []string{
+		"-- Progress: zoneconfig 1 of 1 steps done, 0 remaining",
+		"-- Progress: fk 0 of 1 steps done, 1 remaining",
+		"-- Progress: table_locality 1 of 2 steps done, 1 remaining",
+		"-- Progress: change_crdb_region_type 1 of 2 steps done, 1 remaining",
+		"-- Progress: zone_config_discard 2 of 2 steps done, 0 remaining",
+		"-- FILE START zoneconfig.sql",
+		"-- FILE END",
+		"-- FILE START fk.sql",
+		`-- replacement "orders_user_id_fkey" already exists`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" DROP CONSTRAINT IF EXISTS "fk_user";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" SET LOCALITY REGIONAL BY TABLE IN PRIMARY REGION;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START change_crdb_region_type.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DATA TYPE STRING;`,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DEFAULT default_to_database_primary_region(gateway_region())::STRING;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zone_config_discard.sql",
+		"-- FILE END",
+	}, plan.Lines())
+
+	// Once the FK is dropped the plan is empty
+	tables[1].FKs = tables[1].FKs[1:]
+	tables[1].Locality = Locality{Type: LocalityTypeRegionalByTable}
+	columns["orders"][1].Type = "STRING"
+	plan = rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, tables, columns, nil, nil)
+	assert.Equal(t, 0, plan.StatementCount())
+	assert.Equal(t, "Progress: fk 1 of 1 steps done, 0 remaining", plan.Comments[1])
+}
+
+func TestRbr2rbtPlanRegionColumn(t *testing.T) {
+	accountsFK := FKConstraint{Name: "fk_account", Table: "payments", Columns: []string{"home", "account_id"},
+		ReferencedTable: "accounts", ReferencedColumns: []string{"region", "id"}}.withRegionColumns("home", "region")
+	assert.True(t, accountsFK.RegionRestricted)
+	assert.Equal(t, []string{"account_id"}, accountsFK.ColumnsNoRegion)
+	assert.Equal(t, []string{"id"}, accountsFK.ReferencedColumnsNoRegion)
+	assert.Equal(t, []string{"home", "account_id"}, accountsFK.Columns)
+
+	tables := []Table{
+		{Database: "d", Name: "accounts", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}},
+		{Database: "d", Name: "payments", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "home"},
+			FKs: []FKConstraint{accountsFK}},
+		{Database: "d", Name: "ledger", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+	}
+	columns := map[string][]Column{
+		"accounts": {{Name: "id", Type: "INT8"}, {Name: "country", Type: "STRING"}, {Name: "region", Type: RegionColumnType,
+			Computed: "CASE WHEN country = 'US' THEN 'a' ELSE 'b' END"}},
+		"payments": {{Name: "account_id", Type: "INT8"}, {Name: "home", Type: RegionColumnType, Default: "'a'"}},
+		"ledger":   {{Name: "crdb_region", Type: RegionColumnType}},
+	}
+	indexes := map[string][]Index{
+		"accounts": {
+			{Name: "accounts_pkey", Primary: true, Definition: "CREATE UNIQUE INDEX accounts_pkey ON public.accounts USING btree (id ASC)"},
+			{Name: "accounts_region_id_key", Definition: "CREATE UNIQUE INDEX accounts_region_id_key ON public.accounts USING btree (region ASC, id ASC)"},
+			{Name: "accounts_us_idx", Definition: "CREATE INDEX accounts_us_idx ON public.accounts USING btree (country ASC) " +
+				"WHERE region = 'a'::public.crdb_internal_region"},
+		},
+		"ledger": {{Name: "ledger_pkey", Primary: true,
+			Definition: "CREATE UNIQUE INDEX ledger_pkey ON public.ledger USING btree (crdb_region ASC, id ASC)"}},
+	}
+	zones := []ZoneConfig{testZoneConfig(t, "INDEX d.public.accounts@accounts_us_idx", "gc.ttlseconds = 600")}
+
+	// The region FK of payments is replaced before the type changes, but an FK that is kept is dropped with the
+	// unique index it references, even from a table that is not converted
+	audit := Table{Database: "d", Name: "audit", Locality: Locality{Type: LocalityTypeGlobal},
+		FKs: []FKConstraint{{Name: "fk_audit_account", Table: "audit", Columns: []string{"account_id", "region"},
+			ReferencedTable: "accounts", ReferencedColumns: []string{"region", "id"}}}}
+	plan := rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, append(tables, audit), columns,
+		indexes, nil)
+	phase, _ := plan.Phase("change_crdb_region_type")
+	assert.Equal(t, `-- WARNING: "fk_audit_account" of "audit" references a unique index on "region", which is dropped `+
+		`with CASCADE and drops the FK`, phase.Lines()[0])
+
+	plan = rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, zones, tables, tables, columns, indexes, nil)
+	phase, _ = plan.Phase("change_crdb_region_type")
+	assert.Equal(t, []string{
+		`-- WARNING: "region" is no longer computed as CASE WHEN country = 'US' THEN 'a' ELSE 'b' END, new rows must set it`,
+		ParallelSqlBlockBegin,
+		`DROP INDEX IF EXISTS "d"."accounts"@"accounts_region_id_key" CASCADE;`,
+		`DROP INDEX IF EXISTS "d"."accounts"@"accounts_us_idx";`,
+		`ALTER TABLE "d"."accounts" ALTER COLUMN "region" DROP STORED;`,
+		`ALTER TABLE "d"."accounts" ALTER COLUMN "region" SET DATA TYPE STRING;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS accounts_region_id_key ON "d".public.accounts USING btree (region ASC, id ASC);`,
+		`CREATE INDEX IF NOT EXISTS accounts_us_idx ON "d".public.accounts USING btree (country ASC) WHERE region = 'a'::STRING;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ALTER COLUMN "home" SET DATA TYPE STRING;`,
+		`ALTER TABLE "d"."payments" ALTER COLUMN "home" SET DEFAULT ('a')::STRING;`,
+		ParallelSqlBlockEnd,
+		`-- WARNING: primary key "ledger_pkey" includes "crdb_region", change the primary key before changing the type of the region column`,
+	}, phase.Lines())
+
+	phase, _ = plan.Phase("zone_config_discard")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER INDEX "d"."accounts"@"accounts_us_idx" CONFIGURE ZONE DISCARD;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+
+	// The rollback restores the original index definitions around the type change
+	rollback := rbr2rbtRollbackPlan("d", "a", tables[:1], tables[:1], columns, indexes, nil)
+	assert.Equal(t, []string{
+		`-- WARNING: "region" was computed as CASE WHEN country = 'US' THEN 'a' ELSE 'b' END, which cannot be restored in place`,
+		ParallelSqlBlockBegin,
+		`DROP INDEX IF EXISTS "d"."accounts"@"accounts_region_id_key" CASCADE;`,
+		`DROP INDEX IF EXISTS "d"."accounts"@"accounts_us_idx";`,
+		`ALTER TABLE "d"."accounts" ALTER COLUMN "region" SET DATA TYPE crdb_internal_region USING "region"::crdb_internal_region;`,
+		`ALTER TABLE "d"."accounts" ALTER COLUMN "region" DROP DEFAULT;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS accounts_region_id_key ON "d".public.accounts USING btree (region ASC, id ASC);`,
+		`CREATE INDEX IF NOT EXISTS accounts_us_idx ON "d".public.accounts USING btree (country ASC) WHERE region = 'a'::public.crdb_internal_region;`,
+		ParallelSqlBlockEnd,
+	}, rollback.Phases[0].Lines())
+}
+
+func TestRbr2rbtPlanSkipsNonRegionalByRowTables(t *testing.T) {
+	tables := []Table{
+		{Database: "d", Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+		{Database: "d", Name: "settings", Locality: Locality{Type: LocalityTypeGlobal}},
+		{Database: "d", Name: "audit", Locality: Locality{Type: LocalityTypeRegionalByTable, Region: "b"}},
+	}
+	columns := map[string][]Column{
+		"orders":   {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"settings": {{Name: "id", Type: "INT8"}},
+		"audit":    {{Name: "id", Type: "INT8"}},
+	}
+
+	// Global and regional by table tables keep their locality and have no region column to change
+	plan := rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, nil, tables, tables, columns, nil, nil)
+	phase, _ := plan.Phase("table_locality")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" SET LOCALITY REGIONAL BY TABLE IN PRIMARY REGION;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+	assert.Equal(t, 0, phase.Completed)
+	phase, _ = plan.Phase("change_crdb_region_type")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DATA TYPE STRING;`,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DEFAULT default_to_database_primary_region(gateway_region())::STRING;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+	assert.Equal(t, 0, phase.Completed)
+}
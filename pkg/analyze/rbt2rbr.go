@@ -0,0 +1,242 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegionColumnType is the type of the region column of REGIONAL BY ROW tables
+const RegionColumnType = "crdb_internal_region"
+
+// Rbt2rbrConfig configures the conversion from regional by table to regional by row
+type Rbt2rbrConfig struct {
+	// Filter limits the conversion to matching tables, all regional by table tables are converted when nil
+	Filter *TableFilter
+}
+
+// Rbt2rbrPlan generates the plan that converts regional by table tables to regional by row, the reverse of
+// Rbr2rbtPlan. Region columns left behind as strings are changed back to the region type, FKs between
+// regional by row tables are rewritten to include the region and multi-region zone configurations are reset.
+func (c *Converter) Rbt2rbrPlan(config Rbt2rbrConfig) (*Plan, error) {
+	regions, err := c.Analyzer.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	if !regions.IsMultiRegion() {
+		return nil, fmt.Errorf("database %s is not a multi-region database", c.Config.Database)
+	}
+
+	tables, err := c.Analyzer.Tables(false, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.Analyzer.Columns()
+	if err != nil {
+		return nil, err
+	}
+	views, err := c.Analyzer.Views(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rbt2rbrPlan(c.Config.Database, tables, rbt2rbrTargets(tables, columns, config.Filter), columns, views), nil
+}
+
+// rbt2rbrTargets returns the regional by row locality of each regional by table table matching the filter.
+// Tables converted by rbr2rbt go back to the region column they were converted with.
+func rbt2rbrTargets(tables []Table, columns map[string][]Column, filter *TableFilter) map[string]Locality {
+	targets := make(map[string]Locality)
+	for _, table := range tables {
+		if table.Locality.Type != LocalityTypeRegionalByTable || (filter != nil && !filter.Matches(table)) {
+			continue
+		}
+		target := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+		if column, ok := rbr2rbtRegionColumn(table, columns[table.Name]); ok {
+			target.RegionColumn = column.Name
+		}
+		targets[table.Name] = target
+	}
+	return targets
+}
+
+// rbt2rbrPlan converts the tables in targets to their regional by row locality. Other regional by row tables
+// are linked to the converted tables with region FKs.
+func rbt2rbrPlan(database string, tables []Table, targets map[string]Locality, columns map[string][]Column,
+	views []View) *Plan {
+	plan := NewPlan("rbt2rbr", database)
+
+	// Determine which tables are converted. A computed region column that is not of the region type
+	// cannot be changed, so those tables are left as they are.
+	var converted []Table
+	convertedNames := make(map[string]bool)
+	var skipComments []string
+	for _, table := range tables {
+		target, ok := targets[table.Name]
+		if !ok {
+			continue
+		}
+		regionColumn := target.RegionColumnName()
+		if column, ok := findColumn(columns[table.Name], regionColumn); ok &&
+			column.Computed != "" && !isRegionColumnType(column.Type) {
+			skipComments = append(skipComments, fmt.Sprintf(
+				"WARNING: skipping %s, computed column %s is %s and must be %s to be used as the region column",
+				quoteIdentifier(table.Name), regionColumn, column.Type, RegionColumnType))
+			continue
+		}
+		table.Locality = target
+		converted = append(converted, table)
+		convertedNames[table.Name] = true
+	}
+
+	// Tables that are regional by row once the conversion is done, with their region column
+	rbr := make(map[string]Locality)
+	for _, table := range tables {
+		if table.Locality.IsRegionalByRow() {
+			rbr[table.Name] = table.Locality
+		}
+	}
+	for _, table := range converted {
+		rbr[table.Name] = table.Locality
+	}
+
+	// Region columns kept as strings by rbr2rbt are changed back to the region type. Tables without a
+	// region column get one when the locality is set.
+	phase := plan.AddPhase("change_crdb_region_type")
+	phase.Comment(skipComments...)
+	for _, table := range converted {
+		column, ok := findColumn(columns[table.Name], table.Locality.RegionColumnName())
+		if !ok || isRegionColumnType(column.Type) || column.Computed != "" {
+			continue
+		}
+		columnName := quoteIdentifier(column.Name)
+		comments := append(viewDependencyComments(views, table), fmt.Sprintf(
+			"WARNING: every %s value in %s must be a database region before the type can be changed",
+			column.Name, quoteIdentifier(table.Name)))
+		name := quoteIdentifierWithDatabase(table.Database, table.Name)
+		statements := []Statement{{Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::%s",
+			name, columnName, RegionColumnType, columnName, RegionColumnType),
+			Target: table.Name, Reason: "restore the region type", Risk: PlanRiskMedium, Idempotent: true}}
+		if column.Name == DefaultRegionColumn {
+			statements = append(statements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT default_to_database_primary_region(gateway_region())::%s",
+				name, columnName, RegionColumnType),
+				Target: table.Name, Reason: "restore the gateway region default", Risk: PlanRiskLow, Idempotent: true})
+		}
+		phase.AddBlock(comments, statements...)
+	}
+
+	phase = plan.AddPhase("table_locality")
+	for _, table := range converted {
+		sql := fmt.Sprintf("ALTER TABLE %s SET LOCALITY %s",
+			quoteIdentifierWithDatabase(table.Database, table.Name), table.Locality)
+		phase.AddBlock(viewDependencyComments(views, table), Statement{Sql: sql, Target: table.Name,
+			Reason: "convert to regional by row", Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	// FKs between regional by row tables include the region so the parent is looked up in the local region.
+	// Region FKs do not support CASCADE, SET NULL or SET DEFAULT, so FKs with those rules are kept alongside
+	// the region FK.
+	phase = plan.AddPhase("fk")
+	for _, table := range tables {
+		locality, ok := rbr[table.Name]
+		if !ok {
+			continue
+		}
+		for _, fk := range table.FKs {
+			referenced, ok := rbr[fk.ReferencedTable]
+			if fk.RegionRestricted || !ok {
+				continue
+			}
+			// Only FKs touching a converted table change, others were already left as they are
+			if !convertedNames[fk.Table] && !convertedNames[fk.ReferencedTable] {
+				continue
+			}
+
+			var comments []string
+			var fkStatements []Statement
+			if hasRegionRestrictedFK(table.FKs, fk) {
+				comments = append(comments, "not adding since a region FK already exists")
+			} else {
+				fkStatements = append(fkStatements, Statement{Sql: regionFKSql(database, fk, locality.RegionColumnName(),
+					referenced.RegionColumnName()), Target: fk.Table,
+					Reason: fmt.Sprintf("replace %s with an FK that includes the region", fk.Name),
+					Risk:   PlanRiskMedium, Idempotent: true})
+			}
+
+			if keepsNonRegionFK(fk) {
+				comments = append(comments, fmt.Sprintf("keeping %s for its ON UPDATE %s ON DELETE %s rules",
+					quoteIdentifier(fk.Name), fk.UpdateRule, fk.DeleteRule))
+			} else {
+				fkStatements = append(fkStatements, Statement{Sql: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s",
+					quoteIdentifierWithDatabase(database, fk.Table), quoteIdentifier(fk.Name)), Target: fk.Table,
+					Reason: "drop the FK without the region", Risk: PlanRiskHigh, Idempotent: true})
+			}
+			phase.AddBlock(comments, fkStatements...)
+		}
+	}
+
+	// Overrides such as the primary region placement set by rbr2rbt are replaced with the zone
+	// configurations the multi-region abstractions would set
+	phase = plan.AddPhase("zone_config_reset")
+	for _, table := range converted {
+		name := fmt.Sprintf("%s.public.%s", quoteIdentifier(table.Database), quoteIdentifier(table.Name))
+		sql := fmt.Sprintf("SELECT crdb_internal.reset_multi_region_zone_configs_for_table('%s'::REGCLASS::INT)",
+			strings.ReplaceAll(name, "'", "''"))
+		phase.AddBlock(nil, Statement{Sql: sql, Target: table.Name,
+			Reason: "reset to the multi-region zone configuration", Risk: PlanRiskLow, Idempotent: true})
+	}
+
+	return plan
+}
+
+// keepsNonRegionFK determines whether fk has rules the region FK cannot carry and so must be kept
+func keepsNonRegionFK(fk FKConstraint) bool {
+	switch fk.DeleteRule {
+	case RuleCascade, RuleSetNull, RuleSetDefault:
+		return true
+	}
+	return fk.UpdateRule == RuleSetNull || fk.UpdateRule == RuleSetDefault
+}
+
+// regionFKSql builds the statement adding an FK that includes the region column on both sides. The update
+// rule is kept unless it would set the region column, and a RESTRICT delete rule is kept since the FK
+// without the region is dropped. Other delete rules are left to the FK that is kept.
+func regionFKSql(database string, fk FKConstraint, regionColumn string, referencedRegionColumn string) string {
+	sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT IF NOT EXISTS %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		quoteIdentifierWithDatabase(database, fk.Table),
+		quoteIdentifier(fk.GenerateNameWithRegion()),
+		quoteAndJoinIdentifiers(append([]string{regionColumn}, fk.ColumnsNoRegion...)),
+		quoteIdentifier(fk.ReferencedTable),
+		quoteAndJoinIdentifiers(append([]string{referencedRegionColumn}, fk.ReferencedColumnsNoRegion...)))
+	if fk.UpdateRule != "" && fk.UpdateRule != RuleSetNull && fk.UpdateRule != RuleSetDefault {
+		sql = fmt.Sprintf("%s ON UPDATE %s", sql, fk.UpdateRule)
+	}
+	if fk.DeleteRule == RuleRestrict {
+		sql = fmt.Sprintf("%s ON DELETE %s", sql, fk.DeleteRule)
+	}
+	return sql
+}
+
+// hasRegionRestrictedFK determines whether there is a region FK on the same columns and referenced table
+func hasRegionRestrictedFK(fks []FKConstraint, fk FKConstraint) bool {
+	for _, other := range fks {
+		if other.RegionRestricted && other.ReferencedTable == fk.ReferencedTable &&
+			equalSlices(other.ColumnsNoRegion, fk.ColumnsNoRegion) {
+			return true
+		}
+	}
+	return false
+}
+
+func findColumn(columns []Column, name string) (Column, bool) {
+	for _, column := range columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// isRegionColumnType determines whether a column type is the region type, which may be schema qualified
+func isRegionColumnType(typ string) bool {
+	return typ == RegionColumnType || strings.HasSuffix(typ, "."+RegionColumnType)
+}
@@ -0,0 +1,160 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRbt2rbrPlan(t *testing.T) {
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbt},
+		{Database: "d", Name: "orders", Locality: rbt, FKs: []FKConstraint{
+			{Name: "orders_user_id_fkey", Table: "orders", Columns: []string{"user_id"}, ReferencedTable: "users",
+				ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction, DeleteRule: RuleNoAction,
+				ColumnsNoRegion: []string{"user_id"}, ReferencedColumnsNoRegion: []string{"id"}},
+		}},
+		{Database: "d", Name: "items", Locality: rbr, FKs: []FKConstraint{
+			{Name: "items_order_id_fkey", Table: "items", Columns: []string{"order_id"}, ReferencedTable: "orders",
+				ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction, DeleteRule: RuleCascade,
+				ColumnsNoRegion: []string{"order_id"}, ReferencedColumnsNoRegion: []string{"id"}},
+		}},
+		{Database: "d", Name: "computed", Locality: rbt},
+		{Database: "d", Name: "settings", Locality: Locality{Type: LocalityTypeGlobal}},
+	}
+	columns := map[string][]Column{
+		"orders":   {{Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: "STRING"}},
+		"computed": {{Name: "crdb_region", Type: "STRING", Computed: "'us-east1'"}},
+	}
+
+	assert.Equal(t, []string{
+		"-- FILE START change_crdb_region_type.sql",
+		`-- WARNING: skipping "computed", computed column crdb_region is STRING and must be crdb_internal_region to be used as the region column`,
+		`-- WARNING: every crdb_region value in "orders" must be a database region before the type can be changed`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DATA TYPE crdb_internal_region USING "crdb_region"::crdb_internal_region;`,
+		`ALTER TABLE "d"."orders" ALTER COLUMN "crdb_region" SET DEFAULT default_to_database_primary_region(gateway_region())::crdb_internal_region;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."users" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" SET LOCALITY REGIONAL BY ROW;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START fk.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ADD CONSTRAINT IF NOT EXISTS "orders_crdb_region_user_id_fkey" FOREIGN KEY ("crdb_region","user_id") REFERENCES "users" ("crdb_region","id") ON UPDATE NO ACTION;`,
+		`ALTER TABLE "d"."orders" DROP CONSTRAINT IF EXISTS "orders_user_id_fkey";`,
+		ParallelSqlBlockEnd,
+		`-- keeping "items_order_id_fkey" for its ON UPDATE NO ACTION ON DELETE CASCADE rules`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."items" ADD CONSTRAINT IF NOT EXISTS "items_crdb_region_order_id_fkey" FOREIGN KEY ("crdb_region","order_id") REFERENCES "orders" ("crdb_region","id") ON UPDATE NO ACTION;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zone_config_reset.sql",
+		ParallelSqlBlockBegin,
+		`SELECT crdb_internal.reset_multi_region_zone_configs_for_table('"d".public."users"'::REGCLASS::INT);`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		`SELECT crdb_internal.reset_multi_region_zone_configs_for_table('"d".public."orders"'::REGCLASS::INT);`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, rbt2rbrPlan("d", tables, rbt2rbrTargets(tables, columns, nil), columns, nil).Lines())
+}
+
+func TestRbt2rbrPlanRegionColumn(t *testing.T) {
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+	tables := []Table{
+		{Database: "d", Name: "accounts", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}},
+		{Database: "d", Name: "payments", Locality: rbt, FKs: []FKConstraint{
+			{Name: "payments_account_id_fkey", Table: "payments", Columns: []string{"account_id"},
+				ReferencedTable: "accounts", ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction,
+				DeleteRule: RuleNoAction, ColumnsNoRegion: []string{"account_id"}, ReferencedColumnsNoRegion: []string{"id"}},
+		}},
+		{Database: "d", Name: "ledger", Locality: rbt},
+	}
+	columns := map[string][]Column{
+		"payments": {{Name: "account_id", Type: "INT8"}, {Name: "crdb_region", Type: "STRING",
+			Default: "default_to_database_primary_region(gateway_region())::STRING"}, {Name: "home", Type: "STRING"}},
+	}
+
+	// Only the selected table is converted
+	filter, err := NewTableFilter([]string{"payments"}, nil, nil, 0, 0, "", 0)
+	assert.NoError(t, err)
+	targets := rbt2rbrTargets(tables, columns, filter)
+	assert.Equal(t, map[string]Locality{
+		"payments": {Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn},
+	}, targets)
+
+	// The region FK uses the region column of the locality on each side
+	plan := rbt2rbrPlan("d", tables, targets, columns, nil)
+	phase, _ := plan.Phase("fk")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ADD CONSTRAINT IF NOT EXISTS "payments_crdb_region_account_id_fkey" FOREIGN KEY ("crdb_region","account_id") REFERENCES "accounts" ("region","id") ON UPDATE NO ACTION;`,
+		`ALTER TABLE "d"."payments" DROP CONSTRAINT IF EXISTS "payments_account_id_fkey";`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+
+	// A target locality with its own region column changes that column
+	targets["payments"] = Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "home"}
+	plan = rbt2rbrPlan("d", tables, targets, columns, nil)
+	assert.Equal(t, []string{
+		"-- FILE START change_crdb_region_type.sql",
+		`-- WARNING: every home value in "payments" must be a database region before the type can be changed`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ALTER COLUMN "home" SET DATA TYPE crdb_internal_region USING "home"::crdb_internal_region;`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START table_locality.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" SET LOCALITY REGIONAL BY ROW AS "home";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START fk.sql",
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."payments" ADD CONSTRAINT IF NOT EXISTS "payments_crdb_region_account_id_fkey" FOREIGN KEY ("home","account_id") REFERENCES "accounts" ("region","id") ON UPDATE NO ACTION;`,
+		`ALTER TABLE "d"."payments" DROP CONSTRAINT IF EXISTS "payments_account_id_fkey";`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+		"-- FILE START zone_config_reset.sql",
+		ParallelSqlBlockBegin,
+		`SELECT crdb_internal.reset_multi_region_zone_configs_for_table('"d".public."payments"'::REGCLASS::INT);`,
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, plan.Lines())
+}
+
+func TestRbt2rbrPlanFKRules(t *testing.T) {
+	rbr := Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}
+	fk := func(column string, deleteRule Rule) FKConstraint {
+		return FKConstraint{Name: "orders_" + column + "_fkey", Table: "orders", Columns: []string{column},
+			ReferencedTable: "users", ReferencedColumns: []string{"id"}, UpdateRule: RuleNoAction,
+			DeleteRule: deleteRule, ColumnsNoRegion: []string{column}, ReferencedColumnsNoRegion: []string{"id"}}
+	}
+	tables := []Table{
+		{Database: "d", Name: "users", Locality: rbr},
+		{Database: "d", Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByTable},
+			FKs: []FKConstraint{fk("buyer_id", RuleRestrict), fk("seller_id", RuleSetDefault)}},
+	}
+	columns := map[string][]Column{"orders": {{Name: "crdb_region", Type: "crdb_internal_region"}}}
+
+	// RESTRICT is carried by the region FK, SET DEFAULT keeps the FK without the region
+	plan := rbt2rbrPlan("d", tables, rbt2rbrTargets(tables, columns, nil), columns, nil)
+	phase, _ := plan.Phase("fk")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ADD CONSTRAINT IF NOT EXISTS "orders_crdb_region_buyer_id_fkey" FOREIGN KEY ("crdb_region","buyer_id") REFERENCES "users" ("crdb_region","id") ON UPDATE NO ACTION ON DELETE RESTRICT;`,
+		`ALTER TABLE "d"."orders" DROP CONSTRAINT IF EXISTS "orders_buyer_id_fkey";`,
+		ParallelSqlBlockEnd,
+		`-- keeping "orders_seller_id_fkey" for its ON UPDATE NO ACTION ON DELETE SET DEFAULT rules`,
+		ParallelSqlBlockBegin,
+		`ALTER TABLE "d"."orders" ADD CONSTRAINT IF NOT EXISTS "orders_crdb_region_seller_id_fkey" FOREIGN KEY ("crdb_region","seller_id") REFERENCES "users" ("crdb_region","id") ON UPDATE NO ACTION;`,
+		ParallelSqlBlockEnd,
+	}, phase.Lines())
+}
@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type SurvivalGoal string
+
+const (
+	SurvivalGoalZone   SurvivalGoal = "zone"
+	SurvivalGoalRegion SurvivalGoal = "region"
+)
+
+type PlacementPolicy string
+
+const (
+	PlacementPolicyDefault    PlacementPolicy = "default"
+	PlacementPolicyRestricted PlacementPolicy = "restricted"
+)
+
+type Region struct {
+	Name    string
+	Primary bool
+	Zones   []string
+}
+
+type SuperRegion struct {
+	Name    string
+	Regions []string
+}
+
+// DatabaseRegions is the multi-region configuration of a database
+type DatabaseRegions struct {
+	Database        string
+	PrimaryRegion   string
+	Regions         []Region
+	SurvivalGoal    SurvivalGoal
+	PlacementPolicy PlacementPolicy
+	SuperRegions    []SuperRegion
+}
+
+// LocalityGroup is the set of tables sharing a locality, with the home region resolved for REGIONAL BY TABLE
+type LocalityGroup struct {
+	Type             LocalityType
+	Region           string
+	Tables           []Table
+	LogicalSizeBytes uint64
+}
+
+func (d DatabaseRegions) String() string {
+	var regions []string
+	for _, region := range d.Regions {
+		regions = append(regions, region.Name)
+	}
+	return fmt.Sprintf("Database: %s, Primary Region: %s, Regions: [%s], Survival Goal: %s, Placement: %s, Super Regions: %d",
+		d.Database, d.PrimaryRegion, strings.Join(regions, ", "), d.SurvivalGoal, d.PlacementPolicy, len(d.SuperRegions))
+}
+
+// IsMultiRegion is true when the database has a primary region
+func (d DatabaseRegions) IsMultiRegion() bool {
+	return d.PrimaryRegion != ""
+}
+
+// RegionNames returns the names of all database regions
+func (d DatabaseRegions) RegionNames() []string {
+	var names []string
+	for _, region := range d.Regions {
+		names = append(names, region.Name)
+	}
+	return names
+}
+
+// HasRegion determines whether the region has been added to the database
+func (d DatabaseRegions) HasRegion(name string) bool {
+	return slices.Contains(d.RegionNames(), name)
+}
+
+// SuperRegionFor returns the super region containing the region, if any
+func (d DatabaseRegions) SuperRegionFor(region string) (SuperRegion, bool) {
+	for _, superRegion := range d.SuperRegions {
+		if slices.Contains(superRegion.Regions, region) {
+			return superRegion, true
+		}
+	}
+	return SuperRegion{}, false
+}
+
+func (s SuperRegion) String() string {
+	return fmt.Sprintf("Super Region: %s, Regions: [%s]", s.Name, strings.Join(s.Regions, ", "))
+}
+
+func (g LocalityGroup) String() string {
+	return fmt.Sprintf("Locality: %s, Tables: %d, Logical Size: %s",
+		g.Name(), len(g.Tables), formatBytes(g.LogicalSizeBytes))
+}
+
+// Name describes the locality group, e.g., REGIONAL BY TABLE IN us-east1
+func (g LocalityGroup) Name() string {
+	switch g.Type {
+	case LocalityTypeRegionalByRow:
+		return "REGIONAL BY ROW"
+	case LocalityTypeRegionalByTable:
+		return fmt.Sprintf("REGIONAL BY TABLE IN %s", g.Region)
+	case LocalityTypeGlobal:
+		return "GLOBAL"
+	default:
+		return "NONE"
+	}
+}
+
+// DatabaseRegions returns the regions, primary region, survival goal, placement policy and super regions
+// of the database
+func (a *Analyzer) DatabaseRegions() (DatabaseRegions, error) {
+	regions := DatabaseRegions{Database: a.Config.Database}
+
+	row, err := a.Db.DatabaseSettings(a.Config.Database)
+	if err != nil {
+		return regions, err
+	}
+	regions.PrimaryRegion = row.PrimaryRegion
+	regions.SurvivalGoal = SurvivalGoal(strings.ToLower(row.SurvivalGoal))
+	regions.PlacementPolicy = PlacementPolicy(strings.ToLower(row.PlacementPolicy))
+
+	// A database without a primary region has no regions or super regions to show
+	if !regions.IsMultiRegion() {
+		return regions, nil
+	}
+
+	rrows, err := a.Db.ShowRegions(a.Config.Database)
+	if err != nil {
+		return regions, err
+	}
+	for _, rrow := range rrows {
+		regions.Regions = append(regions.Regions, Region{
+			Name:    rrow.Region,
+			Primary: rrow.Primary,
+			Zones:   rrow.Zones,
+		})
+	}
+
+	srows, err := a.Db.ShowSuperRegions(a.Config.Database)
+	if err != nil {
+		return regions, err
+	}
+	for _, srow := range srows {
+		regions.SuperRegions = append(regions.SuperRegions, SuperRegion{
+			Name:    srow.Name,
+			Regions: srow.Regions,
+		})
+	}
+
+	return regions, nil
+}
+
+// LocalityGroups groups tables by locality, resolving the home region of REGIONAL BY TABLE tables.
+// Groups are ordered by logical size, largest first.
+func (a *Analyzer) LocalityGroups(regions DatabaseRegions, includeSize bool) ([]LocalityGroup, error) {
+	var groups []LocalityGroup
+
+	tables, err := a.Tables(includeSize, false, nil)
+	if err != nil {
+		return groups, err
+	}
+
+	gmap := make(map[string]LocalityGroup)
+	for _, t := range tables {
+		g := LocalityGroup{
+			Type:   t.Locality.Type,
+			Region: t.Locality.HomeRegion(regions.PrimaryRegion),
+		}
+		if existing, ok := gmap[g.Name()]; ok {
+			g = existing
+		}
+		g.Tables = append(g.Tables, t)
+		g.LogicalSizeBytes += t.LogicalSizeBytes
+		gmap[g.Name()] = g
+	}
+
+	for _, g := range gmap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].LogicalSizeBytes != groups[j].LogicalSizeBytes {
+			return groups[i].LogicalSizeBytes > groups[j].LogicalSizeBytes
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+	return groups, nil
+}
@@ -0,0 +1,155 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// regionTypeCastRe matches casts to the region type, which may be schema qualified and quoted
+var regionTypeCastRe = regexp.MustCompile(`::(?:"?public"?\.)?"?` + RegionColumnType + `"?`)
+
+// columnReferenceRe matches a column name as a whole word, quoted or not
+func columnReferenceRe(column string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^\w])"?` + regexp.QuoteMeta(column) + `"?([^\w]|$)`)
+}
+
+// regionDefaultRe matches the gateway region default of crdb_region, which rbr2rbt keeps as a string
+var regionDefaultRe = regexp.MustCompile(`^default_to_database_primary_region\(gateway_region\(\)\)::`)
+
+// stringDefaultRe matches the default rbr2rbt gives a region column using REGIONAL BY ROW AS once it is a string
+var stringDefaultRe = regexp.MustCompile(`^\(.*\)::STRING$`)
+
+// rbr2rbtRegionColumn finds the region column of a table converted by rbr2rbt. Once the locality is changed
+// the AS clause is gone, so a regional by table table is only treated as converted with evidence left by
+// rbr2rbt: crdb_region with the region type or the gateway region default, or replacement FKs without the
+// region together with crdb_region or a column that has the region type or a string default.
+func rbr2rbtRegionColumn(table Table, columns []Column) (Column, bool) {
+	switch table.Locality.Type {
+	case LocalityTypeRegionalByRow:
+		return findColumn(columns, table.Locality.RegionColumnName())
+	case LocalityTypeRegionalByTable:
+		replaced := hasReplacementFK(table.FKs)
+		if column, ok := findColumn(columns, DefaultRegionColumn); ok &&
+			(replaced || isRegionColumnType(column.Type) || regionDefaultRe.MatchString(column.Default)) {
+			return column, true
+		}
+		if !replaced {
+			return Column{}, false
+		}
+		for _, column := range columns {
+			if isRegionColumnType(column.Type) || stringDefaultRe.MatchString(column.Default) {
+				return column, true
+			}
+		}
+	}
+	return Column{}, false
+}
+
+// hasReplacementFK determines whether one of the FKs is named like the FK without the region that rbr2rbt
+// adds in place of a region FK
+func hasReplacementFK(fks []FKConstraint) bool {
+	for _, fk := range fks {
+		if !fk.RegionRestricted && len(fk.ColumnsNoRegion) > 0 && fk.Name == fk.GenerateNameNoRegion() {
+			return true
+		}
+	}
+	return false
+}
+
+// indexReferencesColumn determines whether the column is one of the index columns or is used by the
+// predicate of a partial index. Columns added by implicit partitioning are not part of the definition.
+func indexReferencesColumn(index Index, column string) bool {
+	i := strings.Index(index.Definition, "(")
+	if i < 0 {
+		return false
+	}
+	return columnReferenceRe(column).MatchString(index.Definition[i:])
+}
+
+// regionIndexStatements drops and recreates the secondary indexes that reference the region column, since
+// its type cannot change while it is indexed. When toString is set, casts to the region type in partial
+// index predicates become casts to STRING. The primary key is returned when it includes the column, as it
+// cannot be dropped.
+func regionIndexStatements(database string, table string, indexes []Index, column string, toString bool) (
+	drops []Statement, creates []Statement, primary *Index) {
+	name := quoteIdentifierWithDatabase(database, table)
+	for i, index := range indexes {
+		if !indexReferencesColumn(index, column) {
+			continue
+		}
+		if index.Primary {
+			primary = &indexes[i]
+			continue
+		}
+
+		// Unique indexes backing unique constraints can only be dropped with CASCADE, which also drops the FKs
+		// referencing them, see cascadeDroppedFKs
+		dropSql := fmt.Sprintf("DROP INDEX IF EXISTS %s@%s", name, quoteIdentifier(index.Name))
+		if index.IsUnique() {
+			dropSql += " CASCADE"
+		}
+		drops = append(drops, Statement{Sql: dropSql, Target: table,
+			Reason: fmt.Sprintf("%s references %s", index.Name, column), Risk: PlanRiskHigh, Idempotent: true})
+
+		definition := index.Definition
+		if toString {
+			definition = regionTypeCastRe.ReplaceAllString(definition, "::STRING")
+		}
+		definition = strings.Replace(definition, " ON ", fmt.Sprintf(" ON %s.", quoteIdentifier(database)), 1)
+		definition = strings.Replace(definition, "INDEX ", "INDEX IF NOT EXISTS ", 1)
+		creates = append(creates, Statement{Sql: definition, Target: table,
+			Reason: fmt.Sprintf("recreate %s", index.Name), Risk: PlanRiskMedium, Idempotent: true})
+	}
+	return drops, creates, primary
+}
+
+// cascadeDroppedFKs finds the FKs that reference a unique index on the region column of table. Dropping the
+// index with CASCADE, as regionIndexStatements does, drops these FKs with it and they are not recreated.
+func cascadeDroppedFKs(table string, indexes []Index, column string, fks []FKConstraint) []FKConstraint {
+	var dropped []FKConstraint
+	for _, index := range indexes {
+		if index.Primary || !index.IsUnique() || !indexReferencesColumn(index, column) {
+			continue
+		}
+		var keys []string
+		for _, key := range index.KeyColumns() {
+			keys = append(keys, key.Name)
+		}
+		for _, fk := range fks {
+			if fk.ReferencedTable == table && sameColumns(fk.ReferencedColumns, keys) {
+				dropped = append(dropped, fk)
+			}
+		}
+	}
+	return dropped
+}
+
+// keptFKs lists the FKs of all the tables that still exist when the region column changes type. Region FKs of
+// the converted tables are replaced by FKs without the region before then.
+func keptFKs(tables []Table, converted []Table) []FKConstraint {
+	var fks []FKConstraint
+	for _, table := range tables {
+		replaced := slices.ContainsFunc(converted, func(t Table) bool { return t.Name == table.Name })
+		for _, fk := range table.FKs {
+			if !replaced || !fk.RegionRestricted {
+				fks = append(fks, fk)
+			}
+		}
+	}
+	return fks
+}
+
+// sameColumns determines whether both lists have the same columns in any order
+func sameColumns(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, column := range a {
+		if !slices.Contains(b, column) {
+			return false
+		}
+	}
+	return true
+}
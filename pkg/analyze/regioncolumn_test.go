@@ -0,0 +1,50 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRbr2rbtRegionColumn(t *testing.T) {
+	rbt := Locality{Type: LocalityTypeRegionalByTable}
+	replacementFK := FKConstraint{Name: "orders_user_id_fkey", Table: "orders", Columns: []string{"user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"id"}, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	otherFK := FKConstraint{Name: "fk_user", Table: "orders", Columns: []string{"user_id"},
+		ReferencedTable: "users", ReferencedColumns: []string{"id"}, ColumnsNoRegion: []string{"user_id"},
+		ReferencedColumnsNoRegion: []string{"id"}}
+	home := Column{Name: "home", Type: "STRING", Default: "(gateway_region()::public.crdb_internal_region)::STRING"}
+	crdbRegion := Column{Name: "crdb_region", Type: "STRING"}
+
+	// The region column of a regional by row table comes from its locality
+	column, ok := rbr2rbtRegionColumn(Table{Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow,
+		RegionColumn: "home"}}, []Column{crdbRegion, home})
+	assert.True(t, ok)
+	assert.Equal(t, home, column)
+
+	// A string crdb_region without the gateway region default or replacement FKs was never converted
+	_, ok = rbr2rbtRegionColumn(Table{Name: "orders", Locality: rbt}, []Column{crdbRegion})
+	assert.False(t, ok)
+
+	// The default set by rbr2rbt is evidence for crdb_region
+	converted := Column{Name: "crdb_region", Type: "STRING",
+		Default: "default_to_database_primary_region(gateway_region())::STRING"}
+	column, ok = rbr2rbtRegionColumn(Table{Name: "orders", Locality: rbt}, []Column{converted})
+	assert.True(t, ok)
+	assert.Equal(t, converted, column)
+
+	// Other columns need a replacement FK named like the FK without the region
+	_, ok = rbr2rbtRegionColumn(Table{Name: "orders", Locality: rbt, FKs: []FKConstraint{otherFK}},
+		[]Column{home})
+	assert.False(t, ok)
+	column, ok = rbr2rbtRegionColumn(Table{Name: "orders", Locality: rbt, FKs: []FKConstraint{replacementFK}},
+		[]Column{{Name: "note", Type: "STRING"}, home})
+	assert.True(t, ok)
+	assert.Equal(t, home, column)
+
+	// With a replacement FK, crdb_region is preferred over any other column
+	column, ok = rbr2rbtRegionColumn(Table{Name: "orders", Locality: rbt, FKs: []FKConstraint{replacementFK}},
+		[]Column{home, crdbRegion})
+	assert.True(t, ok)
+	assert.Equal(t, crdbRegion, column)
+}
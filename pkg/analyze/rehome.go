@@ -0,0 +1,105 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultRehomeBatchSize is the number of rows of the primary key each rehome batch walks
+const DefaultRehomeBatchSize = 1000
+
+// IndexColumn is a key column of an index
+type IndexColumn struct {
+	Name       string
+	Descending bool
+}
+
+// KeyColumns parses the key columns of the index from its definition. Columns added by implicit partitioning
+// are not part of the definition.
+func (i Index) KeyColumns() []IndexColumn {
+	start := strings.Index(i.Definition, "(")
+	if start < 0 {
+		return nil
+	}
+	end := strings.Index(i.Definition[start:], ")")
+	if end < 0 {
+		return nil
+	}
+	var columns []IndexColumn
+	for _, part := range strings.Split(i.Definition[start+1:start+end], ",") {
+		part = strings.TrimSpace(part)
+		column := IndexColumn{}
+		switch {
+		case strings.HasSuffix(part, " DESC"):
+			column.Descending = true
+			part = strings.TrimSuffix(part, " DESC")
+		case strings.HasSuffix(part, " ASC"):
+			part = strings.TrimSuffix(part, " ASC")
+		}
+		column.Name = strings.Trim(part, `"`)
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// primaryIndex returns the primary index of the table
+func primaryIndex(indexes []Index) (Index, bool) {
+	for _, index := range indexes {
+		if index.Primary {
+			return index, true
+		}
+	}
+	return Index{}, false
+}
+
+// rehomeRowsBlock updates the region column of the rows homed in fromRegion to toRegion, or of every row not
+// already in toRegion when fromRegion is empty, walking the primary key in keyset batches of batchSize rows.
+// Each batch starts after the last key of the previous one, which the executor passes as $1 to $n, so no batch
+// rescans rows from the start of the table. Keys are passed as strings and cast back to the column types.
+func rehomeRowsBlock(database string, table string, column Column, fromRegion string, toRegion string,
+	indexes []Index, columns []Column, batchSize int) (comments []string, statement *Statement) {
+	columnName := quoteIdentifier(column.Name)
+	if column.Computed != "" {
+		return []string{fmt.Sprintf("WARNING: %s is computed as %s and cannot be rehomed", columnName, column.Computed)}, nil
+	}
+	primary, ok := primaryIndex(indexes)
+	if !ok || len(primary.KeyColumns()) == 0 {
+		return []string{fmt.Sprintf("WARNING: primary key of %s not found, rows cannot be rehomed in batches",
+			quoteIdentifier(table))}, nil
+	}
+
+	var keys, casts, descending, stringKeys []string
+	for i, key := range primary.KeyColumns() {
+		typ := "STRING"
+		if c, ok := findColumn(columns, key.Name); ok {
+			typ = c.Type
+		}
+		keys = append(keys, quoteIdentifier(key.Name))
+		casts = append(casts, fmt.Sprintf("$%d::STRING::%s", i+1, typ))
+		descending = append(descending, quoteIdentifier(key.Name)+" DESC")
+		stringKeys = append(stringKeys, quoteIdentifier(key.Name)+"::STRING")
+		if key.Descending {
+			comments = append(comments, fmt.Sprintf("WARNING: primary key %s sorts %s descending, batches are "+
+				"walked in ascending order and sort each batch", quoteIdentifier(primary.Name), quoteIdentifier(key.Name)))
+		}
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultRehomeBatchSize
+	}
+
+	name := quoteIdentifierWithDatabase(database, table)
+	keyList := strings.Join(keys, ", ")
+	region := quoteZoneString(toRegion)
+	rows := fmt.Sprintf("%s != %s", columnName, region)
+	if fromRegion != "" {
+		rows = fmt.Sprintf("%s = %s", columnName, quoteZoneString(fromRegion))
+	}
+	sql := fmt.Sprintf("WITH batch AS (SELECT %s FROM %s WHERE $1::STRING IS NULL OR (%s) > (%s) ORDER BY %s LIMIT %d), "+
+		"rehomed AS (UPDATE %s SET %s = %s WHERE (%s) IN (SELECT %s FROM batch) AND %s RETURNING 1) "+
+		"SELECT %s, (SELECT count(*) FROM rehomed) FROM batch ORDER BY %s LIMIT 1",
+		keyList, name, keyList, strings.Join(casts, ", "), keyList, batchSize,
+		name, columnName, region, keyList, keyList, rows,
+		strings.Join(stringKeys, ", "), strings.Join(descending, ", "))
+	return comments, &Statement{Sql: sql, Target: table, Reason: fmt.Sprintf("move rows to %s", toRegion),
+		Risk: PlanRiskMedium, Idempotent: true, KeysetBatch: true}
+}
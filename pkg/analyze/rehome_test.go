@@ -0,0 +1,82 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexKeyColumns(t *testing.T) {
+	index := Index{Name: "t_pkey", Definition: `CREATE UNIQUE INDEX t_pkey ON public.t USING btree (id ASC, "Created At" DESC) STORING (v)`}
+	assert.Equal(t, []IndexColumn{{Name: "id"}, {Name: "Created At", Descending: true}}, index.KeyColumns())
+	assert.Nil(t, Index{Definition: "invalid"}.KeyColumns())
+}
+
+func TestRbr2rbtPlanRehomeRows(t *testing.T) {
+	tables := []Table{
+		{Database: "d", Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+		{Database: "d", Name: "accounts", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: "region"}},
+	}
+	columns := map[string][]Column{
+		"orders": {{Name: "tenant", Type: "UUID"}, {Name: "id", Type: "INT8"}, {Name: "crdb_region", Type: RegionColumnType}},
+		"accounts": {{Name: "id", Type: "INT8"}, {Name: "region", Type: RegionColumnType,
+			Computed: "CASE WHEN id > 0 THEN 'a' ELSE 'b' END"}},
+	}
+	indexes := map[string][]Index{
+		"orders": {{Name: "orders_pkey", Primary: true,
+			Definition: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (tenant ASC, id ASC)"}},
+	}
+
+	plan := rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a"}, nil, tables, tables, columns, indexes, nil)
+	_, ok := plan.Phase("rehome_rows")
+	assert.False(t, ok)
+
+	plan = rbr2rbtPlan("d", Rbr2rbtConfig{PrimaryRegion: "a", RehomeRows: true, RehomeBatchSize: 500}, nil, tables, tables,
+		columns, indexes, nil)
+	names := make([]string, 0, len(plan.Phases))
+	for _, phase := range plan.Phases {
+		names = append(names, phase.Name)
+	}
+	assert.Equal(t, []string{"zoneconfig", "fk", "table_locality", "rehome_rows", "change_crdb_region_type",
+		"zone_config_discard"}, names)
+
+	phase, _ := plan.Phase("rehome_rows")
+	assert.Equal(t, []string{
+		ParallelSqlBlockBegin,
+		KeysetBatchDirective,
+		`WITH batch AS (SELECT "tenant", "id" FROM "d"."orders" WHERE $1::STRING IS NULL OR ("tenant", "id") > ` +
+			`($1::STRING::UUID, $2::STRING::INT8) ORDER BY "tenant", "id" LIMIT 500), ` +
+			`rehomed AS (UPDATE "d"."orders" SET "crdb_region" = 'a' WHERE ("tenant", "id") IN ` +
+			`(SELECT "tenant", "id" FROM batch) AND "crdb_region" != 'a' RETURNING 1) ` +
+			`SELECT "tenant"::STRING, "id"::STRING, (SELECT count(*) FROM rehomed) FROM batch ` +
+			`ORDER BY "tenant" DESC, "id" DESC LIMIT 1;`,
+		ParallelSqlBlockEnd,
+		`-- WARNING: "region" is computed as CASE WHEN id > 0 THEN 'a' ELSE 'b' END and cannot be rehomed`,
+	}, phase.Lines())
+
+	// The directive stays with the statement so the executor runs it in batches
+	batches, err := NewSqlFileParser("").ParseReader(strings.NewReader(strings.Join(phase.Lines(), "\n")))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	sql, keys := keysetStatement(batches[0][0])
+	assert.Equal(t, 2, keys)
+	assert.Equal(t, phase.Blocks[0].Statements[0].Sql, sql)
+}
+
+func TestExecutionState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ExecutionStateFile)
+	state, err := ReadExecutionState(path)
+	require.NoError(t, err)
+	assert.Equal(t, KeysetProgress{}, state.Progress("UPDATE t"))
+
+	progress := KeysetProgress{Cursor: []string{"b7c1", "42"}, Batches: 3, Rows: 2500}
+	require.NoError(t, state.Update("UPDATE t", progress))
+
+	// An interrupted execution resumes from the saved cursor
+	state, err = ReadExecutionState(path)
+	require.NoError(t, err)
+	assert.Equal(t, progress, state.Progress("UPDATE t"))
+	assert.Equal(t, KeysetProgress{}, state.Progress("UPDATE u"))
+}
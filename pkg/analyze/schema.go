@@ -0,0 +1,154 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema is a snapshot of the objects in a database that are compared between clusters
+type Schema struct {
+	Database string
+	Tables   []SchemaTable
+	Zones    []ZoneConfig
+}
+
+type SchemaTable struct {
+	Name     string
+	Locality Locality
+	Columns  []Column
+	Indexes  []Index
+	FKs      []FKConstraint
+}
+
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  string
+	Hidden   bool
+	// Computed is the expression of a computed column, empty for other columns
+	Computed string
+}
+
+type Index struct {
+	Name string
+	// Definition is the CREATE INDEX statement, with the database removed from the table name
+	Definition string
+	Primary    bool
+}
+
+func (c Column) String() string {
+	s := c.Type
+	if !c.Nullable {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+	if c.Computed != "" {
+		s += fmt.Sprintf(" AS (%s) STORED", c.Computed)
+	}
+	if c.Hidden {
+		s += " NOT VISIBLE"
+	}
+	return s
+}
+
+// Schema loads the tables of the database with their columns, indexes, FKs and localities, along with
+// all zone configurations
+func (a *Analyzer) Schema() (Schema, error) {
+	schema := Schema{Database: a.Config.Database}
+
+	tables, err := a.Tables(false, true, nil)
+	if err != nil {
+		return schema, err
+	}
+
+	columns, err := a.Columns()
+	if err != nil {
+		return schema, err
+	}
+
+	indexes, err := a.Indexes()
+	if err != nil {
+		return schema, err
+	}
+
+	for _, t := range tables {
+		schema.Tables = append(schema.Tables, SchemaTable{
+			Name:     t.Name,
+			Locality: t.Locality,
+			Columns:  columns[t.Name],
+			Indexes:  indexes[t.Name],
+			FKs:      t.FKs,
+		})
+	}
+
+	schema.Zones, err = a.AllZoneConfigurations()
+	if err != nil {
+		return schema, err
+	}
+	return schema, nil
+}
+
+// Columns returns the columns of each table in the database, keyed by table name
+func (a *Analyzer) Columns() (map[string][]Column, error) {
+	columns := make(map[string][]Column)
+	rows, err := a.Db.Columns(a.Config.Database)
+	if err != nil {
+		return columns, err
+	}
+	for _, row := range rows {
+		columns[row.Table] = append(columns[row.Table], Column{
+			Name:     row.Name,
+			Type:     row.DataType,
+			Nullable: row.Nullable,
+			Default:  row.Default,
+			Hidden:   row.Hidden,
+			Computed: row.Generated,
+		})
+	}
+	return columns, nil
+}
+
+// Indexes returns the indexes of each table in the database, keyed by table name
+func (a *Analyzer) Indexes() (map[string][]Index, error) {
+	indexes := make(map[string][]Index)
+	crows, err := a.Db.Constraints(a.Config.Database)
+	if err != nil {
+		return indexes, err
+	}
+	primary := make(map[string]bool)
+	for _, crow := range crows {
+		if crow.Type == "PRIMARY KEY" {
+			primary[crow.Table+"."+crow.Name] = true
+		}
+	}
+
+	rows, err := a.Db.Indexes()
+	if err != nil {
+		return indexes, err
+	}
+	for _, row := range rows {
+		indexes[row.Table] = append(indexes[row.Table], Index{
+			Name:       row.Name,
+			Definition: removeDatabaseFromIndexDefinition(row.Definition, a.Config.Database),
+			Primary:    primary[row.Table+"."+row.Name],
+		})
+	}
+	return indexes, nil
+}
+
+// IsUnique determines whether the index is a unique index, including those backing unique constraints
+func (i Index) IsUnique() bool {
+	return strings.HasPrefix(strings.ToUpper(i.Definition), "CREATE UNIQUE INDEX")
+}
+
+// removeDatabaseFromIndexDefinition removes the database from the table name in a CREATE INDEX statement
+// so definitions can be compared between databases with different names
+func removeDatabaseFromIndexDefinition(definition string, database string) string {
+	for _, name := range []string{database, quoteIdentifier(database)} {
+		definition = strings.Replace(definition, fmt.Sprintf(" ON %s.", name), " ON ", 1)
+	}
+	return definition
+}
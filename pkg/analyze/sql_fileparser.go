@@ -31,6 +31,7 @@ func (s *SqlFileParser) Parse() ([][]string, error) {
 // ParseReader parses a io.Reader into batches of statements.
 // Each batch is a slice of complete SQL statements (terminated by ";").
 // Blocks between -- BEGIN BLOCK and -- END BLOCK are grouped together as one batch.
+// A -- KEYSET BATCH directive stays at the start of the statement it precedes.
 func (s *SqlFileParser) ParseReader(r io.Reader) ([][]string, error) {
 	scanner := bufio.NewScanner(r)
 
@@ -78,6 +79,12 @@ func (s *SqlFileParser) ParseReader(r io.Reader) ([][]string, error) {
 			continue
 		}
 
+		// The keyset batch directive is kept with the statement it precedes
+		if trimmed == KeysetBatchDirective {
+			currentStmtLines = append(currentStmtLines, trimmed)
+			continue
+		}
+
 		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
 			continue
 		}
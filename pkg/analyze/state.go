@@ -0,0 +1,154 @@
+package analyze
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ExecutionStateFile is the name of the execution state file in a plan directory
+const ExecutionStateFile = "state.json"
+
+// ExecutionState is the progress of an execution, so an interrupted execution resumes. It holds the progress of
+// keyset batch statements by statement checksum and, for a plan, the phases already executed and the state
+// the remaining phases expect. It is saved to a file after every change when the file is set.
+type ExecutionState struct {
+	Path       string                     `json:"-"`
+	Statements map[string]*KeysetProgress `json:"statements"`
+	// Phases are the phases of the plan executed so far
+	Phases []string `json:"phases,omitempty"`
+	// Blocks are the blocks that completed, by phase, so a phase interrupted partway through only runs the
+	// blocks left. Statements executed without a plan are under the empty phase.
+	Blocks map[string][]string `json:"blocks,omitempty"`
+	// Started is the phase a run started and did not complete, which may be partly applied
+	Started string `json:"started,omitempty"`
+	// Expected is the state of the objects the remaining phases change, as left by the last completed block or,
+	// for a plan that runs after another one, by the last completed block of that plan
+	Expected *Schema `json:"expected,omitempty"`
+	mu       sync.Mutex
+}
+
+// ReadExecutionState reads the execution state from the file, or starts a new state when the file does not exist
+func ReadExecutionState(path string) (*ExecutionState, error) {
+	state := &ExecutionState{Path: path, Statements: make(map[string]*KeysetProgress)}
+	if path == "" {
+		return state, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("invalid execution state in %s: %w", path, err)
+	}
+	if state.Statements == nil {
+		state.Statements = make(map[string]*KeysetProgress)
+	}
+	return state, nil
+}
+
+// Progress returns a copy of the progress of the keyset batch statement
+func (s *ExecutionState) Progress(statement string) KeysetProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if progress, ok := s.Statements[sha256Hex([]byte(statement))]; ok {
+		return *progress
+	}
+	return KeysetProgress{}
+}
+
+// Update records the progress of the keyset batch statement and saves the state
+func (s *ExecutionState) Update(statement string, progress KeysetProgress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Statements[sha256Hex([]byte(statement))] = &progress
+	return s.save()
+}
+
+// blockKey identifies a block of a phase by its position and statements
+func blockKey(i int, block []string) string {
+	return fmt.Sprintf("%d %s", i+1, sha256Hex([]byte(strings.Join(block, "\n"))))
+}
+
+// BlockCompleted determines whether the i-th block of the phase completed
+func (s *ExecutionState) BlockCompleted(phase string, i int, block []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.Blocks[phase], blockKey(i, block))
+}
+
+// CompleteBlock records the i-th block of the phase as completed, with the state the remaining phases expect
+// when it is known, and saves the state
+func (s *ExecutionState) CompleteBlock(phase string, i int, block []string, expected *Schema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Blocks == nil {
+		s.Blocks = make(map[string][]string)
+	}
+	s.Blocks[phase] = append(s.Blocks[phase], blockKey(i, block))
+	if expected != nil {
+		s.Expected = expected
+	}
+	return s.save()
+}
+
+// Executed determines whether the phase was executed
+func (s *ExecutionState) Executed(phase string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.Phases, phase)
+}
+
+// StartPhase records the phase as started and saves the state
+func (s *ExecutionState) StartPhase(phase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Started = phase
+	return s.save()
+}
+
+// CompletePhase records the phase as executed, with the state the remaining phases expect, and saves the state
+func (s *ExecutionState) CompletePhase(phase string, expected Schema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.Phases, phase) {
+		s.Phases = append(s.Phases, phase)
+	}
+	if s.Started == phase {
+		s.Started = ""
+	}
+	s.Expected = &expected
+	return s.save()
+}
+
+// Expect records the state the plan expects when it starts, once the plan it runs after changed it, and saves
+// the state
+func (s *ExecutionState) Expect(expected Schema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Expected = &expected
+	return s.save()
+}
+
+func (s *ExecutionState) save() error {
+	if s.Path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Replace the file in one step so an interrupted write never loses the previous state
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
@@ -1,24 +1,271 @@
 package analyze
 
-import "fmt"
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
 
 type Table struct {
 	Database          string
 	Name              string
 	LogicalSizeBytes  uint64
+	RangeCount        int
 	Owner             string
 	EstimatedRowCount int
-	Locality          string
+	Locality          Locality
 	FKs               []FKConstraint
 	ReferencedFKs     []FKConstraint
 }
 
+// TableFilter limits and orders the tables returned by Analyzer.Tables
+type TableFilter struct {
+	Include      []string
+	Exclude      []string
+	Localities   []LocalityType
+	MinSizeBytes uint64
+	MinRows      int
+	Sort         TableSort
+	Top          int
+
+	include []tablePattern
+	exclude []tablePattern
+}
+
+// TableSort is the key used to order tables
+type TableSort string
+
+const (
+	TableSortDefault     TableSort = ""
+	TableSortSize        TableSort = "size"
+	TableSortRows        TableSort = "rows"
+	TableSortBytesPerRow TableSort = "bytes-per-row"
+	TableSortFKs         TableSort = "fks"
+	TableSortReferenced  TableSort = "referenced"
+	TableSortName        TableSort = "name"
+)
+
+// tableRegexPrefix marks a table pattern as a regular expression rather than a glob
+const tableRegexPrefix = "re:"
+
+type tablePattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
 func (t Table) String() string {
-	bytesPerRow := uint64(0)
-	if t.EstimatedRowCount > 0 {
-		bytesPerRow = t.LogicalSizeBytes / uint64(t.EstimatedRowCount)
-	}
 	return fmt.Sprintf("Database: %s, Name: %s, Locality: %s, Logical Size: %s, Row Count: %d, Avg Row Size: %s, FKs: %d, Referenced FKs: %d",
-		t.Database, t.Name, t.Locality, formatBytes(t.LogicalSizeBytes), t.EstimatedRowCount, formatBytes(uint64(bytesPerRow)),
+		t.Database, t.Name, t.Locality, formatBytes(t.LogicalSizeBytes), t.EstimatedRowCount, formatBytes(t.BytesPerRow()),
 		len(t.FKs), len(t.ReferencedFKs))
 }
+
+// BytesPerRow is the average logical bytes per row, or zero if the row count is unknown
+func (t Table) BytesPerRow() uint64 {
+	if t.EstimatedRowCount > 0 {
+		return t.LogicalSizeBytes / uint64(t.EstimatedRowCount)
+	}
+	return 0
+}
+
+// NewTableFilter creates a table filter. Include and exclude patterns are globs, or regular expressions
+// when prefixed with "re:". Localities are RBR, RBT or GLOBAL.
+func NewTableFilter(include []string, exclude []string, localities []string,
+	minSizeBytes uint64, minRows int, sortStr string, top int) (*TableFilter, error) {
+
+	includePatterns, err := parseTablePatterns(include)
+	if err != nil {
+		return nil, err
+	}
+	excludePatterns, err := parseTablePatterns(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var localityTypes []LocalityType
+	for _, locality := range localities {
+		localityType, err := parseLocalityType(locality)
+		if err != nil {
+			return nil, err
+		}
+		localityTypes = append(localityTypes, localityType)
+	}
+
+	tableSort, err := parseTableSort(sortStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if top < 0 {
+		return nil, fmt.Errorf("invalid top: %d", top)
+	}
+
+	return &TableFilter{
+		Include:      include,
+		Exclude:      exclude,
+		Localities:   localityTypes,
+		MinSizeBytes: minSizeBytes,
+		MinRows:      minRows,
+		Sort:         tableSort,
+		Top:          top,
+		include:      includePatterns,
+		exclude:      excludePatterns,
+	}, nil
+}
+
+// MatchesName determines whether an object name passes the include and exclude patterns
+func (filter *TableFilter) MatchesName(name string) bool {
+	if len(filter.include) > 0 && !matchesAnyTablePattern(filter.include, name) {
+		return false
+	}
+	return !matchesAnyTablePattern(filter.exclude, name)
+}
+
+// Matches determines whether a table passes the filter, ignoring sort and top
+func (filter *TableFilter) Matches(t Table) bool {
+	if !filter.MatchesName(t.Name) {
+		return false
+	}
+	if len(filter.Localities) > 0 {
+		found := false
+		for _, locality := range filter.Localities {
+			if t.Locality.Type == locality {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if t.LogicalSizeBytes < filter.MinSizeBytes {
+		return false
+	}
+	if t.EstimatedRowCount < filter.MinRows {
+		return false
+	}
+	return true
+}
+
+// Apply filters, sorts and truncates tables
+func (filter *TableFilter) Apply(tables []Table) []Table {
+	var filtered []Table
+	for _, t := range tables {
+		if filter.Matches(t) {
+			filtered = append(filtered, t)
+		}
+	}
+	sortTables(filtered, filter.Sort)
+	if filter.Top > 0 && len(filtered) > filter.Top {
+		filtered = filtered[:filter.Top]
+	}
+	return filtered
+}
+
+// needsSize is true if the filter can't be applied without table sizes
+func (filter *TableFilter) needsSize() bool {
+	return filter.MinSizeBytes > 0 || filter.Sort == TableSortSize || filter.Sort == TableSortBytesPerRow
+}
+
+// needsFKs is true if the filter can't be applied without FK relationships
+func (filter *TableFilter) needsFKs() bool {
+	return filter.Sort == TableSortFKs || filter.Sort == TableSortReferenced
+}
+
+// sortTables sorts tables by the sort key. Numeric keys sort descending, with name as the tie-breaker.
+func sortTables(tables []Table, tableSort TableSort) {
+	var key func(t Table) uint64
+	switch tableSort {
+	case TableSortSize:
+		key = func(t Table) uint64 { return t.LogicalSizeBytes }
+	case TableSortRows:
+		key = func(t Table) uint64 { return uint64(t.EstimatedRowCount) }
+	case TableSortBytesPerRow:
+		key = func(t Table) uint64 { return t.BytesPerRow() }
+	case TableSortFKs:
+		key = func(t Table) uint64 { return uint64(len(t.FKs)) }
+	case TableSortReferenced:
+		key = func(t Table) uint64 { return uint64(len(t.ReferencedFKs)) }
+	case TableSortName:
+		sort.SliceStable(tables, func(i, j int) bool {
+			return tables[i].Name < tables[j].Name
+		})
+		return
+	default:
+		sort.SliceStable(tables, func(i, j int) bool {
+			// sort by logical size bytes desc, if present
+			if tables[i].LogicalSizeBytes > 0 || tables[j].LogicalSizeBytes > 0 {
+				return tables[i].LogicalSizeBytes > tables[j].LogicalSizeBytes
+			}
+			// sort by row count desc, if present
+			if tables[i].EstimatedRowCount > 0 || tables[j].EstimatedRowCount > 0 {
+				return tables[i].EstimatedRowCount > tables[j].EstimatedRowCount
+			}
+			// otherwise, sort by name asc
+			return tables[i].Name < tables[j].Name
+		})
+		return
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		if key(tables[i]) != key(tables[j]) {
+			return key(tables[i]) > key(tables[j])
+		}
+		return tables[i].Name < tables[j].Name
+	})
+}
+
+func parseTableSort(s string) (TableSort, error) {
+	switch TableSort(strings.ToLower(strings.TrimSpace(s))) {
+	case TableSortDefault:
+		return TableSortDefault, nil
+	case TableSortSize:
+		return TableSortSize, nil
+	case TableSortRows:
+		return TableSortRows, nil
+	case TableSortBytesPerRow:
+		return TableSortBytesPerRow, nil
+	case TableSortFKs:
+		return TableSortFKs, nil
+	case TableSortReferenced:
+		return TableSortReferenced, nil
+	case TableSortName:
+		return TableSortName, nil
+	default:
+		return "", fmt.Errorf("invalid sort: %q", s)
+	}
+}
+
+func parseTablePatterns(patterns []string) ([]tablePattern, error) {
+	var parsed []tablePattern
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, tableRegexPrefix) {
+			re, err := regexp.Compile(strings.TrimPrefix(pattern, tableRegexPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+			}
+			parsed = append(parsed, tablePattern{re: re})
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+		}
+		parsed = append(parsed, tablePattern{glob: pattern})
+	}
+	return parsed, nil
+}
+
+func matchesAnyTablePattern(patterns []tablePattern, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.re != nil {
+			if pattern.re.MatchString(name) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
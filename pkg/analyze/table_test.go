@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var filterTestTables = []Table{
+	{Name: "orders", LogicalSizeBytes: 4096, EstimatedRowCount: 4, Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+	{Name: "order_items", LogicalSizeBytes: 8192, EstimatedRowCount: 64, Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+	{Name: "customers", LogicalSizeBytes: 2048, EstimatedRowCount: 1, Locality: Locality{Type: LocalityTypeRegionalByTable}},
+	{Name: "countries", LogicalSizeBytes: 1024, EstimatedRowCount: 200, Locality: Locality{Type: LocalityTypeGlobal}},
+}
+
+func tableNames(tables []Table) []string {
+	var names []string
+	for _, t := range tables {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestTableFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		include    []string
+		exclude    []string
+		localities []string
+		minSize    uint64
+		minRows    int
+		sort       string
+		top        int
+		expected   []string
+	}{
+		{name: "default sort", expected: []string{"order_items", "orders", "customers", "countries"}},
+		{name: "glob include", include: []string{"order*"}, sort: "name", expected: []string{"order_items", "orders"}},
+		{name: "regex exclude", exclude: []string{"re:^c.*s$"}, sort: "name", expected: []string{"order_items", "orders"}},
+		{name: "locality", localities: []string{"rbt", "GLOBAL"}, sort: "name", expected: []string{"countries", "customers"}},
+		{name: "min size", minSize: 4096, sort: "name", expected: []string{"order_items", "orders"}},
+		{name: "min rows", minRows: 64, sort: "rows", expected: []string{"countries", "order_items"}},
+		{name: "bytes per row", sort: "bytes-per-row", expected: []string{"customers", "orders", "order_items", "countries"}},
+		{name: "top", sort: "size", top: 2, expected: []string{"order_items", "orders"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := NewTableFilter(test.include, test.exclude, test.localities,
+				test.minSize, test.minRows, test.sort, test.top)
+			require.NoError(t, err)
+			tables := append([]Table{}, filterTestTables...)
+			assert.Equal(t, test.expected, tableNames(filter.Apply(tables)))
+		})
+	}
+}
+
+func TestTableFilterInvalid(t *testing.T) {
+	_, err := NewTableFilter(nil, nil, nil, 0, 0, "color", 0)
+	assert.Error(t, err)
+	_, err = NewTableFilter(nil, nil, []string{"REGIONAL"}, 0, 0, "", 0)
+	assert.Error(t, err)
+	_, err = NewTableFilter([]string{"re:("}, nil, nil, 0, 0, "", 0)
+	assert.Error(t, err)
+	_, err = NewTableFilter([]string{"["}, nil, nil, 0, 0, "", 0)
+	assert.Error(t, err)
+}
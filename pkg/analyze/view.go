@@ -0,0 +1,261 @@
+package analyze
+
+import (
+	"fmt"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ObjectKind is the kind of object reported by SHOW TABLES
+type ObjectKind string
+
+const (
+	ObjectKindTable            ObjectKind = "table"
+	ObjectKindView             ObjectKind = "view"
+	ObjectKindMaterializedView ObjectKind = "materialized view"
+	ObjectKindSequence         ObjectKind = "sequence"
+)
+
+type View struct {
+	Database         string
+	Schema           string
+	Name             string
+	Kind             ObjectKind
+	Owner            string
+	Definition       string
+	DependsOn        []string
+	LogicalSizeBytes uint64
+	LastRefresh      *time.Time
+}
+
+type Sequence struct {
+	Database  string
+	Schema    string
+	Name      string
+	Owner     string
+	DataType  string
+	Start     int64
+	Min       int64
+	Max       int64
+	Increment int64
+	Cycle     bool
+	Cache     int64
+	LastValue *int64
+}
+
+var viewDefinitionRe = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:MATERIALIZED\s+)?VIEW\s+.+?\s+AS\s+(.*?);?\s*$`)
+
+func (v View) String() string {
+	s := fmt.Sprintf("Database: %s, Name: %s, Kind: %s, Depends On: [%s]",
+		v.Database, v.Name, v.Kind, strings.Join(v.DependsOn, ", "))
+	if v.Kind == ObjectKindMaterializedView {
+		lastRefresh := "unknown"
+		if v.LastRefresh != nil {
+			lastRefresh = v.LastRefresh.Format(time.RFC3339)
+		}
+		s = fmt.Sprintf("%s, Logical Size: %s, Last Refresh: %s", s, formatBytes(v.LogicalSizeBytes), lastRefresh)
+	}
+	return s
+}
+
+// DependsOnTable determines whether the view selects from the table
+func (v View) DependsOnTable(table string) bool {
+	for _, dep := range v.DependsOn {
+		if dep == table {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Sequence) String() string {
+	lastValue := "none"
+	if s.LastValue != nil {
+		lastValue = fmt.Sprintf("%d", *s.LastValue)
+	}
+	return fmt.Sprintf("Database: %s, Name: %s, Kind: %s, Type: %s, Start: %d, Min: %d, Max: %d, Increment: %d, Cache: %d, Cycle: %t, Last Value: %s",
+		s.Database, s.Name, ObjectKindSequence, s.DataType, s.Start, s.Min, s.Max, s.Increment, s.Cache, s.Cycle, lastValue)
+}
+
+// Views returns views and materialized views for the database, along with the tables they depend on.
+// If a filter is provided, only views with matching names are returned.
+func (a *Analyzer) Views(filter *TableFilter) ([]View, error) {
+	var views []View
+
+	srows, err := a.Db.ShowTables(a.Config.Database)
+	if err != nil {
+		return views, err
+	}
+	// Views are keyed by schema and name, since views in different schemas may share a name
+	vmap := make(map[string]View)
+	for _, srow := range srows {
+		kind := ObjectKind(srow.Type)
+		if kind != ObjectKindView && kind != ObjectKindMaterializedView {
+			continue
+		}
+		if filter != nil && !filter.MatchesName(srow.Name) {
+			continue
+		}
+		vmap[viewKey(srow.Schema, srow.Name)] = View{
+			Database: a.Config.Database,
+			Schema:   srow.Schema,
+			Name:     srow.Name,
+			Kind:     kind,
+			Owner:    srow.Owner,
+		}
+	}
+	if len(vmap) == 0 {
+		return views, nil
+	}
+
+	// Definitions
+	vrows, err := a.Db.Views(a.Config.Database)
+	if err != nil {
+		return views, err
+	}
+	for _, vrow := range vrows {
+		key := viewKey(vrow.Schema, vrow.Name)
+		if v, ok := vmap[key]; ok {
+			v.Definition = parseViewDefinition(vrow.CreateStatement)
+			vmap[key] = v
+		}
+	}
+
+	// Dependencies
+	drows, err := a.Db.Dependencies(a.Config.Database)
+	if err != nil {
+		return views, err
+	}
+	for _, drow := range drows {
+		key := viewKey(drow.Schema, drow.Name)
+		if v, ok := vmap[key]; ok && !v.DependsOnTable(drow.DependsOnName) {
+			v.DependsOn = append(v.DependsOn, drow.DependsOnName)
+			vmap[key] = v
+		}
+	}
+
+	// Materialized view sizes and refreshes
+	if hasMaterializedView(vmap) {
+		sizes, err := a.Db.TableSize(a.Config.Database)
+		if err != nil {
+			return views, err
+		}
+		for _, size := range sizes {
+			key := viewKey(size.Schema, size.Name)
+			if v, ok := vmap[key]; ok && v.Kind == ObjectKindMaterializedView {
+				v.LogicalSizeBytes = size.LogicalBytes
+				vmap[key] = v
+			}
+		}
+
+		refreshes, err := a.Db.MaterializedViewRefreshes(a.Config.Database)
+		if err != nil {
+			return views, err
+		}
+		applyMaterializedViewRefreshes(vmap, refreshes)
+	}
+
+	for _, v := range vmap {
+		sort.Strings(v.DependsOn)
+		views = append(views, v)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Name != views[j].Name {
+			return views[i].Name < views[j].Name
+		}
+		return views[i].Schema < views[j].Schema
+	})
+	return views, nil
+}
+
+// Sequences returns sequences and their settings for the database.
+// If a filter is provided, only sequences with matching names are returned.
+func (a *Analyzer) Sequences(filter *TableFilter) ([]Sequence, error) {
+	var sequences []Sequence
+
+	srows, err := a.Db.ShowTables(a.Config.Database)
+	if err != nil {
+		return sequences, err
+	}
+	owners := sequenceOwners(srows)
+
+	rows, err := a.Db.Sequences(a.Config.Database)
+	if err != nil {
+		return sequences, err
+	}
+	for _, row := range rows {
+		if filter != nil && !filter.MatchesName(row.Name) {
+			continue
+		}
+		sequences = append(sequences, Sequence{
+			Database:  a.Config.Database,
+			Schema:    row.Schema,
+			Name:      row.Name,
+			Owner:     owners[viewKey(row.Schema, row.Name)],
+			DataType:  row.DataType,
+			Start:     row.Start,
+			Min:       row.Min,
+			Max:       row.Max,
+			Increment: row.Increment,
+			Cycle:     row.Cycle,
+			Cache:     row.Cache,
+			LastValue: row.LastValue,
+		})
+	}
+	return sequences, nil
+}
+
+// sequenceOwners keys the owner of each sequence by its schema and name, since sequences in different schemas
+// can have the same name
+func sequenceOwners(srows []db.ShowTablesRow) map[string]string {
+	owners := make(map[string]string)
+	for _, srow := range srows {
+		if ObjectKind(srow.Type) == ObjectKindSequence {
+			owners[viewKey(srow.Schema, srow.Name)] = srow.Owner
+		}
+	}
+	return owners
+}
+
+// parseViewDefinition extracts the query from a CREATE VIEW or CREATE MATERIALIZED VIEW statement
+func parseViewDefinition(createStatement string) string {
+	matches := viewDefinitionRe.FindStringSubmatch(createStatement)
+	if len(matches) == 2 {
+		return strings.TrimSpace(matches[1])
+	}
+	return createStatement
+}
+
+// viewKey identifies a view or sequence by its schema and name
+func viewKey(schema string, name string) string {
+	return schema + "." + name
+}
+
+// applyMaterializedViewRefreshes sets the last refresh of each materialized view from the jobs that created or
+// refreshed it, which are matched to the view by the descriptors of the jobs
+func applyMaterializedViewRefreshes(vmap map[string]View, refreshes []db.RefreshRow) {
+	for _, refresh := range refreshes {
+		key := viewKey(refresh.Schema, refresh.Name)
+		v, ok := vmap[key]
+		if !ok || v.Kind != ObjectKindMaterializedView {
+			continue
+		}
+		if v.LastRefresh == nil || refresh.Finished.After(*v.LastRefresh) {
+			finished := refresh.Finished
+			v.LastRefresh = &finished
+			vmap[key] = v
+		}
+	}
+}
+
+func hasMaterializedView(vmap map[string]View) bool {
+	for _, v := range vmap {
+		if v.Kind == ObjectKindMaterializedView {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package analyze
+
+import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseViewDefinition(t *testing.T) {
+	assert.Equal(t, "SELECT id, name FROM d.public.customers",
+		parseViewDefinition("CREATE VIEW public.v (id, name) AS SELECT id, name FROM d.public.customers"))
+	assert.Equal(t, "SELECT count(*) FROM d.public.orders",
+		parseViewDefinition("CREATE MATERIALIZED VIEW public.mv (count) AS SELECT count(*) FROM d.public.orders;"))
+}
+
+func TestApplyMaterializedViewRefreshes(t *testing.T) {
+	vmap := map[string]View{
+		viewKey("public", "mv"):  {Schema: "public", Name: "mv", Kind: ObjectKindMaterializedView},
+		viewKey("reports", "mv"): {Schema: "reports", Name: "mv", Kind: ObjectKindMaterializedView},
+		viewKey("public", "v"):   {Schema: "public", Name: "v", Kind: ObjectKindView},
+	}
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	applyMaterializedViewRefreshes(vmap, []db.RefreshRow{
+		{Schema: "public", Name: "mv", Finished: first},
+		{Schema: "reports", Name: "mv", Finished: second},
+		{Schema: "public", Name: "v", Finished: second},
+		{Schema: "other", Name: "mv", Finished: second},
+	})
+	assert.Equal(t, first, *vmap[viewKey("public", "mv")].LastRefresh)
+	assert.Equal(t, second, *vmap[viewKey("reports", "mv")].LastRefresh)
+	assert.Nil(t, vmap[viewKey("public", "v")].LastRefresh)
+}
+
+func TestSequenceOwners(t *testing.T) {
+	assert.Equal(t, map[string]string{"public.ids": "root", "billing.ids": "billing"}, sequenceOwners([]db.ShowTablesRow{
+		{Schema: "public", Name: "ids", Type: string(ObjectKindSequence), Owner: "root"},
+		{Schema: "billing", Name: "ids", Type: string(ObjectKindSequence), Owner: "billing"},
+		{Schema: "public", Name: "users", Type: "table", Owner: "root"},
+	}))
+}
@@ -3,27 +3,44 @@ package analyze
 import (
 	"errors"
 	"fmt"
-	"regexp"
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
+	"github.com/sirupsen/logrus"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 type ZoneConfig struct {
-	Target           string
-	RangeMinBytes    int
-	RangeMaxBytes    int
-	GcTtlSeconds     int
-	NumReplicas      int
-	NumVoters        int
-	Constraints      []ZoneConfigConstraint
-	VoterConstraints []ZoneConfigConstraint
-	LeasePreferences []ZoneConfigConstraint
+	Target                string                  `json:"target,omitempty" yaml:"target,omitempty"`
+	RangeMinBytes         int                     `json:"range_min_bytes,omitempty" yaml:"range_min_bytes,omitempty"`
+	RangeMaxBytes         int                     `json:"range_max_bytes,omitempty" yaml:"range_max_bytes,omitempty"`
+	GcTtlSeconds          int                     `json:"gc_ttlseconds,omitempty" yaml:"gc_ttlseconds,omitempty"`
+	GlobalReads           bool                    `json:"global_reads,omitempty" yaml:"global_reads,omitempty"`
+	NumReplicas           int                     `json:"num_replicas,omitempty" yaml:"num_replicas,omitempty"`
+	NumVoters             int                     `json:"num_voters,omitempty" yaml:"num_voters,omitempty"`
+	Constraints           []ZoneConfigConjunction `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+	VoterConstraints      []ZoneConfigConjunction `json:"voter_constraints,omitempty" yaml:"voter_constraints,omitempty"`
+	LeasePreferences      []ZoneConfigConjunction `json:"lease_preferences,omitempty" yaml:"lease_preferences,omitempty"`
+	ExcludeDataFromBackup bool                    `json:"exclude_data_from_backup,omitempty" yaml:"exclude_data_from_backup,omitempty"`
+	// Unknown holds fields that are not modeled, with their values as they appeared in the SQL
+	Unknown []ZoneConfigField `json:"unknown,omitempty" yaml:"unknown,omitempty"`
+	// Fields holds the names of the fields that were set, in the order they appeared
+	Fields []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// RawSql is the statement the configuration was parsed from, when read from the cluster
+	RawSql string `json:"raw_sql,omitempty" yaml:"raw_sql,omitempty"`
+}
+
+// ZoneConfigConjunction is a set of constraints that must all be satisfied. NumReplicas is the number of
+// replicas the conjunction applies to in the per-replica map form, or zero when it applies to all replicas.
+type ZoneConfigConjunction struct {
+	Constraints []ZoneConfigConstraint `json:"constraints" yaml:"constraints"`
+	NumReplicas int                    `json:"num_replicas,omitempty" yaml:"num_replicas,omitempty"`
 }
 
 type ZoneConfigConstraint struct {
-	Value string
-	Type  ZoneConfigConstraintType
-	Scope int
+	Type  ZoneConfigConstraintType `json:"type" yaml:"type"`
+	Key   string                   `json:"key,omitempty" yaml:"key,omitempty"`
+	Value string                   `json:"value" yaml:"value"`
 }
 
 type ZoneConfigConstraintType string
@@ -33,7 +50,93 @@ const (
 	ZoneConfigConstraintTypeProhibited ZoneConfigConstraintType = "-"
 )
 
-// AllZoneConfigurations retrieves all zone configurations
+// ZoneConfigField is a zone configuration field that is not otherwise modeled
+type ZoneConfigField struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+const (
+	ZoneFieldRangeMinBytes         = "range_min_bytes"
+	ZoneFieldRangeMaxBytes         = "range_max_bytes"
+	ZoneFieldGcTtlSeconds          = "gc.ttlseconds"
+	ZoneFieldGlobalReads           = "global_reads"
+	ZoneFieldNumReplicas           = "num_replicas"
+	ZoneFieldNumVoters             = "num_voters"
+	ZoneFieldConstraints           = "constraints"
+	ZoneFieldVoterConstraints      = "voter_constraints"
+	ZoneFieldLeasePreferences      = "lease_preferences"
+	ZoneFieldExcludeDataFromBackup = "exclude_data_from_backup"
+)
+
+// zoneCopyFromParent is the value that resets a field to its inherited value
+const zoneCopyFromParent = "COPY FROM PARENT"
+
+// Region returns the region named by a region=<name> constraint
+func (c ZoneConfigConstraint) Region() (string, bool) {
+	if c.Key == "region" {
+		return c.Value, true
+	}
+	return "", false
+}
+
+func (c ZoneConfigConstraint) String() string {
+	if c.Key == "" {
+		return fmt.Sprintf("%s%s", c.Type, c.Value)
+	}
+	return fmt.Sprintf("%s%s=%s", c.Type, c.Key, c.Value)
+}
+
+// Regions returns the required regions in the conjunction
+func (c ZoneConfigConjunction) Regions() []string {
+	var regions []string
+	for _, constraint := range c.Constraints {
+		if region, ok := constraint.Region(); ok && constraint.Type == ZoneConfigConstraintTypeRequired {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// ParsedTarget parses the zone configuration target
+func (zc ZoneConfig) ParsedTarget() (ZoneTarget, error) {
+	return ParseZoneTarget(zc.Target)
+}
+
+// Has determines whether the field was set
+func (zc ZoneConfig) Has(field string) bool {
+	for _, f := range zc.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaseRegion returns the region of the first lease preference
+func (zc ZoneConfig) LeaseRegion() (string, bool) {
+	if len(zc.LeasePreferences) == 0 {
+		return "", false
+	}
+	regions := zc.LeasePreferences[0].Regions()
+	if len(regions) == 0 {
+		return "", false
+	}
+	return regions[0], true
+}
+
+// AllConstraints returns every constraint in constraints, voter constraints and lease preferences
+func (zc ZoneConfig) AllConstraints() []ZoneConfigConstraint {
+	var constraints []ZoneConfigConstraint
+	for _, conjunctions := range [][]ZoneConfigConjunction{zc.Constraints, zc.VoterConstraints, zc.LeasePreferences} {
+		for _, conjunction := range conjunctions {
+			constraints = append(constraints, conjunction.Constraints...)
+		}
+	}
+	return constraints
+}
+
+// AllZoneConfigurations retrieves all zone configurations for the database
 func (a *Analyzer) AllZoneConfigurations() ([]ZoneConfig, error) {
 	var zones []ZoneConfig
 
@@ -41,169 +144,447 @@ func (a *Analyzer) AllZoneConfigurations() ([]ZoneConfig, error) {
 	if err != nil {
 		return zones, err
 	}
+	return zoneConfigsInDatabase(a.Config.Database, rs)
+}
 
+// zoneConfigsInDatabase parses the zone configuration rows that belong to the database. Rows with a target that
+// cannot be parsed, which may belong to any database, are skipped with a warning.
+func zoneConfigsInDatabase(database string, rs []db.ZoneConfigRow) ([]ZoneConfig, error) {
+	var zones []ZoneConfig
 	for _, r := range rs {
 
-		if !strings.Contains(r.Target, fmt.Sprintf(" %s", a.Config.Database)) {
+		target, err := ParseZoneTarget(r.Target)
+		if err != nil {
+			logrus.Warnf("Skipping zone config: %s", err)
+			continue
+		}
+		if !target.InDatabase(database) {
 			continue
 		}
 		zc, err := parseZoneConfig(r.RawConfigSql)
 		if err != nil {
-			return zones, err
+			return zones, fmt.Errorf("zone config: %s: %w", r.Target, err)
 		}
 		zc.Target = r.Target
+		zc.RawSql = r.RawConfigSql
 		zones = append(zones, zc)
 	}
 	return zones, nil
 }
 
-// parseZoneConfig takes a string zone configuration and parses it into an the ZoneConfig struct
+// parseZoneConfig takes a string zone configuration and parses it into an the ZoneConfig struct.
+// The input is either a full ALTER ... CONFIGURE ZONE USING statement or just the list of fields.
 func parseZoneConfig(input string) (ZoneConfig, error) {
 	var config ZoneConfig
 
-	// Range min bytes
-	re := regexp.MustCompile(`range_min_bytes = (\d+)`)
-	matches := re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		val, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return config, err
-		}
-		config.RangeMinBytes = val
+	tokens, err := tokenizeZoneConfig(input)
+	if err != nil {
+		return config, err
 	}
 
-	// Range max bytes
-	re = regexp.MustCompile(`range_max_bytes = (\d+)`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		val, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return config, err
+	// Skip to the field list
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].isWord("CONFIGURE") && tokens[i+1].isWord("ZONE") && tokens[i+2].isWord("USING") {
+			tokens = tokens[i+3:]
+			break
 		}
-		config.RangeMaxBytes = val
 	}
 
-	// GC TTL seconds
-	re = regexp.MustCompile(`gc.ttlseconds = (\d+)`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		val, err := strconv.Atoi(matches[1])
-		if err != nil {
+	// Split into name = value fields on top-level commas
+	for len(tokens) > 0 {
+		if len(tokens) < 3 || tokens[0].kind != zoneTokenWord || tokens[1].kind != zoneTokenEquals {
+			return config, fmt.Errorf("zone config: expected <field> = <value> at %q", tokens[0].text)
+		}
+		name := strings.ToLower(tokens[0].text)
+		end := 2
+		for end < len(tokens) && tokens[end].kind != zoneTokenComma {
+			end++
+		}
+		if end == 2 {
+			return config, fmt.Errorf("zone config: missing value for %s", name)
+		}
+		if err := config.setField(name, tokens[2:end]); err != nil {
 			return config, err
 		}
-		config.GcTtlSeconds = val
+		if end < len(tokens) {
+			end++ // skip comma
+		}
+		tokens = tokens[end:]
 	}
 
-	// Num voters
-	re = regexp.MustCompile(`num_voters = (\d+)`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		val, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return config, err
-		}
-		config.NumVoters = val
+	return config, nil
+}
+
+// setField sets a field from its value tokens
+func (zc *ZoneConfig) setField(name string, value []zoneToken) error {
+	raw := joinZoneTokens(value)
+
+	// Resetting to the parent value is the same as not setting it
+	if strings.EqualFold(raw, zoneCopyFromParent) {
+		return nil
 	}
 
-	// Num replicas
-	re = regexp.MustCompile(`num_replicas = (\d+)`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		val, err := strconv.Atoi(matches[1])
+	var err error
+	switch name {
+	case ZoneFieldRangeMinBytes:
+		zc.RangeMinBytes, err = zoneIntValue(name, value)
+	case ZoneFieldRangeMaxBytes:
+		zc.RangeMaxBytes, err = zoneIntValue(name, value)
+	case ZoneFieldGcTtlSeconds:
+		zc.GcTtlSeconds, err = zoneIntValue(name, value)
+	case ZoneFieldNumReplicas:
+		zc.NumReplicas, err = zoneIntValue(name, value)
+	case ZoneFieldNumVoters:
+		zc.NumVoters, err = zoneIntValue(name, value)
+	case ZoneFieldGlobalReads:
+		zc.GlobalReads, err = zoneBoolValue(name, value)
+	case ZoneFieldExcludeDataFromBackup:
+		zc.ExcludeDataFromBackup, err = zoneBoolValue(name, value)
+	case ZoneFieldConstraints, ZoneFieldVoterConstraints, ZoneFieldLeasePreferences:
+		var s string
+		s, err = zoneStringValue(name, value)
 		if err != nil {
-			return config, err
+			return err
+		}
+		var conjunctions []ZoneConfigConjunction
+		if name == ZoneFieldLeasePreferences {
+			conjunctions, err = parseLeasePreferences(s)
+		} else {
+			conjunctions, err = parseConstraints(s)
 		}
-		config.NumReplicas = val
+		switch name {
+		case ZoneFieldConstraints:
+			zc.Constraints = conjunctions
+		case ZoneFieldVoterConstraints:
+			zc.VoterConstraints = conjunctions
+		default:
+			zc.LeasePreferences = conjunctions
+		}
+	default:
+		zc.Unknown = append(zc.Unknown, ZoneConfigField{Name: name, Value: raw})
+	}
+	if err != nil {
+		return err
 	}
+	zc.Fields = append(zc.Fields, name)
+	return nil
+}
 
-	// Constraints
-	re = regexp.MustCompile(`constraints = '([^']*)'`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		constraints, err := parseConstraints(matches[1])
-		if err != nil {
-			return config, err
-		}
-		config.Constraints = constraints
+// parseConstraints parses constraints or voter constraints in either the list form that applies to all
+// replicas, e.g., [+region=a,-zone=b], or the per-replica map form, e.g., {+region=a: 2, '+region=b,+zone=c': 1}
+func parseConstraints(input string) ([]ZoneConfigConjunction, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
 	}
 
-	// Voter Constraints
-	re = regexp.MustCompile(`voter_constraints = '([^']*)'`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		vconstraints, err := parseConstraints(matches[1])
+	switch input[0] {
+	case '[':
+		inner, err := trimBrackets(input, '[', ']')
 		if err != nil {
-			return config, err
+			return nil, err
 		}
-		config.VoterConstraints = vconstraints
-	}
-
-	// Lease preferences
-	re = regexp.MustCompile(`lease_preferences = '([^']*)'`)
-	matches = re.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		prefs, err := parseConstraints(matches[1])
+		constraints, err := parseConjunction(inner)
+		if err != nil || len(constraints) == 0 {
+			return nil, err
+		}
+		return []ZoneConfigConjunction{{Constraints: constraints}}, nil
+	case '{':
+		inner, err := trimBrackets(input, '{', '}')
 		if err != nil {
-			return config, err
+			return nil, err
+		}
+		var conjunctions []ZoneConfigConjunction
+		if strings.TrimSpace(inner) == "" {
+			return conjunctions, nil
 		}
-		config.LeasePreferences = prefs
+		for i, entry := range splitTopLevel(inner, ',') {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				return nil, errors.New(fmt.Sprintf("error processing constraints, part %d in '%s' is empty", i, input))
+			}
+			sep := lastIndexOutsideQuotes(entry, ':')
+			if sep < 0 {
+				return nil, fmt.Errorf("zone config: invalid per-replica constraint: %s", entry)
+			}
+			numReplicas, err := strconv.Atoi(strings.TrimSpace(entry[sep+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("zone config: invalid replica count in %s", entry)
+			}
+			constraints, err := parseConjunction(unquoteZoneString(strings.TrimSpace(entry[:sep])))
+			if err != nil {
+				return nil, err
+			}
+			conjunctions = append(conjunctions, ZoneConfigConjunction{Constraints: constraints, NumReplicas: numReplicas})
+		}
+		return conjunctions, nil
+	default:
+		// A bare conjunction without brackets applies to all replicas
+		constraints, err := parseConjunction(input)
+		if err != nil || len(constraints) == 0 {
+			return nil, err
+		}
+		return []ZoneConfigConjunction{{Constraints: constraints}}, nil
 	}
-
-	return config, nil
 }
 
-// parseConstraints from an input string
-func parseConstraints(input string) ([]ZoneConfigConstraint, error) {
-
-	var constraints []ZoneConfigConstraint
-	// Remove the first and last character if it is either any of []{}
-	if len(input) >= 2 && input[0] == '[' && input[len(input)-1] == ']' {
-		input = input[1 : len(input)-1]
+// parseLeasePreferences parses an ordered list of conjunctions, e.g., [[+region=a,+zone=b],[+region=c]]
+func parseLeasePreferences(input string) ([]ZoneConfigConjunction, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
 	}
-	if len(input) >= 2 && input[0] == '{' && input[len(input)-1] == '}' {
-		input = input[1 : len(input)-1]
+	inner, err := trimBrackets(input, '[', ']')
+	if err != nil {
+		return nil, err
 	}
+	var preferences []ZoneConfigConjunction
+	for _, part := range splitTopLevel(inner, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		preference, err := trimBrackets(part, '[', ']')
+		if err != nil {
+			return nil, err
+		}
+		constraints, err := parseConjunction(preference)
+		if err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, ZoneConfigConjunction{Constraints: constraints})
+	}
+	return preferences, nil
+}
 
-	if len(input) == 0 {
+// parseConjunction parses comma separated constraints such as +region=a,-zone=b or ssd
+func parseConjunction(input string) ([]ZoneConfigConstraint, error) {
+	var constraints []ZoneConfigConstraint
+	if strings.TrimSpace(input) == "" {
 		return constraints, nil
 	}
+	for i, part := range splitTopLevel(input, ',') {
+		part = unquoteZoneString(strings.TrimSpace(part))
+		if part == "" {
+			return constraints, errors.New(fmt.Sprintf("error processing constraints, part %d in '%s' is empty", i, input))
+		}
 
-	// Split the input
-	parts := strings.Split(input, ",")
-	for i, part := range parts {
-		part = strings.TrimSpace(part)
-		if len(part) >= 2 && part[0] == '[' && part[len(part)-1] == ']' {
-			part = part[1 : len(part)-1]
+		// Constraints without a prefix are required
+		constraint := ZoneConfigConstraint{Type: ZoneConfigConstraintTypeRequired}
+		switch part[0] {
+		case '+':
+			part = part[1:]
+		case '-':
+			constraint.Type = ZoneConfigConstraintTypeProhibited
+			part = part[1:]
 		}
-		part = strings.TrimSpace(part)
-		constraint := ZoneConfigConstraint{}
-		if len(part) == 0 {
-			return constraints, errors.New(fmt.Sprintf("error processing constraints, part %d in '%s' is empty", i, input))
+
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			key, value = "", key
 		}
-		re := regexp.MustCompile(`^([+-])([a-zA-Z0-9_-]+=.+?)(?::\s*(\d+))?$`)
-		matches := re.FindStringSubmatch(part)
-		if len(matches) < 3 {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" || (found && key == "") || strings.ContainsAny(value, "[]{}:") {
 			return constraints, fmt.Errorf("zone config: invalid zone config constraint: %s", part)
 		}
+		constraint.Key = key
+		constraint.Value = value
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
 
-		typeStr := matches[1]
-		if typeStr != "+" && typeStr != "-" {
-			return constraints, errors.New("zone config: unknown scope")
-		}
-		constraint.Type = ZoneConfigConstraintType(typeStr)
-		constraint.Value = matches[2]
+type zoneTokenKind int
 
-		if len(matches) == 4 && len(matches[3]) > 0 {
-			scope, err := strconv.Atoi(matches[3])
-			if err != nil {
-				return constraints, errors.New("zone config: invalid scope")
+const (
+	zoneTokenWord zoneTokenKind = iota
+	zoneTokenString
+	zoneTokenEquals
+	zoneTokenComma
+	zoneTokenOther
+)
+
+type zoneToken struct {
+	kind zoneTokenKind
+	text string
+}
+
+func (t zoneToken) isWord(word string) bool {
+	return t.kind == zoneTokenWord && strings.EqualFold(t.text, word)
+}
+
+// tokenizeZoneConfig splits zone configuration SQL into words, single-quoted strings and punctuation
+func tokenizeZoneConfig(input string) ([]zoneToken, error) {
+	var tokens []zoneToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					// Two single quotes are an escaped quote
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("zone config: unterminated string in %q", input)
+			}
+			tokens = append(tokens, zoneToken{kind: zoneTokenString, text: sb.String()})
+		case r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				i++
 			}
-			constraint.Scope = scope
+			if i >= len(runes) {
+				return nil, fmt.Errorf("zone config: unterminated identifier in %q", input)
+			}
+			i++
+			tokens = append(tokens, zoneToken{kind: zoneTokenWord, text: string(runes[start:i])})
+		case r == '=':
+			tokens = append(tokens, zoneToken{kind: zoneTokenEquals, text: "="})
+			i++
+		case r == ',':
+			tokens = append(tokens, zoneToken{kind: zoneTokenComma, text: ","})
+			i++
+		case isZoneWordRune(r):
+			start := i
+			for i < len(runes) && isZoneWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, zoneToken{kind: zoneTokenWord, text: string(runes[start:i])})
+		default:
+			tokens = append(tokens, zoneToken{kind: zoneTokenOther, text: string(r)})
+			i++
 		}
+	}
+	return tokens, nil
+}
 
-		constraints = append(constraints, constraint)
+func isZoneWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-' || r == '@'
+}
 
+// joinZoneTokens renders value tokens back to SQL
+func joinZoneTokens(tokens []zoneToken) string {
+	var parts []string
+	for _, t := range tokens {
+		if t.kind == zoneTokenString {
+			parts = append(parts, quoteZoneString(t.text))
+		} else {
+			parts = append(parts, t.text)
+		}
 	}
+	return strings.Join(parts, " ")
+}
 
-	return constraints, nil
+func zoneIntValue(name string, value []zoneToken) (int, error) {
+	if len(value) != 1 || value[0].kind != zoneTokenWord {
+		return 0, fmt.Errorf("zone config: invalid value for %s: %s", name, joinZoneTokens(value))
+	}
+	val, err := strconv.Atoi(value[0].text)
+	if err != nil {
+		return 0, fmt.Errorf("zone config: invalid value for %s: %w", name, err)
+	}
+	return val, nil
+}
+
+func zoneBoolValue(name string, value []zoneToken) (bool, error) {
+	if len(value) != 1 || value[0].kind != zoneTokenWord {
+		return false, fmt.Errorf("zone config: invalid value for %s: %s", name, joinZoneTokens(value))
+	}
+	val, err := strconv.ParseBool(value[0].text)
+	if err != nil {
+		return false, fmt.Errorf("zone config: invalid value for %s: %w", name, err)
+	}
+	return val, nil
+}
+
+func zoneStringValue(name string, value []zoneToken) (string, error) {
+	if len(value) != 1 || value[0].kind != zoneTokenString {
+		return "", fmt.Errorf("zone config: invalid value for %s: %s", name, joinZoneTokens(value))
+	}
+	return value[0].text, nil
+}
+
+// quoteZoneString quotes a SQL string literal
+func quoteZoneString(s string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", "''"))
+}
+
+// unquoteZoneString removes single or double quotes around a constraint or map key
+func unquoteZoneString(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// trimBrackets removes the surrounding open and close characters
+func trimBrackets(s string, open byte, close byte) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != open || s[len(s)-1] != close {
+		return "", fmt.Errorf("zone config: expected %c...%c: %s", open, close, s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// splitTopLevel splits on sep when not inside brackets, braces or quotes
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth := 0
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// lastIndexOutsideQuotes returns the index of the last c that is not inside quotes
+func lastIndexOutsideQuotes(s string, c rune) int {
+	index := -1
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == c:
+			index = i
+		}
+	}
+	return index
 }
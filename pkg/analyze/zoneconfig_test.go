@@ -1,11 +1,32 @@
 package analyze
 
 import (
+	"github.com/jonstjohn/crdb-schema-analyzer/pkg/db"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
-var configsTest = []string{`
+func required(key string, value string) ZoneConfigConstraint {
+	return ZoneConfigConstraint{Type: ZoneConfigConstraintTypeRequired, Key: key, Value: value}
+}
+
+func prohibited(key string, value string) ZoneConfigConstraint {
+	return ZoneConfigConstraint{Type: ZoneConfigConstraintTypeProhibited, Key: key, Value: value}
+}
+
+func conjunction(numReplicas int, constraints ...ZoneConfigConstraint) ZoneConfigConjunction {
+	return ZoneConfigConjunction{Constraints: constraints, NumReplicas: numReplicas}
+}
+
+var configsTest = []struct {
+	name     string
+	input    string
+	expected ZoneConfig
+}{
+	{
+		name: "full statement",
+		input: `
 	ALTER TABLE t CONFIGURE ZONE USING
       range_min_bytes = 134217728,
       range_max_bytes = 536870912,
@@ -15,68 +36,152 @@ var configsTest = []string{`
       constraints = '{+region=us-east1: 3}',
       voter_constraints = '[+region=us-east1]',
       lease_preferences = '[[+region=us-east1],[+region=us-west1]]'
-`}
-
-var configsExpected = []ZoneConfig{
+`,
+		expected: ZoneConfig{
+			RangeMinBytes:    134217728,
+			RangeMaxBytes:    536870912,
+			GcTtlSeconds:     14400,
+			NumReplicas:      5,
+			NumVoters:        3,
+			Constraints:      []ZoneConfigConjunction{conjunction(3, required("region", "us-east1"))},
+			VoterConstraints: []ZoneConfigConjunction{conjunction(0, required("region", "us-east1"))},
+			LeasePreferences: []ZoneConfigConjunction{
+				conjunction(0, required("region", "us-east1")),
+				conjunction(0, required("region", "us-west1")),
+			},
+			Fields: []string{"range_min_bytes", "range_max_bytes", "gc.ttlseconds", "num_replicas", "num_voters",
+				"constraints", "voter_constraints", "lease_preferences"},
+		},
+	},
 	{
-		RangeMinBytes: 134217728,
-		RangeMaxBytes: 536870912,
-		GcTtlSeconds:  14400,
-		NumReplicas:   5,
-		NumVoters:     3,
-		Constraints: []ZoneConfigConstraint{
-			{Value: "region=us-east1", Type: ZoneConfigConstraintTypeRequired, Scope: 3},
+		name:  "list conjunction",
+		input: `constraints = '[+region=a,+zone=b]'`,
+		expected: ZoneConfig{
+			Constraints: []ZoneConfigConjunction{conjunction(0, required("region", "a"), required("zone", "b"))},
+			Fields:      []string{"constraints"},
 		},
-		VoterConstraints: []ZoneConfigConstraint{
-			{Value: "region=us-east1", Type: ZoneConfigConstraintTypeRequired, Scope: 0},
+	},
+	{
+		name:  "prohibited and attribute constraints",
+		input: `constraints = '[-region=a, ssd]'`,
+		expected: ZoneConfig{
+			Constraints: []ZoneConfigConjunction{conjunction(0, prohibited("region", "a"), required("", "ssd"))},
+			Fields:      []string{"constraints"},
+		},
+	},
+	{
+		name:  "per-replica map with quoted keys",
+		input: `constraints = '{''+region=a'': 1, ''+region=b,+zone=c'': 2}'`,
+		expected: ZoneConfig{
+			Constraints: []ZoneConfigConjunction{
+				conjunction(1, required("region", "a")),
+				conjunction(2, required("region", "b"), required("zone", "c")),
+			},
+			Fields: []string{"constraints"},
+		},
+	},
+	{
+		name:  "voter map with double quoted keys",
+		input: `num_voters = 5, voter_constraints = '{"+region=a,+zone=a1": 2, +region=b: 2}'`,
+		expected: ZoneConfig{
+			NumVoters: 5,
+			VoterConstraints: []ZoneConfigConjunction{
+				conjunction(2, required("region", "a"), required("zone", "a1")),
+				conjunction(2, required("region", "b")),
+			},
+			Fields: []string{"num_voters", "voter_constraints"},
 		},
-		LeasePreferences: []ZoneConfigConstraint{
-			{Value: "region=us-east1", Type: ZoneConfigConstraintTypeRequired, Scope: 0},
-			{Value: "region=us-west1", Type: ZoneConfigConstraintTypeRequired, Scope: 0},
+	},
+	{
+		name:  "lease preference conjunctions",
+		input: `lease_preferences = '[[+region=a,+zone=a1], [+region=b]]'`,
+		expected: ZoneConfig{
+			LeasePreferences: []ZoneConfigConjunction{
+				conjunction(0, required("region", "a"), required("zone", "a1")),
+				conjunction(0, required("region", "b")),
+			},
+			Fields: []string{"lease_preferences"},
+		},
+	},
+	{
+		name:  "empty constraints",
+		input: `constraints = '[]', voter_constraints = '{}', lease_preferences = '[]'`,
+		expected: ZoneConfig{
+			Fields: []string{"constraints", "voter_constraints", "lease_preferences"},
+		},
+	},
+	{
+		name:  "booleans",
+		input: `ALTER DATABASE d CONFIGURE ZONE USING global_reads = true, exclude_data_from_backup = false`,
+		expected: ZoneConfig{
+			GlobalReads: true,
+			Fields:      []string{"global_reads", "exclude_data_from_backup"},
+		},
+	},
+	{
+		name:  "copy from parent",
+		input: `ALTER PARTITION "us-east1" OF INDEX d.public.t@t_pkey CONFIGURE ZONE USING num_replicas = COPY FROM PARENT, num_voters = 3`,
+		expected: ZoneConfig{
+			NumVoters: 3,
+			Fields:    []string{"num_voters"},
+		},
+	},
+	{
+		name:  "unknown fields",
+		input: `num_replicas = 3, future_setting = 'a,b', other.setting = 10`,
+		expected: ZoneConfig{
+			NumReplicas: 3,
+			Unknown: []ZoneConfigField{
+				{Name: "future_setting", Value: "'a,b'"},
+				{Name: "other.setting", Value: "10"},
+			},
+			Fields: []string{"num_replicas", "future_setting", "other.setting"},
 		},
 	},
 }
 
 func TestZoneConfigParser(t *testing.T) {
-	for i, test := range configsTest {
-		config, err := parseZoneConfig(test)
-		assert.NoError(t, err)
-		assert.Equal(t, configsExpected[i].RangeMinBytes, config.RangeMinBytes)
-		assert.Equal(t, configsExpected[i].RangeMaxBytes, config.RangeMaxBytes)
-		assert.Equal(t, configsExpected[i].GcTtlSeconds, config.GcTtlSeconds)
-		assert.Equal(t, configsExpected[i].NumReplicas, config.NumReplicas)
-		assert.Equal(t, configsExpected[i].NumVoters, config.NumVoters)
-		assert.Equal(t, configsExpected[i].Constraints, config.Constraints)
-		assert.Equal(t, configsExpected[i].VoterConstraints, config.VoterConstraints)
-		assert.Equal(t, configsExpected[i].LeasePreferences, config.LeasePreferences)
-
-		/*
-			// LeasePreferences
-			constraints, err := parseConstraints(config.Constraints)
-
-			assert.NoError(t, err)
-			assert.Equal(t, 1, len(constraints))
-
-			assert.Equal(t, ZoneConfigConstraintTypeRequired, constraints[0].Type)
-			assert.Equal(t, "region=us-east1", constraints[0].Value)
-			assert.Equal(t, 3, constraints[0].Scope)
-			assert.Equal(t, 1, cons)
-
-			// Voter constraints
-			vconstraints, err := parseConstraints(config.VoterConstraints)
-			assert.NoError(t, err)
-			assert.Equal(t, 1, len(vconstraints))
-			assert.Equal(t, ZoneConfigConstraintTypeRequired, vconstraints[0].Type)
-			assert.Equal(t, "region=us-east1", vconstraints[0].Value)
-			assert.Equal(t, 0, vconstraints[0].Scope)
+	for _, test := range configsTest {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := parseZoneConfig(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, config)
+		})
+	}
+}
 
-			// Lease preferences
-			leaseprefs, err := parseConstraints(config.LeasePreferences)
-			assert.NoError(t, err)
-			assert.Equal(t, 2, len(leaseprefs))
-			assert.Equal(t, ZoneConfigConstraintTypeRequired, leaseprefs[0].Type)
-			assert.Equal(t, "region=us-east1", leaseprefs[0].Value)
-			assert.Equal(t, 0, leaseprefs[0].Scope)
-		*/
+func TestZoneConfigParserErrors(t *testing.T) {
+	inputs := []string{
+		`num_replicas = 'three'`,
+		`num_replicas = `,
+		`constraints = '[+region=a`,
+		`constraints = '{+region=a}'`,
+		`constraints = '{+region=a: x}'`,
+		`constraints = '[+region=a,,+zone=b]'`,
+		`constraints = '[+=a]'`,
+		`lease_preferences = '[+region=a]'`,
+		`global_reads = maybe`,
+		`num_replicas 3`,
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			_, err := parseZoneConfig(input)
+			assert.Error(t, err)
+		})
 	}
 }
+
+func TestZoneConfigsInDatabase(t *testing.T) {
+	zones, err := zoneConfigsInDatabase("d", []db.ZoneConfigRow{
+		{Target: "RANGE default", RawConfigSql: "ALTER RANGE default CONFIGURE ZONE USING num_replicas = 3"},
+		{Target: "SCHEMA x.public", RawConfigSql: "ALTER SCHEMA x.public CONFIGURE ZONE USING num_replicas = 3"},
+		{Target: "TABLE d.t", RawConfigSql: "ALTER TABLE d.t CONFIGURE ZONE USING gc.ttlseconds = 600"},
+		{Target: "TABLE e.public.t", RawConfigSql: "ALTER TABLE e.public.t CONFIGURE ZONE USING gc.ttlseconds = 600"},
+		{Target: "DATABASE d", RawConfigSql: "ALTER DATABASE d CONFIGURE ZONE USING num_replicas = 5"},
+	})
+	require.NoError(t, err)
+	require.Len(t, zones, 2)
+	assert.Equal(t, "TABLE d.t", zones[0].Target)
+	assert.Equal(t, 600, zones[0].GcTtlSeconds)
+	assert.Equal(t, "DATABASE d", zones[1].Target)
+}
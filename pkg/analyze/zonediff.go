@@ -0,0 +1,180 @@
+package analyze
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// zoneFieldOrder is the canonical order fields are rendered in
+var zoneFieldOrder = []string{
+	ZoneFieldRangeMinBytes,
+	ZoneFieldRangeMaxBytes,
+	ZoneFieldGcTtlSeconds,
+	ZoneFieldGlobalReads,
+	ZoneFieldNumReplicas,
+	ZoneFieldNumVoters,
+	ZoneFieldConstraints,
+	ZoneFieldVoterConstraints,
+	ZoneFieldLeasePreferences,
+	ZoneFieldExcludeDataFromBackup,
+}
+
+// Sql renders the zone configuration as a canonical ALTER ... CONFIGURE ZONE USING statement.
+// Only fields that are set are included, known fields first in canonical order followed by unknown fields.
+func (zc ZoneConfig) Sql() string {
+	return zoneConfigureSql(zc.Target, zc.assignments())
+}
+
+// FieldValue renders the SQL value of a field
+func (zc ZoneConfig) FieldValue(field string) string {
+	switch field {
+	case ZoneFieldRangeMinBytes:
+		return strconv.Itoa(zc.RangeMinBytes)
+	case ZoneFieldRangeMaxBytes:
+		return strconv.Itoa(zc.RangeMaxBytes)
+	case ZoneFieldGcTtlSeconds:
+		return strconv.Itoa(zc.GcTtlSeconds)
+	case ZoneFieldGlobalReads:
+		return strconv.FormatBool(zc.GlobalReads)
+	case ZoneFieldNumReplicas:
+		return strconv.Itoa(zc.NumReplicas)
+	case ZoneFieldNumVoters:
+		return strconv.Itoa(zc.NumVoters)
+	case ZoneFieldConstraints:
+		return quoteZoneString(renderConstraints(zc.Constraints))
+	case ZoneFieldVoterConstraints:
+		return quoteZoneString(renderConstraints(zc.VoterConstraints))
+	case ZoneFieldLeasePreferences:
+		return quoteZoneString(renderLeasePreferences(zc.LeasePreferences))
+	case ZoneFieldExcludeDataFromBackup:
+		return strconv.FormatBool(zc.ExcludeDataFromBackup)
+	default:
+		for _, unknown := range zc.Unknown {
+			if unknown.Name == field {
+				return unknown.Value
+			}
+		}
+		return ""
+	}
+}
+
+// Set marks a field as set so it is rendered
+func (zc *ZoneConfig) Set(field string) {
+	if !zc.Has(field) {
+		zc.Fields = append(zc.Fields, field)
+	}
+}
+
+// Clone returns a copy of the zone configuration that shares no slices with the original
+func (zc ZoneConfig) Clone() ZoneConfig {
+	clone := zc
+	clone.Constraints = cloneConjunctions(zc.Constraints)
+	clone.VoterConstraints = cloneConjunctions(zc.VoterConstraints)
+	clone.LeasePreferences = cloneConjunctions(zc.LeasePreferences)
+	clone.Unknown = slices.Clone(zc.Unknown)
+	clone.Fields = slices.Clone(zc.Fields)
+	return clone
+}
+
+// ZoneConfigDiffSql returns the smallest statement that moves the target of to from the from configuration
+// to the to configuration. Fields that are set in from but not in to are reset with COPY FROM PARENT.
+// The boolean is false when the configurations are the same and no statement is needed.
+func ZoneConfigDiffSql(from ZoneConfig, to ZoneConfig) (string, bool) {
+	var assignments []string
+	for _, field := range to.orderedFields() {
+		value := to.FieldValue(field)
+		if !from.Has(field) || from.FieldValue(field) != value {
+			assignments = append(assignments, fmt.Sprintf("%s = %s", field, value))
+		}
+	}
+	for _, field := range from.orderedFields() {
+		if !to.Has(field) {
+			assignments = append(assignments, fmt.Sprintf("%s = %s", field, zoneCopyFromParent))
+		}
+	}
+	if len(assignments) == 0 {
+		return "", false
+	}
+	return zoneConfigureSql(to.Target, assignments), true
+}
+
+// assignments renders each set field as field = value, in the order of orderedFields
+func (zc ZoneConfig) assignments() []string {
+	var assignments []string
+	for _, field := range zc.orderedFields() {
+		assignments = append(assignments, fmt.Sprintf("%s = %s", field, zc.FieldValue(field)))
+	}
+	return assignments
+}
+
+// orderedFields returns the set fields, known fields in canonical order followed by unknown fields
+func (zc ZoneConfig) orderedFields() []string {
+	var fields []string
+	for _, field := range zoneFieldOrder {
+		if zc.Has(field) {
+			fields = append(fields, field)
+		}
+	}
+	for _, field := range zc.Fields {
+		if !slices.Contains(zoneFieldOrder, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func zoneConfigureSql(target string, assignments []string) string {
+	return fmt.Sprintf("ALTER %s CONFIGURE ZONE USING %s", target, strings.Join(assignments, ", "))
+}
+
+// renderConstraints renders constraints in the list form when a single conjunction applies to all replicas,
+// otherwise in the per-replica map form
+func renderConstraints(conjunctions []ZoneConfigConjunction) string {
+	if len(conjunctions) == 0 {
+		return "[]"
+	}
+	if len(conjunctions) == 1 && conjunctions[0].NumReplicas == 0 {
+		return fmt.Sprintf("[%s]", renderConjunction(conjunctions[0]))
+	}
+	var entries []string
+	for _, conjunction := range conjunctions {
+		key := renderConjunction(conjunction)
+		if len(conjunction.Constraints) > 1 {
+			key = fmt.Sprintf(`"%s"`, key)
+		}
+		entries = append(entries, fmt.Sprintf("%s: %d", key, conjunction.NumReplicas))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(entries, ", "))
+}
+
+func renderLeasePreferences(preferences []ZoneConfigConjunction) string {
+	var parts []string
+	for _, preference := range preferences {
+		parts = append(parts, fmt.Sprintf("[%s]", renderConjunction(preference)))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+}
+
+func renderConjunction(conjunction ZoneConfigConjunction) string {
+	var parts []string
+	for _, constraint := range conjunction.Constraints {
+		parts = append(parts, constraint.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func cloneConjunctions(conjunctions []ZoneConfigConjunction) []ZoneConfigConjunction {
+	if conjunctions == nil {
+		return nil
+	}
+	clone := make([]ZoneConfigConjunction, len(conjunctions))
+	for i, conjunction := range conjunctions {
+		clone[i] = ZoneConfigConjunction{
+			Constraints: slices.Clone(conjunction.Constraints),
+			NumReplicas: conjunction.NumReplicas,
+		}
+	}
+	return clone
+}
@@ -0,0 +1,76 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestZoneConfigSql(t *testing.T) {
+	config, err := parseZoneConfig(`lease_preferences = '[[+region=a,+zone=a1],[+region=b]]', num_voters = 5,
+		future_setting = 'x', voter_constraints = '{+region=a: 2, ''+region=b,+zone=b1'': 2}', global_reads = true`)
+	require.NoError(t, err)
+	config.Target = "TABLE d.public.t"
+
+	assert.Equal(t, `ALTER TABLE d.public.t CONFIGURE ZONE USING global_reads = true, num_voters = 5, `+
+		`voter_constraints = '{+region=a: 2, "+region=b,+zone=b1": 2}', `+
+		`lease_preferences = '[[+region=a,+zone=a1], [+region=b]]', future_setting = 'x'`, config.Sql())
+}
+
+func TestZoneConfigSqlRoundTrip(t *testing.T) {
+	for _, test := range configsTest {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := parseZoneConfig(test.input)
+			require.NoError(t, err)
+			config.Target = "TABLE t"
+
+			reparsed, err := parseZoneConfig(config.Sql())
+			require.NoError(t, err)
+			reparsed.Target = config.Target
+			assert.Equal(t, config.Sql(), reparsed.Sql())
+			assert.ElementsMatch(t, config.Fields, reparsed.Fields)
+		})
+	}
+}
+
+func TestZoneConfigDiffSql(t *testing.T) {
+	from, err := parseZoneConfig(`gc.ttlseconds = 14400, num_replicas = 5, num_voters = 3,
+		constraints = '{+region=a: 1, +region=b: 1}', lease_preferences = '[[+region=a]]'`)
+	require.NoError(t, err)
+	from.Target = "TABLE d.public.t"
+
+	_, changed := ZoneConfigDiffSql(from, from.Clone())
+	assert.False(t, changed)
+
+	to := from.Clone()
+	to.NumReplicas = 3
+	to.LeasePreferences = []ZoneConfigConjunction{conjunction(0, required("region", "b"))}
+	to.Fields = []string{"gc.ttlseconds", "num_replicas", "num_voters", "lease_preferences", "global_reads"}
+	to.GlobalReads = true
+
+	sql, changed := ZoneConfigDiffSql(from, to)
+	assert.True(t, changed)
+	assert.Equal(t, `ALTER TABLE d.public.t CONFIGURE ZONE USING global_reads = true, num_replicas = 3, `+
+		`lease_preferences = '[[+region=b]]', constraints = COPY FROM PARENT`, sql)
+
+	// The original is left untouched by changes to the clone
+	assert.Equal(t, 5, from.NumReplicas)
+	assert.Equal(t, "a", from.LeasePreferences[0].Constraints[0].Value)
+}
+
+func TestPrimaryRegionZoneConfig(t *testing.T) {
+	zc, err := parseZoneConfig(`range_max_bytes = 536870912, num_replicas = 5, num_voters = 3,
+		constraints = '{+region=us-east1: 1, +region=us-west1: 1}', voter_constraints = '[+region=us-west1]',
+		lease_preferences = '[[+region=us-west1]]'`)
+	require.NoError(t, err)
+	zc.Target = "TABLE d.public.t"
+
+	sql, changed := ZoneConfigDiffSql(zc, primaryRegionZoneConfig(zc, "us-east1"))
+	assert.True(t, changed)
+	assert.Equal(t, `ALTER TABLE d.public.t CONFIGURE ZONE USING num_replicas = 3, constraints = '[+region=us-east1]', `+
+		`voter_constraints = '[+region=us-east1]', lease_preferences = '[[+region=us-east1]]'`, sql)
+
+	converted := primaryRegionZoneConfig(zc, "us-east1")
+	_, changed = ZoneConfigDiffSql(converted, primaryRegionZoneConfig(converted, "us-east1"))
+	assert.False(t, changed)
+}
@@ -0,0 +1,163 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ZoneConfigExportVersion is the version of the export file format. It is bumped whenever the format
+// changes in a way older versions of the tool cannot read.
+const ZoneConfigExportVersion = 1
+
+type ZoneConfigExportFormat string
+
+const (
+	ZoneConfigExportFormatJson ZoneConfigExportFormat = "json"
+	ZoneConfigExportFormatYaml ZoneConfigExportFormat = "yaml"
+)
+
+// ZoneConfigExport is a snapshot of every zone configuration in a database
+type ZoneConfigExport struct {
+	Version   int          `json:"version" yaml:"version"`
+	Database  string       `json:"database" yaml:"database"`
+	CreatedAt time.Time    `json:"created_at" yaml:"created_at"`
+	Zones     []ZoneConfig `json:"zones" yaml:"zones"`
+}
+
+// ExportZoneConfigs takes a snapshot of all zone configurations for the database
+func (a *Analyzer) ExportZoneConfigs() (ZoneConfigExport, error) {
+	zones, err := a.AllZoneConfigurations()
+	if err != nil {
+		return ZoneConfigExport{}, err
+	}
+	return ZoneConfigExport{
+		Version:   ZoneConfigExportVersion,
+		Database:  a.Config.Database,
+		CreatedAt: time.Now().UTC(),
+		Zones:     zones,
+	}, nil
+}
+
+// ZoneConfigExportFormatFromPath determines the export format from the file extension
+func ZoneConfigExportFormatFromPath(path string) (ZoneConfigExportFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ZoneConfigExportFormatJson, nil
+	case ".yaml", ".yml":
+		return ZoneConfigExportFormatYaml, nil
+	default:
+		return "", fmt.Errorf("cannot determine export format from %q, use a .json, .yaml or .yml extension", path)
+	}
+}
+
+// Marshal encodes the export in the format
+func (e ZoneConfigExport) Marshal(format ZoneConfigExportFormat) ([]byte, error) {
+	switch format {
+	case ZoneConfigExportFormatJson:
+		return json.MarshalIndent(e, "", "  ")
+	case ZoneConfigExportFormatYaml:
+		return yaml.Marshal(e)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// WriteZoneConfigExport writes the export to a file. When format is empty, it is determined from the
+// file extension.
+func WriteZoneConfigExport(export ZoneConfigExport, path string, format ZoneConfigExportFormat) error {
+	if format == "" {
+		var err error
+		format, err = ZoneConfigExportFormatFromPath(path)
+		if err != nil {
+			return err
+		}
+	}
+	data, err := export.Marshal(format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadZoneConfigExport reads an export written by WriteZoneConfigExport. JSON is a subset of YAML,
+// so both formats are read with the YAML decoder.
+func ReadZoneConfigExport(path string) (ZoneConfigExport, error) {
+	var export ZoneConfigExport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return export, err
+	}
+	if err := yaml.Unmarshal(data, &export); err != nil {
+		return export, fmt.Errorf("zone config export %s: %w", path, err)
+	}
+	if export.Version != ZoneConfigExportVersion {
+		return export, fmt.Errorf("zone config export %s: unsupported version %d, expected %d",
+			path, export.Version, ZoneConfigExportVersion)
+	}
+	return export, nil
+}
+
+// ZoneRestorePlan generates the plan that brings the zone configurations of the database back to the state
+// in the export. Targets that changed are altered with only the fields that differ, targets missing from the
+// cluster are recreated and targets that were not in the export are discarded.
+func (c *Converter) ZoneRestorePlan(export ZoneConfigExport) (*Plan, error) {
+	if export.Database != c.Config.Database {
+		return nil, fmt.Errorf("export is for database %s, not %s", export.Database, c.Config.Database)
+	}
+
+	current, err := c.Analyzer.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	return zoneRestorePlan(c.Config.Database, current, export.Zones), nil
+}
+
+func zoneRestorePlan(database string, current []ZoneConfig, snapshot []ZoneConfig) *Plan {
+	plan := NewPlan("zone-restore", database)
+
+	currentByTarget := make(map[string]ZoneConfig)
+	for _, zc := range current {
+		currentByTarget[zc.Target] = zc
+	}
+	snapshotTargets := make(map[string]bool)
+
+	phase := plan.AddPhase("zone_restore")
+	for _, zc := range snapshot {
+		snapshotTargets[zc.Target] = true
+		if existing, ok := currentByTarget[zc.Target]; ok {
+			if sql, changed := ZoneConfigDiffSql(existing, zc); changed {
+				phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "changed since the export",
+					Risk: PlanRiskMedium, Idempotent: true})
+			}
+			continue
+		}
+		// A target without fields inherits everything from its parent, which is the same as having no override
+		if len(zc.Fields) == 0 {
+			continue
+		}
+		// Partitions only exist while the table is partitioned, such as when it is regional by row
+		var comments []string
+		if target, err := zc.ParsedTarget(); err == nil && target.Type == ZoneTargetTypePartition {
+			comments = append(comments,
+				fmt.Sprintf("WARNING: %s must exist before its zone configuration can be restored", zc.Target))
+		}
+		phase.AddBlock(comments, Statement{Sql: zc.Sql(), Target: zc.Target, Reason: "missing since the export",
+			Risk: PlanRiskMedium, Idempotent: true})
+	}
+
+	// Overrides added since the export are removed so the targets inherit from their parent again
+	for _, zc := range current {
+		if !snapshotTargets[zc.Target] {
+			sql := fmt.Sprintf("ALTER %s CONFIGURE ZONE DISCARD", zc.Target)
+			phase.AddBlock(nil, Statement{Sql: sql, Target: zc.Target, Reason: "added since the export",
+				Risk: PlanRiskMedium, Idempotent: true})
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,80 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testZoneConfig(t *testing.T, target string, input string) ZoneConfig {
+	zc, err := parseZoneConfig(input)
+	require.NoError(t, err)
+	zc.Target = target
+	zc.RawSql = input
+	return zc
+}
+
+func TestZoneConfigExportRoundTrip(t *testing.T) {
+	export := ZoneConfigExport{
+		Version:   ZoneConfigExportVersion,
+		Database:  "d",
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Zones: []ZoneConfig{
+			testZoneConfig(t, "DATABASE d", `num_replicas = 5, num_voters = 3, constraints = '{+region=a: 1, +region=b: 1}'`),
+			testZoneConfig(t, "TABLE d.public.t", `lease_preferences = '[[+region=a,+zone=a1]]', future_setting = 'x'`),
+		},
+	}
+
+	for _, file := range []string{"zones.json", "zones.yaml"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			require.NoError(t, WriteZoneConfigExport(export, path, ""))
+
+			read, err := ReadZoneConfigExport(path)
+			require.NoError(t, err)
+			assert.Equal(t, export, read)
+		})
+	}
+
+	export.Version = ZoneConfigExportVersion + 1
+	path := filepath.Join(t.TempDir(), "zones.json")
+	require.NoError(t, WriteZoneConfigExport(export, path, ""))
+	_, err := ReadZoneConfigExport(path)
+	assert.Error(t, err)
+
+	assert.Error(t, WriteZoneConfigExport(export, filepath.Join(t.TempDir(), "zones.txt"), ""))
+}
+
+func TestZoneRestorePlan(t *testing.T) {
+	snapshot := []ZoneConfig{
+		testZoneConfig(t, "TABLE d.public.t", `num_replicas = 5, lease_preferences = '[[+region=a]]'`),
+		testZoneConfig(t, "TABLE d.public.u", `gc.ttlseconds = 600`),
+		testZoneConfig(t, `PARTITION "a" OF INDEX d.public.t@t_pkey`, `num_voters = 3`),
+		testZoneConfig(t, "TABLE d.public.same", `num_replicas = 3`),
+	}
+	current := []ZoneConfig{
+		testZoneConfig(t, "TABLE d.public.t", `num_replicas = 3, lease_preferences = '[[+region=a]]'`),
+		testZoneConfig(t, "TABLE d.public.same", `num_replicas = 3`),
+		testZoneConfig(t, "TABLE d.public.new", `num_replicas = 3`),
+	}
+
+	assert.Equal(t, []string{
+		"-- FILE START zone_restore.sql",
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.t CONFIGURE ZONE USING num_replicas = 5;",
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.u CONFIGURE ZONE USING gc.ttlseconds = 600;",
+		ParallelSqlBlockEnd,
+		`-- WARNING: PARTITION "a" OF INDEX d.public.t@t_pkey must exist before its zone configuration can be restored`,
+		ParallelSqlBlockBegin,
+		`ALTER PARTITION "a" OF INDEX d.public.t@t_pkey CONFIGURE ZONE USING num_voters = 3;`,
+		ParallelSqlBlockEnd,
+		ParallelSqlBlockBegin,
+		"ALTER TABLE d.public.new CONFIGURE ZONE DISCARD;",
+		ParallelSqlBlockEnd,
+		"-- FILE END",
+	}, zoneRestorePlan("d", current, snapshot).Lines())
+}
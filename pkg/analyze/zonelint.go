@@ -0,0 +1,202 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ZoneLintSeverity string
+
+const (
+	ZoneLintSeverityError   ZoneLintSeverity = "ERROR"
+	ZoneLintSeverityWarning ZoneLintSeverity = "WARNING"
+)
+
+type ZoneLintRule string
+
+const (
+	ZoneLintRuleSurvivalVoters      ZoneLintRule = "survival-voters"
+	ZoneLintRuleLeaseRegion         ZoneLintRule = "lease-region"
+	ZoneLintRuleUnknownRegion       ZoneLintRule = "unknown-region"
+	ZoneLintRuleDivergesVoters      ZoneLintRule = "diverges-num-voters"
+	ZoneLintRuleDivergesReplicas    ZoneLintRule = "diverges-num-replicas"
+	ZoneLintRuleDivergesVoterRegion ZoneLintRule = "diverges-voter-region"
+)
+
+// ZoneLintFinding is a problem found in a zone configuration
+type ZoneLintFinding struct {
+	Target   string
+	Severity ZoneLintSeverity
+	Rule     ZoneLintRule
+	Message  string
+}
+
+// zoneExpectation is what the multi-region abstractions would set for a target
+type zoneExpectation struct {
+	HomeRegion  string
+	NumVoters   int
+	NumReplicas int
+}
+
+const numVotersForZoneSurvival = 3
+const numVotersForRegionSurvival = 5
+
+func (f ZoneLintFinding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", f.Severity, f.Rule, f.Target, f.Message)
+}
+
+// LintZoneConfigs checks each zone configuration in the database against the survival goal, the database
+// regions and the locality of the table it applies to
+func (a *Analyzer) LintZoneConfigs() ([]ZoneLintFinding, error) {
+	regions, err := a.DatabaseRegions()
+	if err != nil {
+		return nil, err
+	}
+	tables, err := a.Tables(false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := a.AllZoneConfigurations()
+	if err != nil {
+		return nil, err
+	}
+	return lintZoneConfigs(regions, tables, zones), nil
+}
+
+func lintZoneConfigs(regions DatabaseRegions, tables []Table, zones []ZoneConfig) []ZoneLintFinding {
+	var findings []ZoneLintFinding
+
+	// Single region databases have no multi-region abstractions to compare against
+	if !regions.IsMultiRegion() {
+		return findings
+	}
+
+	localities := make(map[string]Locality)
+	for _, t := range tables {
+		localities[t.Name] = t.Locality
+	}
+
+	for _, zc := range zones {
+		target, err := zc.ParsedTarget()
+		if err != nil {
+			continue
+		}
+		add := func(severity ZoneLintSeverity, rule ZoneLintRule, format string, args ...any) {
+			findings = append(findings, ZoneLintFinding{
+				Target:   zc.Target,
+				Severity: severity,
+				Rule:     rule,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		expected := expectedZoneConfig(regions, target, localities)
+
+		// Voters must tolerate the failures required by the survival goal.
+		// When num_voters is not set, all replicas are voters.
+		voters := zc.NumVoters
+		if voters == 0 {
+			voters = zc.NumReplicas
+		}
+		requiredVoters := numVotersForZoneSurvival
+		if regions.SurvivalGoal == SurvivalGoalRegion {
+			requiredVoters = numVotersForRegionSurvival
+		}
+		if voters > 0 && voters < requiredVoters {
+			add(ZoneLintSeverityError, ZoneLintRuleSurvivalVoters,
+				"%d voters cannot survive a %s failure, %d are required", voters, regions.SurvivalGoal, requiredVoters)
+		}
+
+		// Every region referenced must be a database region
+		for _, region := range zoneConfigRegions(zc) {
+			if !regions.HasRegion(region) {
+				add(ZoneLintSeverityError, ZoneLintRuleUnknownRegion,
+					"region %s is not a region of database %s", region, regions.Database)
+			}
+		}
+
+		// The first lease preference should be the home region
+		if expected.HomeRegion != "" {
+			if region, ok := zc.LeaseRegion(); ok && region != expected.HomeRegion {
+				add(ZoneLintSeverityError, ZoneLintRuleLeaseRegion,
+					"leaseholders prefer %s but the home region is %s", region, expected.HomeRegion)
+			}
+		}
+
+		// Overrides that diverge from what the multi-region abstractions would set
+		if zc.NumVoters > 0 && zc.NumVoters != expected.NumVoters {
+			add(ZoneLintSeverityWarning, ZoneLintRuleDivergesVoters,
+				"num_voters = %d but the multi-region default is %d", zc.NumVoters, expected.NumVoters)
+		}
+		if zc.NumReplicas > 0 && zc.NumReplicas != expected.NumReplicas {
+			add(ZoneLintSeverityWarning, ZoneLintRuleDivergesReplicas,
+				"num_replicas = %d but the multi-region default is %d", zc.NumReplicas, expected.NumReplicas)
+		}
+		if expected.HomeRegion != "" {
+			for _, conjunction := range zc.VoterConstraints {
+				for _, region := range conjunction.Regions() {
+					if region != expected.HomeRegion {
+						add(ZoneLintSeverityWarning, ZoneLintRuleDivergesVoterRegion,
+							"voters are constrained to %s but the home region is %s", region, expected.HomeRegion)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Target < findings[j].Target
+	})
+	return findings
+}
+
+// expectedZoneConfig determines the home region and replica counts the multi-region abstractions would use
+// for the target, mirroring how CockroachDB derives them from the survival goal and placement policy
+func expectedZoneConfig(regions DatabaseRegions, target ZoneTarget, localities map[string]Locality) zoneExpectation {
+	locality := localities[target.Table]
+
+	expected := zoneExpectation{HomeRegion: regions.PrimaryRegion}
+	switch {
+	case target.Type == ZoneTargetTypeDatabase:
+	case locality.Type == LocalityTypeRegionalByTable:
+		expected.HomeRegion = locality.HomeRegion(regions.PrimaryRegion)
+	case locality.Type == LocalityTypeRegionalByRow && target.Type == ZoneTargetTypePartition:
+		// Partitions of regional by row tables are named after their region
+		if regions.HasRegion(target.Partition) {
+			expected.HomeRegion = target.Partition
+		} else {
+			expected.HomeRegion = ""
+		}
+	}
+
+	numRegions := len(regions.Regions)
+	switch regions.SurvivalGoal {
+	case SurvivalGoalRegion:
+		expected.NumVoters = numVotersForRegionSurvival
+		expected.NumReplicas = numVotersForRegionSurvival/2 + (numRegions - 1)
+		if expected.NumReplicas < expected.NumVoters {
+			expected.NumReplicas = expected.NumVoters
+		}
+	default:
+		expected.NumVoters = numVotersForZoneSurvival
+		expected.NumReplicas = numVotersForZoneSurvival + (numRegions - 1)
+		// Restricted placement keeps non-voters out of other regions, except for global tables
+		if regions.PlacementPolicy == PlacementPolicyRestricted && locality.Type != LocalityTypeGlobal {
+			expected.NumReplicas = expected.NumVoters
+		}
+	}
+	return expected
+}
+
+// zoneConfigRegions returns the distinct regions named in constraints, voter constraints and lease preferences
+func zoneConfigRegions(zc ZoneConfig) []string {
+	var regions []string
+	seen := make(map[string]bool)
+	for _, c := range zc.AllConstraints() {
+		if region, ok := c.Region(); ok && !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
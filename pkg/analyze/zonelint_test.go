@@ -0,0 +1,76 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lintTestRegions = DatabaseRegions{
+	Database:      "d",
+	PrimaryRegion: "us-east1",
+	Regions:       []Region{{Name: "us-east1", Primary: true}, {Name: "us-west1"}, {Name: "europe-west1"}},
+	SurvivalGoal:  SurvivalGoalRegion,
+}
+
+var lintTestTables = []Table{
+	{Name: "orders", Locality: Locality{Type: LocalityTypeRegionalByRow, RegionColumn: DefaultRegionColumn}},
+	{Name: "customers", Locality: Locality{Type: LocalityTypeRegionalByTable, Region: "us-west1"}},
+}
+
+func lintRules(findings []ZoneLintFinding) []ZoneLintRule {
+	var rules []ZoneLintRule
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+	return rules
+}
+
+func TestLintZoneConfigs(t *testing.T) {
+	required := ZoneConfigConstraintTypeRequired
+	tests := []struct {
+		name     string
+		zone     ZoneConfig
+		expected []ZoneLintRule
+	}{
+		{
+			name: "multi-region default",
+			zone: ZoneConfig{Target: "TABLE d.public.customers", NumReplicas: 5, NumVoters: 5,
+				VoterConstraints: []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{{Type: required, Key: "region", Value: "us-west1"}}, NumReplicas: 2}},
+				LeasePreferences: []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{{Type: required, Key: "region", Value: "us-west1"}}}}},
+		},
+		{
+			name:     "too few voters",
+			zone:     ZoneConfig{Target: "DATABASE d", NumReplicas: 5, NumVoters: 3},
+			expected: []ZoneLintRule{ZoneLintRuleSurvivalVoters, ZoneLintRuleDivergesVoters},
+		},
+		{
+			name: "lease outside home region",
+			zone: ZoneConfig{Target: "TABLE d.public.customers", NumReplicas: 5, NumVoters: 5,
+				LeasePreferences: []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{{Type: required, Key: "region", Value: "us-east1"}}}}},
+			expected: []ZoneLintRule{ZoneLintRuleLeaseRegion},
+		},
+		{
+			name: "partition home region",
+			zone: ZoneConfig{Target: `PARTITION "europe-west1" OF INDEX d.public.orders@orders_pkey`,
+				LeasePreferences: []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{{Type: required, Key: "region", Value: "us-east1"}}}}},
+			expected: []ZoneLintRule{ZoneLintRuleLeaseRegion},
+		},
+		{
+			name: "unknown region",
+			zone: ZoneConfig{Target: "TABLE d.public.orders",
+				Constraints: []ZoneConfigConjunction{{Constraints: []ZoneConfigConstraint{{Type: required, Key: "region", Value: "ap-south1"}}, NumReplicas: 1}}},
+			expected: []ZoneLintRule{ZoneLintRuleUnknownRegion},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := lintZoneConfigs(lintTestRegions, lintTestTables, []ZoneConfig{test.zone})
+			assert.Equal(t, test.expected, lintRules(findings))
+		})
+	}
+}
+
+func TestLintZoneConfigsSingleRegion(t *testing.T) {
+	findings := lintZoneConfigs(DatabaseRegions{Database: "d"}, nil, []ZoneConfig{{Target: "DATABASE d", NumReplicas: 1}})
+	assert.Empty(t, findings)
+}
@@ -0,0 +1,157 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ZoneTargetType is the kind of object a zone configuration applies to
+type ZoneTargetType string
+
+const (
+	ZoneTargetTypeRange     ZoneTargetType = "RANGE"
+	ZoneTargetTypeDatabase  ZoneTargetType = "DATABASE"
+	ZoneTargetTypeTable     ZoneTargetType = "TABLE"
+	ZoneTargetTypeIndex     ZoneTargetType = "INDEX"
+	ZoneTargetTypePartition ZoneTargetType = "PARTITION"
+)
+
+// ZoneTarget is a parsed zone configuration target, such as TABLE d.public.t or
+// PARTITION "us-east1" OF INDEX d.public.t@t_pkey
+type ZoneTarget struct {
+	Type      ZoneTargetType
+	Range     string
+	Database  string
+	Schema    string
+	Table     string
+	Index     string
+	Partition string
+}
+
+var zoneTargetPartitionRe = regexp.MustCompile(`(?i)^PARTITION\s+("(?:[^"]|"")*"|\S+)\s+OF\s+(?:INDEX|TABLE)\s+(.+)$`)
+var zoneTargetRe = regexp.MustCompile(`(?i)^(RANGE|DATABASE|TABLE|INDEX)\s+(.+)$`)
+
+// ParseZoneTarget parses a target as shown by SHOW ZONE CONFIGURATIONS
+func ParseZoneTarget(s string) (ZoneTarget, error) {
+	s = strings.TrimSpace(s)
+	var target ZoneTarget
+
+	if matches := zoneTargetPartitionRe.FindStringSubmatch(s); matches != nil {
+		target.Type = ZoneTargetTypePartition
+		target.Partition = unquoteIdentifier(matches[1])
+		err := target.setTableName(matches[2])
+		return target, err
+	}
+
+	matches := zoneTargetRe.FindStringSubmatch(s)
+	if matches == nil {
+		return target, fmt.Errorf("invalid zone target: %q", s)
+	}
+	target.Type = ZoneTargetType(strings.ToUpper(matches[1]))
+	switch target.Type {
+	case ZoneTargetTypeRange:
+		target.Range = unquoteIdentifier(matches[2])
+	case ZoneTargetTypeDatabase:
+		target.Database = unquoteIdentifier(matches[2])
+	default:
+		if err := target.setTableName(matches[2]); err != nil {
+			return target, err
+		}
+	}
+	return target, nil
+}
+
+// setTableName sets the database, schema, table and index from a name like d.public.t@idx or d.t@idx
+func (t *ZoneTarget) setTableName(name string) error {
+	// The index follows an @ that is not inside quotes
+	inQuote := false
+	for i, r := range name {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r == '@' && !inQuote {
+			t.Index = unquoteIdentifier(strings.TrimSpace(name[i+1:]))
+			name = name[:i]
+			break
+		}
+	}
+
+	parts := splitQualifiedName(name)
+	if len(parts) == 0 {
+		return fmt.Errorf("invalid zone target name: %q", name)
+	}
+	for i := range parts {
+		parts[i] = unquoteIdentifier(parts[i])
+	}
+	t.Table = parts[len(parts)-1]
+	switch len(parts) {
+	case 1:
+	case 2:
+		// Legacy targets name the table as database.table without a schema
+		t.Database = parts[0]
+	default:
+		t.Schema = parts[len(parts)-2]
+		t.Database = parts[len(parts)-3]
+	}
+	return nil
+}
+
+// String returns the target as used in ALTER ... CONFIGURE ZONE
+func (t ZoneTarget) String() string {
+	switch t.Type {
+	case ZoneTargetTypeRange:
+		return fmt.Sprintf("RANGE %s", t.Range)
+	case ZoneTargetTypeDatabase:
+		return fmt.Sprintf("DATABASE %s", quoteIdentifier(t.Database))
+	case ZoneTargetTypeTable:
+		return fmt.Sprintf("TABLE %s", t.tableName())
+	case ZoneTargetTypeIndex:
+		return fmt.Sprintf("INDEX %s@%s", t.tableName(), quoteIdentifier(t.Index))
+	case ZoneTargetTypePartition:
+		if t.Index == "" {
+			return fmt.Sprintf("PARTITION %s OF TABLE %s", quoteIdentifier(t.Partition), t.tableName())
+		}
+		return fmt.Sprintf("PARTITION %s OF INDEX %s@%s", quoteIdentifier(t.Partition), t.tableName(), quoteIdentifier(t.Index))
+	default:
+		return ""
+	}
+}
+
+// InDatabase determines whether the target belongs to the database. RANGE targets belong to no database.
+func (t ZoneTarget) InDatabase(database string) bool {
+	return t.Type != ZoneTargetTypeRange && t.Database == database
+}
+
+func (t ZoneTarget) tableName() string {
+	var parts []string
+	for _, part := range []string{t.Database, t.Schema, t.Table} {
+		if part != "" {
+			parts = append(parts, quoteIdentifier(part))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// splitQualifiedName splits a dotted name on dots that are not inside double quotes
+func splitQualifiedName(name string) []string {
+	var parts []string
+	var current strings.Builder
+	inQuote := false
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			current.WriteRune(r)
+		case r == '.' && !inQuote:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
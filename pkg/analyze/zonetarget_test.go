@@ -0,0 +1,38 @@
+package analyze
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseZoneTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ZoneTarget
+		str      string
+	}{
+		{"RANGE default", ZoneTarget{Type: ZoneTargetTypeRange, Range: "default"}, "RANGE default"},
+		{"DATABASE d", ZoneTarget{Type: ZoneTargetTypeDatabase, Database: "d"}, `DATABASE "d"`},
+		{"TABLE d.public.t", ZoneTarget{Type: ZoneTargetTypeTable, Database: "d", Schema: "public", Table: "t"}, `TABLE "d"."public"."t"`},
+		{`INDEX d.public."My.Table"@t_idx`,
+			ZoneTarget{Type: ZoneTargetTypeIndex, Database: "d", Schema: "public", Table: "My.Table", Index: "t_idx"},
+			`INDEX "d"."public"."My.Table"@"t_idx"`},
+		{`PARTITION "us-east1" OF INDEX d.public.t@t_pkey`,
+			ZoneTarget{Type: ZoneTargetTypePartition, Database: "d", Schema: "public", Table: "t", Index: "t_pkey", Partition: "us-east1"},
+			`PARTITION "us-east1" OF INDEX "d"."public"."t"@"t_pkey"`},
+		{"TABLE d.t", ZoneTarget{Type: ZoneTargetTypeTable, Database: "d", Table: "t"}, `TABLE "d"."t"`},
+		{"INDEX d.t@t_idx", ZoneTarget{Type: ZoneTargetTypeIndex, Database: "d", Table: "t", Index: "t_idx"}, `INDEX "d"."t"@"t_idx"`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			target, err := ParseZoneTarget(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, target)
+			assert.Equal(t, test.str, target.String())
+		})
+	}
+
+	_, err := ParseZoneTarget("SCHEMA d.public")
+	assert.Error(t, err)
+}
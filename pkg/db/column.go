@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+)
+
+type ColumnRow struct {
+	Table    string
+	Name     string
+	DataType string
+	Nullable bool
+	Default  string
+	Hidden   bool
+	// Generated is the expression of a computed column, empty for other columns
+	Generated string
+}
+
+type IndexRow struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+const columnsSql = `
+SELECT table_name, column_name, crdb_sql_type, is_nullable = 'YES', coalesce(column_default, ''), is_hidden = 'YES',
+  coalesce(generation_expression, '')
+FROM information_schema.columns
+WHERE table_catalog = $1 AND table_schema = 'public'
+ORDER BY table_name, ordinal_position
+`
+
+const indexesSql = `
+SELECT tablename, indexname, indexdef
+FROM pg_catalog.pg_indexes
+WHERE schemaname = 'public'
+ORDER BY tablename, indexname
+`
+
+// Columns returns the columns of all tables in the public schema of the database, in table order
+func (db *Db) Columns(database string) ([]ColumnRow, error) {
+	var rows []ColumnRow
+
+	rs, err := db.Pool.Query(context.Background(), columnsSql, database)
+	if err != nil {
+		return rows, err
+	}
+
+	for rs.Next() {
+		var row ColumnRow
+		err := rs.Scan(&row.Table, &row.Name, &row.DataType, &row.Nullable, &row.Default, &row.Hidden, &row.Generated)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Indexes returns the indexes of all tables in the public schema of the connected database, with their
+// CREATE INDEX definitions
+func (db *Db) Indexes() ([]IndexRow, error) {
+	var rows []IndexRow
+
+	rs, err := db.Pool.Query(context.Background(), indexesSql)
+	if err != nil {
+		return rows, err
+	}
+
+	for rs.Next() {
+		var row IndexRow
+		err := rs.Scan(&row.Table, &row.Name, &row.Definition)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+)
+
+type ConstraintRow struct {
+	Table string
+	Name  string
+	Type  string
+}
+
+type SchemaChangeJobRow struct {
+	ID          int64
+	Type        string
+	Status      string
+	Description string
+}
+
+type FunctionRow struct {
+	Schema          string
+	Name            string
+	CreateStatement string
+}
+
+const constraintsSql = `
+SELECT table_name, constraint_name, constraint_type
+FROM information_schema.table_constraints
+WHERE table_catalog = $1 AND table_schema = 'public'
+ORDER BY table_name, constraint_name
+`
+
+const schemaChangeJobsSql = `
+SELECT job_id, job_type, status, description
+FROM crdb_internal.jobs
+WHERE job_type IN ('SCHEMA CHANGE', 'NEW SCHEMA CHANGE', 'TYPEDESC SCHEMA CHANGE')
+  AND status NOT IN ('succeeded', 'failed', 'canceled', 'revert-failed')
+  AND descriptor_ids && (
+    SELECT array_agg(id) FROM (
+      SELECT table_id AS id FROM "".crdb_internal.tables WHERE database_name = $1
+      UNION ALL SELECT descriptor_id FROM "".crdb_internal.create_type_statements WHERE database_name = $1
+      UNION ALL SELECT id FROM crdb_internal.databases WHERE name = $1
+    )
+  )
+ORDER BY job_id
+`
+
+const functionsSql = `
+SELECT schema_name, function_name, create_statement
+FROM "".crdb_internal.create_function_statements
+WHERE database_name = $1
+ORDER BY schema_name, function_name
+`
+
+// Constraints returns the names of all constraints on tables in the public schema of the database
+func (db *Db) Constraints(database string) ([]ConstraintRow, error) {
+	var rows []ConstraintRow
+
+	rs, err := db.Pool.Query(context.Background(), constraintsSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row ConstraintRow
+		err := rs.Scan(&row.Table, &row.Name, &row.Type)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// SchemaChangeJobs returns the schema change jobs that have not finished on the database or its tables and
+// types, matched by the descriptors of the jobs
+func (db *Db) SchemaChangeJobs(database string) ([]SchemaChangeJobRow, error) {
+	var rows []SchemaChangeJobRow
+
+	rs, err := db.Pool.Query(context.Background(), schemaChangeJobsSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row SchemaChangeJobRow
+		err := rs.Scan(&row.ID, &row.Type, &row.Status, &row.Description)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Functions returns the CREATE statements for all user-defined functions in the database
+func (db *Db) Functions(database string) ([]FunctionRow, error) {
+	var rows []FunctionRow
+
+	rs, err := db.Pool.Query(context.Background(), functionsSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row FunctionRow
+		err := rs.Scan(&row.Schema, &row.Name, &row.CreateStatement)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
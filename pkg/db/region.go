@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+type DatabaseRow struct {
+	Name            string
+	PrimaryRegion   string
+	Regions         []string
+	SurvivalGoal    string
+	PlacementPolicy string
+}
+
+type RegionRow struct {
+	Region  string
+	Primary bool
+	Zones   []string
+}
+
+type SuperRegionRow struct {
+	Name    string
+	Regions []string
+}
+
+const databaseSql = `
+SELECT name, coalesce(primary_region, ''), coalesce(regions, ARRAY[]::STRING[]),
+  coalesce(survival_goal, ''), coalesce(placement_policy, '')
+FROM crdb_internal.databases
+WHERE name = $1
+`
+
+const showRegionsSql = `
+WITH x AS (SHOW REGIONS FROM DATABASE %s) SELECT region, "primary", zones FROM x ORDER BY region
+`
+
+const showSuperRegionsSql = `
+WITH x AS (SHOW SUPER REGIONS FROM DATABASE %s) SELECT super_region_name, regions FROM x ORDER BY super_region_name
+`
+
+// DatabaseSettings returns the multi-region settings for the database
+func (db *Db) DatabaseSettings(database string) (DatabaseRow, error) {
+	var row DatabaseRow
+	err := db.Pool.QueryRow(context.Background(), databaseSql, database).Scan(
+		&row.Name, &row.PrimaryRegion, &row.Regions, &row.SurvivalGoal, &row.PlacementPolicy)
+	return row, err
+}
+
+// ShowRegions returns the output from SHOW REGIONS FROM DATABASE [database]
+func (db *Db) ShowRegions(database string) ([]RegionRow, error) {
+	var rows []RegionRow
+
+	rs, err := db.Pool.Query(context.Background(), fmt.Sprintf(showRegionsSql, database))
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row RegionRow
+		err := rs.Scan(&row.Region, &row.Primary, &row.Zones)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ShowSuperRegions returns the output from SHOW SUPER REGIONS FROM DATABASE [database]
+func (db *Db) ShowSuperRegions(database string) ([]SuperRegionRow, error) {
+	var rows []SuperRegionRow
+
+	rs, err := db.Pool.Query(context.Background(), fmt.Sprintf(showSuperRegionsSql, database))
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row SuperRegionRow
+		err := rs.Scan(&row.Name, &row.Regions)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+)
+
+type StatementTablesRow struct {
+	Query  string
+	Count  int64
+	Tables []string
+}
+
+// statementTablesSql lists the persisted statement statistics of the database with the tables of the indexes
+// each statement used
+const statementTablesSql = `
+WITH s AS (
+  SELECT row_number() OVER () AS n, metadata ->> 'query' AS query,
+    (statistics -> 'statistics' ->> 'cnt')::INT AS cnt,
+    statistics -> 'statistics' -> 'indexes' AS indexes
+  FROM crdb_internal.statement_statistics
+  WHERE metadata ->> 'db' = $1
+)
+SELECT s.query, s.cnt, array_agg(DISTINCT t.name)
+FROM s, jsonb_array_elements_text(s.indexes) AS idx
+  INNER JOIN "".crdb_internal.tables t ON t.table_id = split_part(idx, '@', 1)::INT
+WHERE t.database_name = $1
+GROUP BY s.n, s.query, s.cnt
+`
+
+// StatementTables returns the statements executed in the database, from the persisted statement statistics,
+// with their execution count and the tables they used
+func (db *Db) StatementTables(database string) ([]StatementTablesRow, error) {
+	var rows []StatementTablesRow
+
+	rs, err := db.Pool.Query(context.Background(), statementTablesSql, database)
+	if err != nil {
+		return rows, err
+	}
+
+	for rs.Next() {
+		var row StatementTablesRow
+		err := rs.Scan(&row.Query, &row.Count, &row.Tables)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
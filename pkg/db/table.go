@@ -3,26 +3,31 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type TableSizeRow struct {
 	Database     string
+	Schema       string
 	Name         string
 	LogicalBytes uint64
+	RangeCount   int
 }
 
 const tableSizeSql = `
 SELECT t.database_name,
+  t.schema_name,
   t.name as table_name,
   sum((crdb_internal.range_stats(r.start_key) ->> 'key_bytes')::INT
     + (crdb_internal.range_stats(r.start_key) ->> 'val_bytes')::INT
     + coalesce((crdb_internal.range_stats(r.start_key) ->> 'range_key_bytes')::INT, 0)
-    + coalesce((crdb_internal.range_stats(r.start_key) ->> 'range_val_bytes')::INT, 0)) AS logical_size_bytes
+    + coalesce((crdb_internal.range_stats(r.start_key) ->> 'range_val_bytes')::INT, 0)) AS logical_size_bytes,
+  count(DISTINCT r.range_id) AS range_count
 FROM crdb_internal.ranges_no_leases r
   LEFT OUTER JOIN "".crdb_internal.index_spans s ON s.start_key < r.end_key AND s.end_key > r.start_key
   LEFT OUTER JOIN "".crdb_internal.tables t ON s.descriptor_id = t.table_id
 WHERE t.database_name = $1 
-GROUP BY t.database_name, t.name
+GROUP BY t.database_name, t.schema_name, t.name
 `
 
 type ShowTablesRow struct {
@@ -35,10 +40,11 @@ type ShowTablesRow struct {
 }
 
 const showTableSql = `
-WITH x AS (SHOW TABLES FROM %s) SELECT * FROM x WHERE type = 'table'
+WITH x AS (SHOW TABLES FROM %s)
+SELECT schema_name, table_name, type, owner, coalesce(estimated_row_count, 0), coalesce(locality, '') FROM x
 `
 
-// TableSize gets the logical table sizes for all tables/databases
+// TableSize gets the logical table sizes and range counts for all tables/databases
 func (db *Db) TableSize(database string) ([]TableSizeRow, error) {
 	var rows []TableSizeRow
 
@@ -49,20 +55,23 @@ func (db *Db) TableSize(database string) ([]TableSizeRow, error) {
 	}
 
 	var dbase string
+	var schema string
 	var name string
 	var logicalBytes uint64
+	var rangeCount int
 
 	for rs.Next() {
-		err := rs.Scan(&dbase, &name, &logicalBytes)
+		err := rs.Scan(&dbase, &schema, &name, &logicalBytes, &rangeCount)
 		if err != nil {
 			return rows, err
 		}
-		rows = append(rows, TableSizeRow{Database: dbase, Name: name, LogicalBytes: logicalBytes})
+		rows = append(rows, TableSizeRow{Database: dbase, Schema: schema, Name: name, LogicalBytes: logicalBytes, RangeCount: rangeCount})
 	}
 	return rows, nil
 }
 
-// ShowTables returns the output from SHOW TABLES FROM [database]
+// ShowTables returns the output from SHOW TABLES FROM [database], which includes tables, views,
+// materialized views and sequences
 func (db *Db) ShowTables(database string) ([]ShowTablesRow, error) {
 	var rows []ShowTablesRow
 
@@ -97,3 +106,59 @@ func (db *Db) ShowTables(database string) ([]ShowTablesRow, error) {
 
 	return rows, nil
 }
+
+const countRowsWithValueSql = `
+SELECT count(*) FROM %s AS OF SYSTEM TIME follower_read_timestamp() WHERE %s::STRING = $1
+`
+
+// CountRowsWithValue counts the rows of the table where the column has the value. The table and column
+// must already be quoted. The count is read as of the follower read timestamp to avoid contention.
+func (db *Db) CountRowsWithValue(table string, column string, value string) (int, error) {
+	var count int
+	err := db.Pool.QueryRow(context.Background(), fmt.Sprintf(countRowsWithValueSql, table, column), value).Scan(&count)
+	return count, err
+}
+
+type ValueCountRow struct {
+	Value string
+	Rows  int
+}
+
+const countRowsByValueSql = `
+SELECT %s::STRING, count(*) FROM %s AS OF SYSTEM TIME %s %s GROUP BY 1 ORDER BY 1
+`
+
+// CountRowsByValue counts the rows of the table for each value of the column, read as of the timestamp.
+// The table and column must already be quoted and asOf must be a valid AS OF SYSTEM TIME expression.
+// A sample rate below one only counts that fraction of rows. Every row is still scanned, so sampling reduces
+// the aggregation work but not the I/O.
+func (db *Db) CountRowsByValue(table string, column string, asOf string, sampleRate float64) ([]ValueCountRow, error) {
+	var rows []ValueCountRow
+
+	where := ""
+	if sampleRate > 0 && sampleRate < 1 {
+		where = fmt.Sprintf("WHERE random() < %f", sampleRate)
+	}
+	rs, err := db.Pool.Query(context.Background(), fmt.Sprintf(countRowsByValueSql, column, table, asOf, where))
+	if err != nil {
+		return rows, err
+	}
+
+	for rs.Next() {
+		var row ValueCountRow
+		err := rs.Scan(&row.Value, &row.Rows)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// FollowerReadTimestamp returns the timestamp follower reads are served at, used to read several tables
+// at the same consistent time
+func (db *Db) FollowerReadTimestamp() (time.Time, error) {
+	var ts time.Time
+	err := db.Pool.QueryRow(context.Background(), "SELECT follower_read_timestamp()").Scan(&ts)
+	return ts, err
+}
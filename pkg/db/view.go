@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type ViewRow struct {
+	Schema          string
+	Name            string
+	CreateStatement string
+}
+
+type DependencyRow struct {
+	Schema        string
+	Name          string
+	DependsOnName string
+}
+
+type RefreshRow struct {
+	Schema   string
+	Name     string
+	Finished time.Time
+}
+
+type SequenceRow struct {
+	Schema    string
+	Name      string
+	DataType  string
+	Start     int64
+	Min       int64
+	Max       int64
+	Increment int64
+	Cycle     bool
+	Cache     int64
+	LastValue *int64
+}
+
+const viewsSql = `
+SELECT schema_name, descriptor_name, create_statement
+FROM "".crdb_internal.create_statements
+WHERE database_name = $1 AND descriptor_type = 'view'
+ORDER BY schema_name, descriptor_name
+`
+
+const dependenciesSql = `
+SELECT v.schema_name, d.descriptor_name, t.name
+FROM "".crdb_internal.backward_dependencies d
+  INNER JOIN "".crdb_internal.tables t ON d.dependson_id = t.table_id
+  INNER JOIN "".crdb_internal.tables v ON d.descriptor_id = v.table_id
+WHERE t.database_name = $1
+`
+
+const refreshSql = `
+SELECT t.schema_name, t.name, max(j.finished)
+FROM crdb_internal.jobs j
+  INNER JOIN "".crdb_internal.tables t ON t.table_id = ANY (j.descriptor_ids)
+WHERE t.database_name = $1 AND j.status = 'succeeded' AND j.finished IS NOT NULL
+  AND (j.description ILIKE 'REFRESH MATERIALIZED VIEW %' OR j.description ILIKE 'CREATE MATERIALIZED VIEW %')
+GROUP BY t.schema_name, t.name
+`
+
+const sequencesSql = `
+SELECT schemaname, sequencename, data_type::STRING, start_value, min_value, max_value,
+  increment_by, cycle, cache_size, last_value
+FROM %s.pg_catalog.pg_sequences
+ORDER BY schemaname, sequencename
+`
+
+// Views returns the CREATE statements for all views and materialized views in the database
+func (db *Db) Views(database string) ([]ViewRow, error) {
+	var rows []ViewRow
+
+	rs, err := db.Pool.Query(context.Background(), viewsSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row ViewRow
+		err := rs.Scan(&row.Schema, &row.Name, &row.CreateStatement)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Dependencies returns the objects that depend on tables, views and sequences in the database
+func (db *Db) Dependencies(database string) ([]DependencyRow, error) {
+	var rows []DependencyRow
+
+	rs, err := db.Pool.Query(context.Background(), dependenciesSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row DependencyRow
+		err := rs.Scan(&row.Schema, &row.Name, &row.DependsOnName)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// MaterializedViewRefreshes returns the last successful completion of the create or refresh jobs of each
+// materialized view in the database, matched by the descriptors of the jobs
+func (db *Db) MaterializedViewRefreshes(database string) ([]RefreshRow, error) {
+	var rows []RefreshRow
+
+	rs, err := db.Pool.Query(context.Background(), refreshSql, database)
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row RefreshRow
+		err := rs.Scan(&row.Schema, &row.Name, &row.Finished)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Sequences returns the settings for all sequences in the database
+func (db *Db) Sequences(database string) ([]SequenceRow, error) {
+	var rows []SequenceRow
+
+	rs, err := db.Pool.Query(context.Background(), fmt.Sprintf(sequencesSql, database))
+	if err != nil {
+		return rows, err
+	}
+	for rs.Next() {
+		var row SequenceRow
+		err := rs.Scan(&row.Schema, &row.Name, &row.DataType, &row.Start, &row.Min, &row.Max,
+			&row.Increment, &row.Cycle, &row.Cache, &row.LastValue)
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}